@@ -0,0 +1,7 @@
+// Package v1 contains the types of the databases.k8s.io API, the deepcopy functions, clientset,
+// listers and informers are generated from them by scripts/update-codegen.sh
+//
+// +k8s:deepcopy-gen=package
+// +groupName=k8s.io
+// +groupGoName=Database
+package v1
@@ -0,0 +1,289 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Database is the definition of our CRD Database
+type Database struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              DatabaseSpec   `json:"spec"`
+	Status            DatabaseStatus `json:"status,omitempty"`
+}
+
+// DatabaseSpec main structure describing the database instance, the schema of the CRD is generated from the
+// json tags and the validation tags described in crd/schema.go
+type DatabaseSpec struct {
+	Username              string            `json:"username" description:"User Name to access the database" minLength:"1" maxLength:"16" pattern:"^[A-Za-z]\\w+$"`
+	Password              PasswordSecret    `json:"password" description:"Secret and key holding the password of the database user"`
+	DBName                string            `json:"dbname" description:"Database name" minLength:"1" maxLength:"63" pattern:"^[A-Za-z]\\w+$"`
+	Engine                string            `json:"engine" description:"database engine. Ex: postgres, mysql, aurora-postgresql, etc"`
+	Version               string            `json:"version" description:"database engine version. ex 5.1.49"`
+	AllowMajorUpgrade     bool              `json:"allowMajorVersionUpgrade,omitempty" description:"Allow changing version to a new major version of the engine, major upgrades can't be undone"`
+	Class                 string            `json:"class" description:"instance class name. Ex: db.m5.24xlarge or db.m3.medium"`
+	Size                  int64             `json:"size" description:"Database size in Gb" minimum:"20" maximum:"64000"`
+	MaxAllocatedSize      int64             `json:"MaxAllocatedSize,omitempty" description:"The maximum allowed storage size in Gb for the database when using autoscaling. Has to be larger then size" minimum:"20" maximum:"64000"`
+	MultiAZ               bool              `json:"multiaz,omitempty" description:"should it be available in multiple regions?"`
+	PubliclyAccessible    bool              `json:"publicaccess,omitempty" description:"is the database publicly accessible?"`
+	StorageEncrypted      bool              `json:"encrypted,omitempty" description:"should the storage be encrypted?"`
+	StorageType           string            `json:"storagetype,omitempty" description:"gp2 (General Purpose SSD) or io1 (Provisioned IOPS SSD)" pattern:"gp2|io1"`
+	Iops                  int64             `json:"iops,omitempty" description:"I/O operations per second" minimum:"1000" maximum:"80000"`
+	BackupRetentionPeriod int64             `json:"backupretentionperiod,omitempty" description:"Retention period in days. 0 means disabled, 7 is the default and 35 is the maximum" minimum:"0" maximum:"35"`
+	DeleteProtection      bool              `json:"deleteprotection,omitempty" description:"Enable or disable deletion protection"`
+	Tags                  string            `json:"tags,omitempty" description:"Tags to create on the database instance format key=value,key1=value1"`
+	Provider              string            `json:"provider,omitempty" description:"Provider used to create the database, aws or local" enum:"aws,local"`
+	Port                  int32             `json:"port,omitempty" description:"Port the database listens on, defaults to the standard port of the engine" minimum:"1" maximum:"65535"`
+	ConnectionSecretName  string            `json:"connectionSecretName,omitempty" description:"Name of the Secret with the connection details for applications, defaults to <name>-connection" maxLength:"253"`
+	SkipFinalSnapshot     bool              `json:"skipfinalsnapshot,omitempty" description:"Indicates whether to skip the creation of a final DB snapshot before deleting the instance. By default, skipfinalsnapshot isn't enabled, and the DB snapshot is created."`
+	ReadReplicas          *ReadReplicas     `json:"readReplicas,omitempty" description:"Read replicas of the database, each one gets its own <name>-ro-<n> service"`
+	RestoreFrom           *RestoreFrom      `json:"restoreFrom,omitempty" description:"Create the database from a snapshot or from a point in time of another database, only used when the database is first created"`
+	Parameters            map[string]string `json:"parameters,omitempty" description:"Engine parameters of the database, ex max_connections: \"200\". Set through a parameter group owned by the database for aws"`
+	SubnetGroup           *SubnetGroup      `json:"subnetGroup,omitempty" description:"Subnets of the database, defaults to a subnet group shared by the databases in the VPC of the cluster"`
+	SecurityGroupIDs      []string          `json:"securityGroupIds,omitempty" description:"IDs of existing security groups of the database, by default the database gets a security group of its own"`
+	AllowedSecurityGroups []string          `json:"allowedSecurityGroupIds,omitempty" description:"IDs of security groups allowed to reach the database besides the groups of the nodes, ex the groups of pods using security groups for pods"`
+	AllowedCIDRs          []string          `json:"allowedCIDRs,omitempty" description:"CIDR blocks allowed to reach the database, ex 10.0.0.0/16"`
+	ProviderConfigRef     string            `json:"providerConfigRef,omitempty" description:"Name of the ProviderConfig with the AWS account, role and network of the database, defaults to the configuration of the operator"`
+	Options               []DatabaseOption  `json:"options,omitempty" description:"Engine options of the database, ex MEMCACHED for mysql or SQLSERVER_BACKUP_RESTORE for sqlserver. Set through an option group owned by the database for aws"`
+}
+
+// SubnetGroup selects the subnets of a database, either name, subnetIds or subnetTags is set. With subnetIds or
+// subnetTags the database gets a subnet group of its own
+type SubnetGroup struct {
+	Name       string            `json:"name,omitempty" description:"Name of an existing DB subnet group, it is never modified or deleted by the operator"`
+	SubnetIDs  []string          `json:"subnetIds,omitempty" description:"IDs of the subnets of the subnet group owned by the database"`
+	SubnetTags map[string]string `json:"subnetTags,omitempty" description:"Tags of the subnets in the VPC of the cluster for the subnet group owned by the database, ex kubernetes.io/role/internal-elb: \"1\""`
+}
+
+// DatabaseOption is an option of an option group, like the memcached plugin of mysql
+type DatabaseOption struct {
+	Name     string            `json:"name" description:"Name of the option, ex MARIADB_AUDIT_PLUGIN" minLength:"1"`
+	Version  string            `json:"version,omitempty" description:"Version of the option, defaults to the latest version"`
+	Port     int32             `json:"port,omitempty" description:"Port of options that listen on a port, ex 11211 for MEMCACHED" minimum:"1" maximum:"65535"`
+	Settings map[string]string `json:"settings,omitempty" description:"Settings of the option, ex IAM_ROLE_ARN for SQLSERVER_BACKUP_RESTORE"`
+}
+
+// RestoreFrom selects the data a new database is created from, either snapshotIdentifier or pointInTime is set
+type RestoreFrom struct {
+	SnapshotIdentifier string       `json:"snapshotIdentifier,omitempty" description:"Identifier or ARN of the DB snapshot to restore, ex the final snapshot <name>-<namespace>-<timestamp> of a deleted database"`
+	PointInTime        *PointInTime `json:"pointInTime,omitempty" description:"Restore another database as it was at a point in time"`
+}
+
+// PointInTime selects a database in the same namespace and the time to restore it to
+type PointInTime struct {
+	Database    string       `json:"database" description:"Name of the Database in the same namespace to restore from" minLength:"1"`
+	RestoreTime *metav1.Time `json:"restoreTime,omitempty" description:"Time to restore to, defaults to the latest restorable time"`
+}
+
+// ReadReplicas describes the read replicas of a database
+type ReadReplicas struct {
+	Count            int32  `json:"count" description:"Number of read replicas" minimum:"0" maximum:"15"`
+	Class            string `json:"class,omitempty" description:"instance class of the replicas, defaults to the class of the database"`
+	AvailabilityZone string `json:"availabilityZone,omitempty" description:"Availability zone of the replicas, ex eu-west-1b"`
+	Region           string `json:"region,omitempty" description:"Region of the replicas for cross region replication, defaults to the region of the database"`
+}
+
+// PasswordSecret selects the key of the secret holding the password of the database user
+type PasswordSecret struct {
+	corev1.SecretKeySelector `json:",inline"`
+	// Generate creates the secret with a random password if it doesn't exist, an existing password is never replaced
+	Generate bool `json:"generate,omitempty" description:"Create the secret with a random password if it does not exist, an existing password is never replaced"`
+	// RotationInterval replaces a generated password once it is older than the interval
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty" description:"Replace the generated password when it is older than the interval, ex 720h. Requires generate"`
+}
+
+// Condition types reported in DatabaseStatus.Conditions
+const (
+	ConditionReady        = "Ready"
+	ConditionProvisioning = "Provisioning"
+	ConditionDegraded     = "Degraded"
+	ConditionDeleting     = "Deleting"
+	// ConditionPasswordRotated is true once a changed password has been applied to the database
+	ConditionPasswordRotated = "PasswordRotated"
+)
+
+// DatabaseStatus is written by the operator through the status subresource
+type DatabaseStatus struct {
+	State                string             `json:"state,omitempty" description:"State of the deploy"`
+	Message              string             `json:"message,omitempty" description:"Detailed message around the state"`
+	LastError            string             `json:"lastError,omitempty" description:"Last error seen while reconciling the database"`
+	Conditions           []metav1.Condition `json:"conditions,omitempty" description:"Ready, Provisioning, Degraded and Deleting conditions of the database"`
+	ObservedGeneration   int64              `json:"observedGeneration,omitempty" description:"Generation of the spec that was last applied to the database"`
+	Endpoint             string             `json:"endpoint,omitempty" description:"Hostname of the database at the provider"`
+	Port                 int32              `json:"port,omitempty" description:"Port the database is listening on"`
+	Provider             string             `json:"provider,omitempty" description:"Provider used for the database, aws or local"`
+	ARN                  string             `json:"arn,omitempty" description:"Amazon Resource Name of the database"`
+	ResourceID           string             `json:"resourceId,omitempty" description:"Identifier of the database at the provider"`
+	EngineVersion        string             `json:"engineVersion,omitempty" description:"Engine version the database is running"`
+	PasswordHash         string             `json:"passwordHash,omitempty" description:"Fingerprint of the password that was last applied to the database"`
+	PasswordRotationTime *metav1.Time       `json:"passwordRotationTime,omitempty" description:"Last time the password was set on the database"`
+	ReadReplicas         []ReplicaStatus    `json:"readReplicas,omitempty" description:"Read replicas of the database"`
+	RestoredFrom         string             `json:"restoredFrom,omitempty" description:"Snapshot or database the database was restored from"`
+	ParameterApplyStatus string             `json:"parameterApplyStatus,omitempty" description:"Whether the parameters are applied, in-sync, applying or pending-reboot"`
+	LastReconcileTime    *metav1.Time       `json:"lastReconcileTime,omitempty" description:"Last time the database was reconciled"`
+}
+
+// ReplicaStatus is the state of a read replica
+type ReplicaStatus struct {
+	Name     string `json:"name" description:"Name of the service of the replica"`
+	Endpoint string `json:"endpoint,omitempty" description:"Hostname of the replica at the provider"`
+	State    string `json:"state,omitempty" description:"State of the replica at the provider"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DatabaseList is a list of databases
+type DatabaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []Database `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DatabaseCluster is an Aurora cluster with a writer and optional reader instances
+type DatabaseCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              DatabaseClusterSpec   `json:"spec"`
+	Status            DatabaseClusterStatus `json:"status,omitempty"`
+}
+
+// DatabaseClusterSpec describes the cluster and its member instances
+type DatabaseClusterSpec struct {
+	Username              string                   `json:"username" description:"User Name to access the database" minLength:"1" maxLength:"16" pattern:"^[A-Za-z]\\w+$"`
+	Password              corev1.SecretKeySelector `json:"password" description:"Secret and key holding the password of the database user"`
+	DBName                string                   `json:"dbname" description:"Database name" minLength:"1" maxLength:"63" pattern:"^[A-Za-z]\\w+$"`
+	Engine                string                   `json:"engine" description:"Aurora engine of the cluster" enum:"aurora-postgresql,aurora-mysql"`
+	Version               string                   `json:"version,omitempty" description:"database engine version. ex 13.6"`
+	AllowMajorUpgrade     bool                     `json:"allowMajorVersionUpgrade,omitempty" description:"Allow changing version to a new major version of the engine, major upgrades can't be undone"`
+	Class                 string                   `json:"class,omitempty" description:"instance class of the members. Ex: db.r6g.large, defaults to db.serverless when serverlessV2 is set"`
+	Instances             int32                    `json:"instances,omitempty" description:"Number of instances in the cluster, the first one is the writer and the others are readers. Defaults to 1" minimum:"0" maximum:"16"`
+	ServerlessV2          *ServerlessV2Scaling     `json:"serverlessV2,omitempty" description:"Capacity range of the Aurora Serverless v2 instances of the cluster"`
+	PubliclyAccessible    bool                     `json:"publicaccess,omitempty" description:"are the instances publicly accessible?"`
+	StorageEncrypted      bool                     `json:"encrypted,omitempty" description:"should the storage be encrypted?"`
+	BackupRetentionPeriod int64                    `json:"backupretentionperiod,omitempty" description:"Retention period in days, between 1 and 35. Defaults to 1" minimum:"0" maximum:"35"`
+	DeleteProtection      bool                     `json:"deleteprotection,omitempty" description:"Enable or disable deletion protection"`
+	Tags                  string                   `json:"tags,omitempty" description:"Tags to create on the cluster and its instances format key=value,key1=value1"`
+	Port                  int32                    `json:"port,omitempty" description:"Port the cluster listens on, defaults to the standard port of the engine" minimum:"1" maximum:"65535"`
+	SkipFinalSnapshot     bool                     `json:"skipfinalsnapshot,omitempty" description:"Indicates whether to skip the creation of a final cluster snapshot before deleting the cluster"`
+	SecurityGroupIDs      []string                 `json:"securityGroupIds,omitempty" description:"IDs of existing security groups of the cluster, by default the cluster gets a security group of its own"`
+	AllowedSecurityGroups []string                 `json:"allowedSecurityGroupIds,omitempty" description:"IDs of security groups allowed to reach the cluster besides the groups of the nodes, ex the groups of pods using security groups for pods"`
+	AllowedCIDRs          []string                 `json:"allowedCIDRs,omitempty" description:"CIDR blocks allowed to reach the cluster, ex 10.0.0.0/16"`
+	ProviderConfigRef     string                   `json:"providerConfigRef,omitempty" description:"Name of the ProviderConfig with the AWS account, role and network of the cluster, defaults to the configuration of the operator"`
+}
+
+// ServerlessV2Scaling is the capacity range of Aurora Serverless v2 instances in Aurora capacity units (ACU)
+type ServerlessV2Scaling struct {
+	MinCapacity float64 `json:"minCapacity" description:"Minimum capacity in ACU, in steps of 0.5" minimum:"0.5" maximum:"128"`
+	MaxCapacity float64 `json:"maxCapacity" description:"Maximum capacity in ACU, in steps of 0.5" minimum:"1" maximum:"128"`
+}
+
+// DatabaseClusterStatus is written by the operator through the status subresource, Endpoint is the writer endpoint
+type DatabaseClusterStatus struct {
+	DatabaseStatus `json:",inline"`
+	ReaderEndpoint string   `json:"readerEndpoint,omitempty" description:"Hostname of the load balanced reader endpoint of the cluster"`
+	Members        []string `json:"members,omitempty" description:"Identifiers of the instances in the cluster"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DatabaseClusterList is a list of database clusters
+type DatabaseClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []DatabaseCluster `json:"items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DatabaseSnapshot is a snapshot of a Database, with a schedule it takes a snapshot on every run instead
+type DatabaseSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              DatabaseSnapshotSpec   `json:"spec"`
+	Status            DatabaseSnapshotStatus `json:"status,omitempty"`
+}
+
+// Deletion policies of a DatabaseSnapshot
+const (
+	SnapshotDelete = "Delete"
+	SnapshotRetain = "Retain"
+)
+
+// DatabaseSnapshotSpec selects the database to snapshot and what happens to the snapshot when the object is deleted
+type DatabaseSnapshotSpec struct {
+	Database       string            `json:"database" description:"Name of the Database in the same namespace to snapshot" minLength:"1"`
+	DeletionPolicy string            `json:"deletionPolicy,omitempty" description:"Delete removes the snapshot at the provider together with the object, Retain keeps it. Defaults to Delete" enum:"Delete,Retain"`
+	Schedule       *SnapshotSchedule `json:"schedule,omitempty" description:"Take snapshots on a schedule, every run creates a DatabaseSnapshot named <name>-<unix time>"`
+}
+
+// SnapshotSchedule is a cron schedule and the number of scheduled snapshots to keep
+type SnapshotSchedule struct {
+	Cron      string `json:"cron" description:"Cron expression in UTC, ex 0 3 * * *" minLength:"1"`
+	Retention int32  `json:"retention,omitempty" description:"Number of scheduled snapshots to keep, older ones are deleted. Defaults to 7" minimum:"1"`
+}
+
+// DatabaseSnapshotStatus is written by the operator through the status subresource
+type DatabaseSnapshotStatus struct {
+	State             string       `json:"state,omitempty" description:"State of the snapshot"`
+	Message           string       `json:"message,omitempty" description:"Detailed message around the state"`
+	LastError         string       `json:"lastError,omitempty" description:"Last error seen while reconciling the snapshot"`
+	Provider          string       `json:"provider,omitempty" description:"Provider holding the snapshot, aws or local"`
+	SnapshotID        string       `json:"snapshotId,omitempty" description:"Identifier of the snapshot at the provider"`
+	Progress          int32        `json:"progress,omitempty" description:"Percentage of the snapshot that is done"`
+	Size              int64        `json:"size,omitempty" description:"Size of the snapshot in Gb"`
+	CreationTime      *metav1.Time `json:"creationTime,omitempty" description:"Time the snapshot was taken"`
+	LastScheduleTime  *metav1.Time `json:"lastScheduleTime,omitempty" description:"Last time a scheduled snapshot was created"`
+	NextScheduleTime  *metav1.Time `json:"nextScheduleTime,omitempty" description:"Next time a scheduled snapshot will be created"`
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty" description:"Last time the snapshot was reconciled"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DatabaseSnapshotList is a list of database snapshots
+type DatabaseSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []DatabaseSnapshot `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProviderConfig is the AWS account, role and network used for the databases and clusters referencing it
+type ProviderConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ProviderConfigSpec `json:"spec"`
+}
+
+// ProviderConfigSpec overrides the AWS configuration of the operator, empty fields keep the value of the operator
+type ProviderConfigSpec struct {
+	Region               string                  `json:"region,omitempty" description:"AWS region of the databases"`
+	RoleARN              string                  `json:"roleArn,omitempty" description:"IAM role assumed through STS for all AWS calls, ex arn:aws:iam::123456789012:role/k8s-rds"`
+	ExternalID           string                  `json:"externalId,omitempty" description:"External ID required by the trust policy of the role"`
+	CredentialsSecretRef *corev1.SecretReference `json:"credentialsSecretRef,omitempty" description:"Secret with the accessKeyId, secretAccessKey and optional sessionToken used instead of the credentials of the operator"`
+	VpcID                string                  `json:"vpcId,omitempty" description:"VPC of the databases, required together with securityGroupIds when the role belongs to another account than the nodes"`
+	Subnets              []string                `json:"subnets,omitempty" description:"Subnets of the shared subnet group of private databases"`
+	PublicSubnets        []string                `json:"publicSubnets,omitempty" description:"Subnets of the shared subnet group of publicly accessible databases"`
+	SecurityGroupIDs     []string                `json:"securityGroupIds,omitempty" description:"Security groups of the workloads allowed to reach the databases"`
+	Tags                 map[string]string       `json:"tags,omitempty" description:"Tags added to the instances and clusters, the tags of a database win"`
+	AllowedNamespaces    []string                `json:"allowedNamespaces,omitempty" description:"Namespaces whose databases may use the ProviderConfig, * allows all namespaces. No namespace may use it if empty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProviderConfigList is a list of provider configs
+type ProviderConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []ProviderConfig `json:"items"`
+}
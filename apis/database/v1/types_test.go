@@ -0,0 +1,28 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeepCopy(t *testing.T) {
+	optional := true
+	db := &Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Finalizers: []string{"a"}},
+		Spec:       DatabaseSpec{Password: PasswordSecret{SecretKeySelector: corev1.SecretKeySelector{Optional: &optional}}},
+		Status:     DatabaseStatus{Conditions: []metav1.Condition{{Type: ConditionReady}}},
+	}
+
+	c := db.DeepCopyObject().(*Database)
+	assert.Equal(t, db, c)
+
+	c.Finalizers[0] = "b"
+	*c.Spec.Password.Optional = false
+	c.Status.Conditions[0].Type = ConditionDegraded
+	assert.Equal(t, "a", db.Finalizers[0])
+	assert.True(t, *db.Spec.Password.Optional)
+	assert.Equal(t, ConditionReady, db.Status.Conditions[0].Type)
+}
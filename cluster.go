@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	databaseclient "github.com/sorenmat/k8s-rds/client/clientset/versioned/typed/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	"github.com/sorenmat/k8s-rds/rds"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// getClusterProvider returns the provider for a database cluster, clusters are Aurora only so they are always
+// created in AWS whatever the default provider of the operator is
+func (c *Controller) getClusterProvider(cluster *databasev1.DatabaseCluster) (provider.ClusterProvider, error) {
+	opts, err := c.awsOptions(context.Background(), c.kubeclient, cluster.Namespace, cluster.Spec.ProviderConfigRef)
+	if err != nil {
+		return nil, err
+	}
+	r, err := rds.NewCluster(context.Background(), cluster, c.kubeclient, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.WaitTimeout = c.opts.awsWaitTimeout
+	r.PollInterval = c.opts.awsPollInterval
+	return r, nil
+}
+
+// readerServiceName is the name of the service pointing at the reader endpoint of the cluster, the writer
+// service has the name of the cluster
+func readerServiceName(cluster *databasev1.DatabaseCluster) string {
+	return cluster.Name + "-reader"
+}
+
+// reconcileCluster brings the cluster at the provider in line with the object, errors are recorded in the status
+func (c *Controller) reconcileCluster(ctx context.Context, cluster *databasev1.DatabaseCluster, clusterclient databaseclient.DatabaseClusterInterface) error {
+	if cluster.DeletionTimestamp != nil {
+		return c.handleDeleteCluster(ctx, cluster, clusterclient)
+	}
+
+	var err error
+	if cluster.Status.State != Available {
+		err = c.handleCreateCluster(ctx, cluster, clusterclient)
+	} else {
+		err = c.handleUpdateCluster(ctx, cluster, clusterclient)
+	}
+	if pending, ok := provider.IsPending(err); ok {
+		serr := updateClusterStatus(ctx, cluster, clusterclient, clusterStatus(setPending(pending)))
+		if serr != nil {
+			log.Printf("database cluster status update failed: %v", serr)
+		}
+		return err
+	}
+	if err != nil {
+		log.Printf("database cluster %v failed: %v", cluster.Name, err)
+		mutate := setUpdateFailed(err)
+		if cluster.Status.State != Available {
+			mutate = setFailed(err)
+		}
+		serr := updateClusterStatus(ctx, cluster, clusterclient, clusterStatus(mutate))
+		if serr != nil {
+			log.Printf("database cluster status update failed: %v", serr)
+		}
+	}
+	return err
+}
+
+func (c *Controller) handleCreateCluster(ctx context.Context, cluster *databasev1.DatabaseCluster, clusterclient databaseclient.DatabaseClusterInterface) error {
+	if !hasFinalizer(cluster) {
+		err := addFinalizer(ctx, cluster, clusterFinalizers(clusterclient))
+		if err != nil {
+			return fmt.Errorf("unable to add finalizer: %v", err)
+		}
+	}
+	if cluster.Status.State == "" || cluster.Status.State == Failed {
+		err := updateClusterStatus(ctx, cluster, clusterclient, clusterStatus(setCreating("aws")))
+		if err != nil {
+			return fmt.Errorf("database cluster status update failed: %v", err)
+		}
+	}
+
+	r, err := c.getClusterProvider(cluster)
+	if err != nil {
+		return err
+	}
+
+	// returns a PendingError until the cluster and its instances are available
+	info, err := r.CreateCluster(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	err = createClusterServices(ctx, r, cluster, info)
+	if err != nil {
+		return err
+	}
+
+	err = updateClusterStatus(ctx, cluster, clusterclient, setClusterAvailable(info))
+	if err != nil {
+		return err
+	}
+	log.Printf("Creation of database cluster %v done\n", cluster.Name)
+	return nil
+}
+
+// createClusterServices creates the services pointing at the writer and reader endpoints
+func createClusterServices(ctx context.Context, r provider.ClusterProvider, cluster *databasev1.DatabaseCluster, info *provider.ClusterInfo) error {
+	port := provider.ClusterServicePort(cluster, info.Port)
+	log.Printf("Creating service '%v' for %v\n", cluster.Name, info.Hostname)
+	err := r.CreateService(ctx, cluster.Namespace, info.Hostname, cluster.Name, port)
+	if err != nil {
+		return err
+	}
+	log.Printf("Creating service '%v' for %v\n", readerServiceName(cluster), info.ReaderHostname)
+	return r.CreateService(ctx, cluster.Namespace, info.ReaderHostname, readerServiceName(cluster), port)
+}
+
+// handleUpdateCluster applies the spec to an already created cluster
+func (c *Controller) handleUpdateCluster(ctx context.Context, cluster *databasev1.DatabaseCluster, clusterclient databaseclient.DatabaseClusterInterface) error {
+	r, err := c.getClusterProvider(cluster)
+	if err != nil {
+		return err
+	}
+
+	err = r.UpdateCluster(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	return updateClusterStatus(ctx, cluster, clusterclient, clusterStatus(setAvailable(nil)))
+}
+
+// handleDeleteCluster removes the cluster and its services, the finalizer is only removed once that succeeded
+func (c *Controller) handleDeleteCluster(ctx context.Context, cluster *databasev1.DatabaseCluster, clusterclient databaseclient.DatabaseClusterInterface) error {
+	if !hasFinalizer(cluster) {
+		return nil
+	}
+	log.Printf("deleting database cluster: %s \n", cluster.Name)
+
+	if cluster.Status.State != Deleting {
+		err := updateClusterStatus(ctx, cluster, clusterclient, clusterStatus(setDeleting("Deleting", nil)))
+		if err != nil {
+			return fmt.Errorf("database cluster status update failed: %v", err)
+		}
+	}
+
+	r, err := c.getClusterProvider(cluster)
+	if err == nil {
+		err = r.DeleteCluster(ctx, cluster)
+	}
+	if err == nil {
+		err = r.DeleteService(ctx, cluster.Namespace, cluster.Name)
+	}
+	if err == nil {
+		err = r.DeleteService(ctx, cluster.Namespace, readerServiceName(cluster))
+	}
+	if pending, ok := provider.IsPending(err); ok {
+		serr := updateClusterStatus(ctx, cluster, clusterclient, clusterStatus(setDeleting(pending.Message, nil)))
+		if serr != nil {
+			log.Printf("database cluster status update failed: %v", serr)
+		}
+		return err
+	}
+	if err != nil {
+		serr := updateClusterStatus(ctx, cluster, clusterclient, clusterStatus(setDeleting("Deletion failed, will retry", err)))
+		if serr != nil {
+			log.Printf("database cluster status update failed: %v", serr)
+		}
+		return err
+	}
+
+	err = removeFinalizer(ctx, cluster, clusterFinalizers(clusterclient))
+	if err != nil {
+		return fmt.Errorf("unable to remove finalizer: %v", err)
+	}
+	log.Printf("Deletion of database cluster %v done\n", cluster.Name)
+	return nil
+}
+
+// updateClusterStatus is updateStatus for database clusters
+func updateClusterStatus(ctx context.Context, cluster *databasev1.DatabaseCluster, clusterclient databaseclient.DatabaseClusterInterface, mutate func(*databasev1.DatabaseClusterStatus, int64)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := clusterclient.Get(ctx, cluster.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		mutate(&latest.Status, latest.Generation)
+		now := metav1.Now()
+		latest.Status.LastReconcileTime = &now
+		_, err = clusterclient.UpdateStatus(ctx, latest, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// clusterStatus applies a database status change to the status of a cluster
+func clusterStatus(mutate func(*databasev1.DatabaseStatus, int64)) func(*databasev1.DatabaseClusterStatus, int64) {
+	return func(s *databasev1.DatabaseClusterStatus, generation int64) {
+		mutate(&s.DatabaseStatus, generation)
+	}
+}
+
+// setClusterAvailable records the cluster as reported by the provider
+func setClusterAvailable(info *provider.ClusterInfo) func(*databasev1.DatabaseClusterStatus, int64) {
+	return func(s *databasev1.DatabaseClusterStatus, generation int64) {
+		setAvailable(&info.DatabaseInfo)(&s.DatabaseStatus, generation)
+		s.ReaderEndpoint = info.ReaderHostname
+		s.Members = info.Members
+	}
+}
+
+func clusterFinalizers(clusterclient databaseclient.DatabaseClusterInterface) finalizerClient {
+	return finalizerClient{
+		get: func(ctx context.Context, name string) (metav1.Object, error) {
+			return clusterclient.Get(ctx, name, metav1.GetOptions{})
+		},
+		patch: func(ctx context.Context, name string, patch []byte) error {
+			_, err := clusterclient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/client/clientset/versioned/fake"
+	"github.com/sorenmat/k8s-rds/provider"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUpdateClusterStatus(t *testing.T) {
+	cluster := &databasev1.DatabaseCluster{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "default"}}
+	clientset := fake.NewSimpleClientset(cluster)
+	clusterclient := clientset.DatabaseV1().DatabaseClusters("default")
+
+	err := updateClusterStatus(context.Background(), cluster, clusterclient, clusterStatus(setCreating("aws")))
+	assert.NoError(t, err)
+	info := &provider.ClusterInfo{
+		DatabaseInfo:   provider.DatabaseInfo{Hostname: "mycluster.cluster-abc.rds.amazonaws.com", Port: 5432},
+		ReaderHostname: "mycluster.cluster-ro-abc.rds.amazonaws.com",
+		Members:        []string{"mycluster-default-0", "mycluster-default-1"},
+	}
+	err = updateClusterStatus(context.Background(), cluster, clusterclient, setClusterAvailable(info))
+	assert.NoError(t, err)
+	assert.Empty(t, cluster.Status.State)
+
+	updated, err := clusterclient.Get(context.Background(), "mycluster", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, Available, updated.Status.State)
+	assert.Equal(t, "mycluster.cluster-abc.rds.amazonaws.com", updated.Status.Endpoint)
+	assert.Equal(t, "mycluster.cluster-ro-abc.rds.amazonaws.com", updated.Status.ReaderEndpoint)
+	assert.Len(t, updated.Status.Members, 2)
+	assert.True(t, meta.IsStatusConditionTrue(updated.Status.Conditions, databasev1.ConditionReady))
+}
+
+func TestClusterFinalizer(t *testing.T) {
+	cluster := &databasev1.DatabaseCluster{ObjectMeta: metav1.ObjectMeta{Name: "mycluster", Namespace: "default"}}
+	clientset := fake.NewSimpleClientset(cluster)
+	clusterclient := clientset.DatabaseV1().DatabaseClusters("default")
+
+	assert.NoError(t, addFinalizer(context.Background(), cluster, clusterFinalizers(clusterclient)))
+	updated, err := clusterclient.Get(context.Background(), "mycluster", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, hasFinalizer(updated))
+
+	assert.NoError(t, removeFinalizer(context.Background(), updated, clusterFinalizers(clusterclient)))
+	updated, err = clusterclient.Get(context.Background(), "mycluster", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.False(t, hasFinalizer(updated))
+	for _, action := range clientset.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+	}
+}
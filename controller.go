@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/client/clientset/versioned"
+	"github.com/sorenmat/k8s-rds/client/informers/externalversions"
+	databaselisters "github.com/sorenmat/k8s-rds/client/listers/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// passwordSecretIndex indexes the databases by the namespace/name of their password secret
+const passwordSecretIndex = "passwordSecret"
+
+// Controller watches databases and reconciles them from a rate limited work queue keyed by namespace/name.
+// Failed reconciles are retried with a per item exponential backoff.
+type Controller struct {
+	clientset     versioned.Interface
+	kubeclient    kubernetes.Interface
+	opts          options
+	queue         workqueue.RateLimitingInterface
+	factory       externalversions.SharedInformerFactory
+	kubeFactory   informers.SharedInformerFactory
+	lister        databaselisters.DatabaseLister
+	indexer       cache.Indexer
+	synced        cache.InformerSynced
+	secretsSynced cache.InformerSynced
+	// clusters have their own queue, keyed by namespace/name as well
+	clusterQueue  workqueue.RateLimitingInterface
+	clusterLister databaselisters.DatabaseClusterLister
+	clusterSynced cache.InformerSynced
+	// snapshots have their own queue too
+	snapshotQueue  workqueue.RateLimitingInterface
+	snapshotLister databaselisters.DatabaseSnapshotLister
+	snapshotSynced cache.InformerSynced
+	// ProviderConfigs are only read from the cache, changes to them are picked up by the next reconcile
+	providerConfigLister databaselisters.ProviderConfigLister
+	providerConfigSynced cache.InformerSynced
+	// deleted holds the last state of the databases deleted before they got the finalizer, the workers clean them up
+	deletedMu sync.Mutex
+	deleted   map[string]*databasev1.Database
+}
+
+// NewController creates a controller and sets up the informers feeding the work queue, changes to the
+// password secret of a database enqueue the database as well
+func NewController(clientset versioned.Interface, kubeclient kubernetes.Interface, opts options) *Controller {
+	c := &Controller{
+		clientset:     clientset,
+		kubeclient:    kubeclient,
+		opts:          opts,
+		queue:         workqueue.NewNamedRateLimitingQueue(newRateLimiter(opts.retryBaseDelay, opts.retryMaxDelay), "databases"),
+		clusterQueue:  workqueue.NewNamedRateLimitingQueue(newRateLimiter(opts.retryBaseDelay, opts.retryMaxDelay), "databaseclusters"),
+		snapshotQueue: workqueue.NewNamedRateLimitingQueue(newRateLimiter(opts.retryBaseDelay, opts.retryMaxDelay), "databasesnapshots"),
+		factory:       externalversions.NewSharedInformerFactory(clientset, opts.resyncPeriod),
+		kubeFactory:   informers.NewSharedInformerFactory(kubeclient, opts.resyncPeriod),
+		deleted:       map[string]*databasev1.Database{},
+	}
+
+	informer := c.factory.Database().V1().Databases()
+	c.lister = informer.Lister()
+	c.synced = informer.Informer().HasSynced
+	err := informer.Informer().AddIndexers(cache.Indexers{passwordSecretIndex: passwordSecretKey})
+	if err != nil {
+		// only fails if the informer is already running or the index exists
+		panic(err)
+	}
+	c.indexer = informer.Informer().GetIndexer()
+	informer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.enqueue(obj)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if shouldEnqueue(oldObj.(*databasev1.Database), newObj.(*databasev1.Database)) {
+					c.enqueue(newObj)
+				}
+			},
+			DeleteFunc: c.handleDelete,
+		},
+	)
+
+	secrets := c.kubeFactory.Core().V1().Secrets().Informer()
+	c.secretsSynced = secrets.HasSynced
+	secrets.AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.enqueueForSecret,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if !reflect.DeepEqual(oldObj.(*corev1.Secret).Data, newObj.(*corev1.Secret).Data) {
+					c.enqueueForSecret(newObj)
+				}
+			},
+		},
+	)
+
+	clusters := c.factory.Database().V1().DatabaseClusters()
+	c.clusterLister = clusters.Lister()
+	c.clusterSynced = clusters.Informer().HasSynced
+	clusters.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.enqueueCluster,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldCluster, cluster := oldObj.(*databasev1.DatabaseCluster), newObj.(*databasev1.DatabaseCluster)
+				if oldCluster.ResourceVersion == cluster.ResourceVersion || oldCluster.Generation != cluster.Generation ||
+					(oldCluster.DeletionTimestamp == nil && cluster.DeletionTimestamp != nil) {
+					c.enqueueCluster(newObj)
+				}
+			},
+		},
+	)
+
+	providerConfigs := c.factory.Database().V1().ProviderConfigs()
+	c.providerConfigLister = providerConfigs.Lister()
+	c.providerConfigSynced = providerConfigs.Informer().HasSynced
+
+	snapshots := c.factory.Database().V1().DatabaseSnapshots()
+	c.snapshotLister = snapshots.Lister()
+	c.snapshotSynced = snapshots.Informer().HasSynced
+	snapshots.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: c.enqueueSnapshot,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldSnapshot, snapshot := oldObj.(*databasev1.DatabaseSnapshot), newObj.(*databasev1.DatabaseSnapshot)
+				if oldSnapshot.ResourceVersion == snapshot.ResourceVersion || oldSnapshot.Generation != snapshot.Generation ||
+					(oldSnapshot.DeletionTimestamp == nil && snapshot.DeletionTimestamp != nil) {
+					c.enqueueSnapshot(newObj)
+				}
+			},
+		},
+	)
+	return c
+}
+
+// passwordSecretKey is the index function for passwordSecretIndex
+func passwordSecretKey(obj interface{}) ([]string, error) {
+	db, ok := obj.(*databasev1.Database)
+	if !ok || db.Spec.Password.Name == "" {
+		return nil, nil
+	}
+	return []string{db.Namespace + "/" + db.Spec.Password.Name}, nil
+}
+
+// enqueueForSecret enqueues the databases using the secret as their password
+func (c *Controller) enqueueForSecret(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	dbs, err := c.indexer.ByIndex(passwordSecretIndex, key)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, db := range dbs {
+		c.enqueue(db)
+	}
+}
+
+// newRateLimiter returns a rate limiter with a per item exponential backoff and an overall limit
+// to avoid hammering the provider API when a lot of databases fails at once
+func newRateLimiter(baseDelay, maxDelay time.Duration) workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
+
+// shouldEnqueue filters out the updates caused by our own status changes,
+// spec changes, deletions and resyncs are reconciled
+func shouldEnqueue(oldDB, db *databasev1.Database) bool {
+	if oldDB.ResourceVersion == db.ResourceVersion {
+		// periodic resync
+		return true
+	}
+	if oldDB.DeletionTimestamp == nil && db.DeletionTimestamp != nil {
+		return true
+	}
+	return oldDB.Generation != db.Generation || specChanged(oldDB, db)
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	db := obj.(*databasev1.Database)
+	if excluded(db, c.opts.excludeNamespaces, c.opts.includeNamespaces) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+func (c *Controller) enqueueCluster(obj interface{}) {
+	cluster := obj.(*databasev1.DatabaseCluster)
+	if excluded(cluster, c.opts.excludeNamespaces, c.opts.includeNamespaces) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.clusterQueue.Add(key)
+}
+
+func (c *Controller) enqueueSnapshot(obj interface{}) {
+	snapshot := obj.(*databasev1.DatabaseSnapshot)
+	if excluded(snapshot, c.opts.excludeNamespaces, c.opts.includeNamespaces) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.snapshotQueue.Add(key)
+}
+
+// handleDelete only does work if the database disappeared before we got to add the finalizer, otherwise the cleanup
+// was already done before the finalizer was removed. The database is kept until a worker has cleaned it up, so the
+// provider is only called by the leader and failures are retried with backoff like any other reconcile
+func (c *Controller) handleDelete(obj interface{}) {
+	db, ok := obj.(*databasev1.Database)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if db, ok = tombstone.Obj.(*databasev1.Database); !ok {
+			return
+		}
+	}
+	if excluded(db, c.opts.excludeNamespaces, c.opts.includeNamespaces) {
+		return
+	}
+	if hasFinalizer(db) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(db)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.deletedMu.Lock()
+	c.deleted[key] = db
+	c.deletedMu.Unlock()
+	c.queue.Add(key)
+}
+
+// deletedDatabase returns the database deleted without the finalizer under key, or nil
+func (c *Controller) deletedDatabase(key string) *databasev1.Database {
+	c.deletedMu.Lock()
+	defer c.deletedMu.Unlock()
+	return c.deleted[key]
+}
+
+func (c *Controller) forgetDeleted(key string) {
+	c.deletedMu.Lock()
+	defer c.deletedMu.Unlock()
+	delete(c.deleted, key)
+}
+
+// cleanupDeleted deletes a database that was deleted without the finalizer at the provider
+func (c *Controller) cleanupDeleted(key string, db *databasev1.Database) error {
+	log.Printf("deleting database without finalizer: %s \n", db.Name)
+	r, err := c.getProvider(db)
+	if err != nil {
+		return err
+	}
+	err = deleteDatabase(context.Background(), r, db)
+	if err != nil {
+		return err
+	}
+	c.forgetDeleted(key)
+	log.Printf("Deletion of database %v done\n", db.Name)
+	return nil
+}
+
+// StartInformer starts filling the cache and the queue, standby replicas do this as well
+// so they are ready to take over as soon as they become leader. The event handlers only
+// queue work, providers are only called by the workers started in Run
+func (c *Controller) StartInformer(stopCh <-chan struct{}) {
+	c.factory.Start(stopCh)
+	c.kubeFactory.Start(stopCh)
+}
+
+// Run waits for the cache to be synced and starts the workers, it blocks until stopCh is closed
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+	defer c.clusterQueue.ShutDown()
+	defer c.snapshotQueue.ShutDown()
+
+	if !cache.WaitForCacheSync(stopCh, c.synced, c.secretsSynced, c.clusterSynced, c.snapshotSynced, c.providerConfigSynced) {
+		utilruntime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
+		return
+	}
+
+	log.Printf("Starting %d workers\n", workers)
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+		go wait.Until(c.runClusterWorker, time.Second, stopCh)
+		go wait.Until(c.runSnapshotWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	log.Println("Stopping workers")
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem(c.queue, c.sync) {
+	}
+}
+
+func (c *Controller) runClusterWorker() {
+	for c.processNextItem(c.clusterQueue, c.syncCluster) {
+	}
+}
+
+func (c *Controller) runSnapshotWorker() {
+	for c.processNextItem(c.snapshotQueue, c.syncSnapshot) {
+	}
+}
+
+func (c *Controller) processNextItem(queue workqueue.RateLimitingInterface, sync func(string) error) bool {
+	key, quit := queue.Get()
+	if quit {
+		return false
+	}
+	defer queue.Done(key)
+
+	err := sync(key.(string))
+	c.handleErr(queue, err, key)
+	return true
+}
+
+// handleErr requeues the key with backoff on failures, and after the requested delay when the provider is still working
+func (c *Controller) handleErr(queue workqueue.RateLimitingInterface, err error, key interface{}) {
+	if err == nil {
+		queue.Forget(key)
+		return
+	}
+
+	if pending, ok := provider.IsPending(err); ok {
+		queue.Forget(key)
+		after := pending.RequeueAfter
+		if after == 0 {
+			after = c.opts.requeueAfter
+		}
+		log.Printf("%v is %v, checking again in %v\n", key, pending.State, after)
+		queue.AddAfter(key, after)
+		return
+	}
+
+	log.Printf("error reconciling %v (retry %d): %v\n", key, queue.NumRequeues(key), err)
+	queue.AddRateLimited(key)
+}
+
+func (c *Controller) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	cached, err := c.lister.Databases(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		if db := c.deletedDatabase(key); db != nil {
+			return c.cleanupDeleted(key, db)
+		}
+		// the database is gone, the finalizer made sure it was cleaned up
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// a database created again under the same name is reconciled instead, it uses the same resources
+	c.forgetDeleted(key)
+	// objects in the informer cache are shared and must not be modified
+	db := cached.DeepCopy()
+	crdclient := c.clientset.DatabaseV1().Databases(db.Namespace)
+	return c.reconcileDatabase(context.Background(), db, crdclient)
+}
+
+func (c *Controller) syncCluster(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	cached, err := c.clusterLister.DatabaseClusters(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cluster := cached.DeepCopy()
+	clusterclient := c.clientset.DatabaseV1().DatabaseClusters(cluster.Namespace)
+	return c.reconcileCluster(context.Background(), cluster, clusterclient)
+}
+
+func (c *Controller) syncSnapshot(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	cached, err := c.snapshotLister.DatabaseSnapshots(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	snapshot := cached.DeepCopy()
+	snapshotclient := c.clientset.DatabaseV1().DatabaseSnapshots(snapshot.Namespace)
+	return c.reconcileSnapshot(context.Background(), snapshot, snapshotclient)
+}
@@ -2,230 +2,274 @@ package crd
 
 import (
 	"context"
+	"log"
+	"reflect"
 
-	v1 "k8s.io/api/core/v1"
-	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextcs "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
-	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 )
 
 const (
 	CRDPlural          string = "databases"
-	CRDGroup           string = "k8s.io"
+	CRDGroup           string = databasev1.GroupName
 	CRDVersion         string = "v1"
 	FullCRDName        string = "databases." + CRDGroup
 	StorageTypePattern string = `gp2|io1`
 	DBNamePattern      string = "^[A-Za-z]\\w+$"
 	DBUsernamePattern  string = "^[A-Za-z]\\w+$"
-)
+	// Finalizer is set on every database so the provider resources are removed before the object is gone
+	Finalizer string = FullCRDName + "/cleanup"
 
-func intptr(x int64) *int64 {
-	return &x
-}
+	ClusterCRDPlural   string = "databaseclusters"
+	FullClusterCRDName string = ClusterCRDPlural + "." + CRDGroup
 
-func floatptr(x float64) *float64 {
-	return &x
-}
+	SnapshotCRDPlural   string = "databasesnapshots"
+	FullSnapshotCRDName string = SnapshotCRDPlural + "." + CRDGroup
+
+	ProviderConfigCRDPlural   string = "providerconfigs"
+	FullProviderConfigCRDName string = ProviderConfigCRDPlural + "." + CRDGroup
+)
 
-func NewDatabaseCRD() *apiextv1beta1.CustomResourceDefinition {
-	return &apiextv1beta1.CustomResourceDefinition{
-		ObjectMeta: meta_v1.ObjectMeta{Name: FullCRDName},
-		Spec: apiextv1beta1.CustomResourceDefinitionSpec{
-			Group:   CRDGroup,
-			Version: CRDVersion,
-			Scope:   apiextv1beta1.NamespaceScoped,
-			Names: apiextv1beta1.CustomResourceDefinitionNames{
-				Plural: "databases",
-				Kind:   "Database",
+// NewDatabaseCRD returns the apiextensions.k8s.io/v1 definition of the databases resource
+func NewDatabaseCRD() *apiextv1.CustomResourceDefinition {
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: FullCRDName,
+			// k8s.io is a protected group, v1 CRDs in it have to state that they are not approved by the API reviewers
+			Annotations: map[string]string{"api-approved.kubernetes.io": "unapproved, experimental-only"},
+		},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: CRDGroup,
+			Scope: apiextv1.NamespaceScoped,
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Plural:     CRDPlural,
+				Singular:   "database",
+				Kind:       "Database",
+				ListKind:   "DatabaseList",
+				ShortNames: []string{"db", "dbs"},
 			},
-			Validation: &apiextv1beta1.CustomResourceValidation{
-				OpenAPIV3Schema: &apiextv1beta1.JSONSchemaProps{
-					Type: "object",
-					Properties: map[string]apiextv1beta1.JSONSchemaProps{
-						"spec": {
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{
+					Name:    CRDVersion,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextv1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiextv1.JSONSchemaProps{
 							Type: "object",
-							Properties: map[string]apiextv1beta1.JSONSchemaProps{
-								"username": {
-									Type:        "string",
-									Description: "User Name to access the database",
-									MinLength:   intptr(1),
-									MaxLength:   intptr(16),
-									Pattern:     DBUsernamePattern,
-								},
-								"dbname": {
-									Type:        "string",
-									Description: "Database name",
-									MinLength:   intptr(1),
-									MaxLength:   intptr(63),
-									Pattern:     DBNamePattern,
-								},
-								"engine": {
-									Type:        "string",
-									Description: "database engine. Ex: postgres, mysql, aurora-postgresql, etc",
-								},
-								"version": {
-									Type:        "string",
-									Description: "database engine version. ex 5.1.49",
-								},
-								"class": {
-									Type:        "string",
-									Description: "instance class name. Ex: db.m5.24xlarge or db.m3.medium",
-								},
-								"size": {
-									Type:        "integer",
-									Description: "Database size in Gb",
-									Minimum:     floatptr(20),
-									Maximum:     floatptr(64000),
-								},
-								"MaxAllocatedSize": {
-									Type:        "integer",
-									Description: "Database size in Gb",
-									Minimum:     floatptr(20),
-									Maximum:     floatptr(64000),
-								},
-								"multiaz": {
-									Type:        "boolean",
-									Description: "should it be available in multiple regions?",
-								},
-								"publiclyaccessible": {
-									Type:        "boolean",
-									Description: "is the database publicly accessible?",
-								},
-								"storageencrypted": {
-									Type:        "boolean",
-									Description: "should the storage be encrypted?",
-								},
-								"storagetype": {
-									Type:        "string",
-									Description: "gp2 (General Purpose SSD) or io1 (Provisioned IOPS SSD)",
-									Pattern:     StorageTypePattern,
-								},
-								"iops": {
-									Type:        "integer",
-									Description: "I/O operations per second",
-									Minimum:     floatptr(1000),
-									Maximum:     floatptr(80000),
-								},
-								"backupretentionperiod": {
-									Type:        "integer",
-									Description: "Retention period in days. 0 means disabled, 7 is the default and 35 is the maximum",
-									Minimum:     floatptr(0),
-									Maximum:     floatptr(35),
-								},
-								"deleteprotection": {
-									Type:        "boolean",
-									Description: "Enable or disable deletion protection",
-								},
-								"tags": {
-									Type:        "string",
-									Description: "Tags to create on the database instance format key=value,key1=value1",
-								},
-								"skipfinalsnapshot": {
-									Type:        "boolean",
-									Description: "Indicates whether to skip the creation of a final DB snapshot before deleting the instance. By default, skipfinalsnapshot isn't enabled, and the DB snapshot is created.",
-								},
+							Properties: map[string]apiextv1.JSONSchemaProps{
+								"spec":   schemaFor(reflect.TypeOf(databasev1.DatabaseSpec{})),
+								"status": schemaFor(reflect.TypeOf(databasev1.DatabaseStatus{})),
 							},
 						},
 					},
+					Subresources: &apiextv1.CustomResourceSubresources{
+						Status: &apiextv1.CustomResourceSubresourceStatus{},
+					},
+					AdditionalPrinterColumns: []apiextv1.CustomResourceColumnDefinition{
+						{Name: "Engine", Type: "string", JSONPath: ".spec.engine"},
+						{Name: "Class", Type: "string", JSONPath: ".spec.class"},
+						{Name: "State", Type: "string", JSONPath: ".status.state"},
+						{Name: "Endpoint", Type: "string", JSONPath: ".status.endpoint"},
+						{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+					},
 				},
 			},
 		},
 	}
 }
 
-// CreateCRD creates the CRD resource, ignore error if it already exists
-func CreateCRD(clientset apiextcs.Interface) error {
-	ctx := context.Background()
-	crd := NewDatabaseCRD()
-	_, err := clientset.ApiextensionsV1beta1().CustomResourceDefinitions().Create(ctx, crd, meta_v1.CreateOptions{})
-	if err != nil && apierrors.IsAlreadyExists(err) {
-		return nil
+// NewDatabaseClusterCRD returns the apiextensions.k8s.io/v1 definition of the databaseclusters resource
+func NewDatabaseClusterCRD() *apiextv1.CustomResourceDefinition {
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        FullClusterCRDName,
+			Annotations: map[string]string{"api-approved.kubernetes.io": "unapproved, experimental-only"},
+		},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: CRDGroup,
+			Scope: apiextv1.NamespaceScoped,
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Plural:     ClusterCRDPlural,
+				Singular:   "databasecluster",
+				Kind:       "DatabaseCluster",
+				ListKind:   "DatabaseClusterList",
+				ShortNames: []string{"dbc"},
+			},
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{
+					Name:    CRDVersion,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextv1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiextv1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]apiextv1.JSONSchemaProps{
+								"spec":   schemaFor(reflect.TypeOf(databasev1.DatabaseClusterSpec{})),
+								"status": schemaFor(reflect.TypeOf(databasev1.DatabaseClusterStatus{})),
+							},
+						},
+					},
+					Subresources: &apiextv1.CustomResourceSubresources{
+						Status: &apiextv1.CustomResourceSubresourceStatus{},
+					},
+					AdditionalPrinterColumns: []apiextv1.CustomResourceColumnDefinition{
+						{Name: "Engine", Type: "string", JSONPath: ".spec.engine"},
+						{Name: "Instances", Type: "integer", JSONPath: ".spec.instances"},
+						{Name: "State", Type: "string", JSONPath: ".status.state"},
+						{Name: "Endpoint", Type: "string", JSONPath: ".status.endpoint"},
+						{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+					},
+				},
+			},
+		},
 	}
-	return err
-}
-
-// Database is the definition of our CRD Database
-type Database struct {
-	meta_v1.TypeMeta   `json:",inline"`
-	meta_v1.ObjectMeta `json:"metadata"`
-	Spec               DatabaseSpec   `json:"spec"`
-	Status             DatabaseStatus `json:"status,omitempty"`
-}
-
-// DatabaseSpec main structure describing the database instance
-type DatabaseSpec struct {
-	Username              string               `json:"username"`
-	Password              v1.SecretKeySelector `json:"password"`
-	DBName                string               `json:"dbname"`
-	Engine                string               `json:"engine"`           // "postgres"
-	Version               string               `json:"version"`          // version of the engine / database
-	Class                 string               `json:"class"`            // like "db.t2.micro"
-	Size                  int64                `json:"size"`             // size in gb
-	MaxAllocatedSize      int64                `json:"MaxAllocatedSize"` // max_allocated_storage size in gb, the maximum allowed storage size for the database when using autoscaling. Has to be larger then size
-	MultiAZ               bool                 `json:"multiaz,omitempty"`
-	PubliclyAccessible    bool                 `json:"publicaccess,omitempty"`
-	StorageEncrypted      bool                 `json:"encrypted,omitempty"`
-	StorageType           string               `json:"storagetype,omitempty"`
-	Iops                  int64                `json:"iops,omitempty"`
-	BackupRetentionPeriod int64                `json:"backupretentionperiod,omitempty"` // between 0 and 35, zero means disable
-	DeleteProtection      bool                 `json:"deleteprotection,omitempty"`
-	Tags                  string               `json:"tags,omitempty"`     // key=value,key1=value1
-	Provider              string               `json:"provider,omitempty"` // local or aws
-	SkipFinalSnapshot     bool                 `json:"skipfinalsnapshot,omitempty"`
-}
-
-type DatabaseStatus struct {
-	State   string `json:"state,omitempty" description:"State of the deploy"`
-	Message string `json:"message,omitempty" description:"Detailed message around the state"`
-}
-
-type DatabaseList struct {
-	meta_v1.TypeMeta `json:",inline"`
-	meta_v1.ListMeta `json:"metadata"`
-	Items            []Database `json:"items"`
 }
 
-func (d *Database) DeepCopyObject() runtime.Object {
-	return d
+// NewDatabaseSnapshotCRD returns the apiextensions.k8s.io/v1 definition of the databasesnapshots resource
+func NewDatabaseSnapshotCRD() *apiextv1.CustomResourceDefinition {
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        FullSnapshotCRDName,
+			Annotations: map[string]string{"api-approved.kubernetes.io": "unapproved, experimental-only"},
+		},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: CRDGroup,
+			Scope: apiextv1.NamespaceScoped,
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Plural:     SnapshotCRDPlural,
+				Singular:   "databasesnapshot",
+				Kind:       "DatabaseSnapshot",
+				ListKind:   "DatabaseSnapshotList",
+				ShortNames: []string{"dbsnap"},
+			},
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{
+					Name:    CRDVersion,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextv1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiextv1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]apiextv1.JSONSchemaProps{
+								"spec":   schemaFor(reflect.TypeOf(databasev1.DatabaseSnapshotSpec{})),
+								"status": schemaFor(reflect.TypeOf(databasev1.DatabaseSnapshotStatus{})),
+							},
+						},
+					},
+					Subresources: &apiextv1.CustomResourceSubresources{
+						Status: &apiextv1.CustomResourceSubresourceStatus{},
+					},
+					AdditionalPrinterColumns: []apiextv1.CustomResourceColumnDefinition{
+						{Name: "Database", Type: "string", JSONPath: ".spec.database"},
+						{Name: "Schedule", Type: "string", JSONPath: ".spec.schedule.cron"},
+						{Name: "State", Type: "string", JSONPath: ".status.state"},
+						{Name: "Progress", Type: "integer", JSONPath: ".status.progress"},
+						{Name: "Snapshot", Type: "string", JSONPath: ".status.snapshotId"},
+						{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+					},
+				},
+			},
+		},
+	}
 }
 
-func (d *DatabaseList) DeepCopyObject() runtime.Object {
-	return d
+// NewProviderConfigCRD returns the definition of the cluster scoped providerconfigs resource
+func NewProviderConfigCRD() *apiextv1.CustomResourceDefinition {
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        FullProviderConfigCRDName,
+			Annotations: map[string]string{"api-approved.kubernetes.io": "unapproved, experimental-only"},
+		},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: CRDGroup,
+			Scope: apiextv1.ClusterScoped,
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Plural:   ProviderConfigCRDPlural,
+				Singular: "providerconfig",
+				Kind:     "ProviderConfig",
+				ListKind: "ProviderConfigList",
+			},
+			Versions: []apiextv1.CustomResourceDefinitionVersion{
+				{
+					Name:    CRDVersion,
+					Served:  true,
+					Storage: true,
+					Schema: &apiextv1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiextv1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]apiextv1.JSONSchemaProps{
+								"spec": schemaFor(reflect.TypeOf(databasev1.ProviderConfigSpec{})),
+							},
+						},
+					},
+					AdditionalPrinterColumns: []apiextv1.CustomResourceColumnDefinition{
+						{Name: "Region", Type: "string", JSONPath: ".spec.region"},
+						{Name: "Role", Type: "string", JSONPath: ".spec.roleArn"},
+						{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+					},
+				},
+			},
+		},
+	}
 }
 
-var SchemeGroupVersion = schema.GroupVersion{Group: CRDGroup, Version: CRDVersion}
-
-func addKnownTypes(scheme *runtime.Scheme) error {
-	scheme.AddKnownTypes(SchemeGroupVersion,
-		&Database{},
-		&DatabaseList{},
-	)
-	meta_v1.AddToGroupVersion(scheme, SchemeGroupVersion)
+// CreateCRD creates the CRD resources, an existing CRD is updated if its definition differs from ours
+func CreateCRD(clientset apiextcs.Interface) error {
+	for _, crd := range []*apiextv1.CustomResourceDefinition{NewDatabaseCRD(), NewDatabaseClusterCRD(), NewDatabaseSnapshotCRD(), NewProviderConfigCRD()} {
+		err := createOrUpdateCRD(clientset, crd)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// NewClient Creates a Rest client with the new CRD Schema
-func NewClient(cfg *rest.Config) (*rest.RESTClient, *runtime.Scheme, error) {
-	scheme := runtime.NewScheme()
-	SchemeBuilder := runtime.NewSchemeBuilder(addKnownTypes)
-	if err := SchemeBuilder.AddToScheme(scheme); err != nil {
-		return nil, nil, err
+func createOrUpdateCRD(clientset apiextcs.Interface, crd *apiextv1.CustomResourceDefinition) error {
+	ctx := context.Background()
+	crds := clientset.ApiextensionsV1().CustomResourceDefinitions()
+	_, err := crds.Create(ctx, crd, meta_v1.CreateOptions{})
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err
 	}
-	config := *cfg
-	config.GroupVersion = &SchemeGroupVersion
-	config.APIPath = "/apis"
-	config.ContentType = runtime.ContentTypeJSON
-	config.NegotiatedSerializer = serializer.WithoutConversionCodecFactory{
-		CodecFactory: serializer.NewCodecFactory(scheme)}
 
-	client, err := rest.RESTClientFor(&config)
-	if err != nil {
-		return nil, nil, err
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := crds.Get(ctx, crd.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !crdChanged(existing, crd) {
+			return nil
+		}
+		log.Printf("updating CRD %v to the current definition\n", crd.Name)
+		existing.Spec.Group = crd.Spec.Group
+		existing.Spec.Scope = crd.Spec.Scope
+		existing.Spec.Names = crd.Spec.Names
+		existing.Spec.Versions = crd.Spec.Versions
+		if existing.Annotations == nil {
+			existing.Annotations = map[string]string{}
+		}
+		for k, v := range crd.Annotations {
+			existing.Annotations[k] = v
+		}
+		_, err = crds.Update(ctx, existing, meta_v1.UpdateOptions{})
+		return err
+	})
+}
+
+// crdChanged compares the parts of the CRD we own, the API server fills in defaults for the rest
+func crdChanged(existing, crd *apiextv1.CustomResourceDefinition) bool {
+	for k, v := range crd.Annotations {
+		if existing.Annotations[k] != v {
+			return true
+		}
 	}
-	return client, scheme, nil
+	return existing.Spec.Group != crd.Spec.Group ||
+		existing.Spec.Scope != crd.Spec.Scope ||
+		!equality.Semantic.DeepEqual(existing.Spec.Names, crd.Spec.Names) ||
+		!equality.Semantic.DeepEqual(existing.Spec.Versions, crd.Spec.Versions)
 }
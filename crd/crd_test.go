@@ -1,26 +1,30 @@
 package crd
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"testing"
 
 	"github.com/ghodss/yaml"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/xeipuuv/gojsonschema"
 	v1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestMarshal(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
-		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"}, Spec: DatabaseSpec{BackupRetentionPeriod: 10,
+		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"}, Spec: databasev1.DatabaseSpec{BackupRetentionPeriod: 10,
 			Class:              "db.t2.micro",
 			DBName:             "database_name",
 			Engine:             "postgres",
 			MultiAZ:            true,
-			Password:           v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:           databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible: false,
 			Size:               20,
 			MaxAllocatedSize:   20,
@@ -38,16 +42,16 @@ func TestMarshal(t *testing.T) {
 }
 
 func TestCRDValidationWithValidInput(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 10,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  50,
 			MaxAllocatedSize:      50,
@@ -58,7 +62,7 @@ func TestCRDValidationWithValidInput(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -70,11 +74,11 @@ func TestCaseInsensitiveInput(t *testing.T) {
 	// in the test.yaml, you can see maxallocatedsize instead of MaxAllocatedSize
 	yamlFile, err := ioutil.ReadFile("test.yaml")
 	assert.NoError(t, err)
-	db := Database{}
-	err = yaml.Unmarshal(yamlFile,&db)
+	db := databasev1.Database{}
+	err = yaml.Unmarshal(yamlFile, &db)
 	assert.NoError(t, err)
 	assert.Equal(t, int(db.Spec.MaxAllocatedSize), 200, "they should be equal")
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(db)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -82,19 +86,18 @@ func TestCaseInsensitiveInput(t *testing.T) {
 	assert.True(t, result.Valid(), result.Errors())
 }
 
-
 func TestDatabaseSizeIsTooSmall(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 10,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			Iops:                  1000,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  10,
 			MaxAllocatedSize:      10,
@@ -104,7 +107,7 @@ func TestDatabaseSizeIsTooSmall(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -113,17 +116,17 @@ func TestDatabaseSizeIsTooSmall(t *testing.T) {
 }
 
 func TestDatabaseSizeIsTooBig(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 10,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			Iops:                  1000,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65000,
 			MaxAllocatedSize:      65000,
@@ -133,7 +136,7 @@ func TestDatabaseSizeIsTooBig(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -142,17 +145,17 @@ func TestDatabaseSizeIsTooBig(t *testing.T) {
 }
 
 func TestBackupRetentionPeriodIsTooLong(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 36,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			Iops:                  1000,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65,
 			MaxAllocatedSize:      65,
@@ -162,7 +165,7 @@ func TestBackupRetentionPeriodIsTooLong(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -171,17 +174,17 @@ func TestBackupRetentionPeriodIsTooLong(t *testing.T) {
 }
 
 func TestInvalidDatabaseNameWithDashSeparator(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 30,
 			Class:                 "db.t2.micro",
 			DBName:                "database-name",
 			Engine:                "postgres",
 			Iops:                  1000,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65,
 			MaxAllocatedSize:      65,
@@ -191,7 +194,7 @@ func TestInvalidDatabaseNameWithDashSeparator(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -200,17 +203,17 @@ func TestInvalidDatabaseNameWithDashSeparator(t *testing.T) {
 }
 
 func TestInvalidDatabaseNameStartingWithANumber(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 30,
 			Class:                 "db.t2.micro",
 			DBName:                "1database_name",
 			Engine:                "postgres",
 			Iops:                  1000,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65,
 			MaxAllocatedSize:      65,
@@ -220,7 +223,7 @@ func TestInvalidDatabaseNameStartingWithANumber(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -229,17 +232,17 @@ func TestInvalidDatabaseNameStartingWithANumber(t *testing.T) {
 }
 
 func TestInvalidUsername(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 30,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			Iops:                  1000,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65,
 			MaxAllocatedSize:      65,
@@ -249,7 +252,7 @@ func TestInvalidUsername(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -258,17 +261,17 @@ func TestInvalidUsername(t *testing.T) {
 }
 
 func TestInvalidStorageType(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 30,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			Iops:                  1000,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65,
 			MaxAllocatedSize:      65,
@@ -278,7 +281,7 @@ func TestInvalidStorageType(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -287,17 +290,17 @@ func TestInvalidStorageType(t *testing.T) {
 }
 
 func TestIopsTooSmall(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 30,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			Iops:                  999,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65,
 			MaxAllocatedSize:      65,
@@ -307,7 +310,7 @@ func TestIopsTooSmall(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
@@ -316,17 +319,17 @@ func TestIopsTooSmall(t *testing.T) {
 }
 
 func TestIopsTooBig(t *testing.T) {
-	d := Database{
+	d := databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
 		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
-		Spec: DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			BackupRetentionPeriod: 30,
 			Class:                 "db.t2.micro",
 			DBName:                "database_name",
 			Engine:                "postgres",
 			Iops:                  80001,
 			MultiAZ:               true,
-			Password:              v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}},
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{Key: "key", LocalObjectReference: v1.LocalObjectReference{Name: "DB-Secret"}}},
 			PubliclyAccessible:    false,
 			Size:                  65,
 			MaxAllocatedSize:      65,
@@ -336,10 +339,129 @@ func TestIopsTooBig(t *testing.T) {
 		},
 	}
 
-	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Validation.OpenAPIV3Schema)
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
 	documentLoader := gojsonschema.NewGoLoader(d)
 
 	result, err := gojsonschema.Validate(loader, documentLoader)
 	assert.NoError(t, err)
 	assert.False(t, result.Valid(), result.Errors())
 }
+
+func TestCreateCRDUpdatesExisting(t *testing.T) {
+	old := NewDatabaseCRD()
+	old.Annotations = nil
+	old.Spec.Versions[0].AdditionalPrinterColumns = nil
+	old.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"].Properties["username"] = apiextv1.JSONSchemaProps{Type: "string"}
+	clientset := apiextfake.NewSimpleClientset(old)
+
+	assert.NoError(t, CreateCRD(clientset))
+
+	updated, err := clientset.ApiextensionsV1().CustomResourceDefinitions().Get(context.Background(), FullCRDName, meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.False(t, crdChanged(updated, NewDatabaseCRD()))
+	assert.Len(t, updated.Spec.Versions[0].AdditionalPrinterColumns, 5)
+	assert.Equal(t, "unapproved, experimental-only", updated.Annotations["api-approved.kubernetes.io"])
+}
+
+func TestCreateCRDUnchanged(t *testing.T) {
+	clientset := apiextfake.NewSimpleClientset()
+	assert.NoError(t, CreateCRD(clientset))
+	assert.NoError(t, CreateCRD(clientset))
+
+	for _, action := range clientset.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+	}
+}
+
+func TestCRDValidationWithStatus(t *testing.T) {
+	now := meta_v1.Now()
+	d := databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "my_db", Namespace: "default"},
+		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
+		Spec:       databasev1.DatabaseSpec{Username: "dbuser", DBName: "database_name", Size: 20, MaxAllocatedSize: 20},
+		Status: databasev1.DatabaseStatus{
+			State: "available",
+			Conditions: []meta_v1.Condition{
+				{Type: databasev1.ConditionReady, Status: meta_v1.ConditionTrue, Reason: "Available", Message: "Database is available", LastTransitionTime: now},
+			},
+			Endpoint:          "my_db.abc.eu-west-1.rds.amazonaws.com",
+			Port:              5432,
+			LastReconcileTime: &now,
+		},
+	}
+
+	loader := gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
+	result, err := gojsonschema.Validate(loader, gojsonschema.NewGoLoader(d))
+	assert.NoError(t, err)
+	assert.True(t, result.Valid(), result.Errors())
+}
+
+func TestClusterCRDValidation(t *testing.T) {
+	c := databasev1.DatabaseCluster{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		TypeMeta:   meta_v1.TypeMeta{Kind: "DatabaseCluster", APIVersion: "k8s.io/v1"},
+		Spec: databasev1.DatabaseClusterSpec{
+			Username:     "dbuser",
+			DBName:       "app",
+			Engine:       "aurora-postgresql",
+			Instances:    2,
+			ServerlessV2: &databasev1.ServerlessV2Scaling{MinCapacity: 0.5, MaxCapacity: 8},
+		},
+		Status: databasev1.DatabaseClusterStatus{
+			DatabaseStatus: databasev1.DatabaseStatus{State: "available", Endpoint: "my-cluster.cluster-abc.eu-west-1.rds.amazonaws.com"},
+			ReaderEndpoint: "my-cluster.cluster-ro-abc.eu-west-1.rds.amazonaws.com",
+		},
+	}
+	loader := gojsonschema.NewGoLoader(NewDatabaseClusterCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
+	result, err := gojsonschema.Validate(loader, gojsonschema.NewGoLoader(c))
+	assert.NoError(t, err)
+	assert.True(t, result.Valid(), result.Errors())
+
+	c.Spec.Engine = "postgres"
+	c.Spec.ServerlessV2.MinCapacity = 0.25
+	result, err = gojsonschema.Validate(loader, gojsonschema.NewGoLoader(c))
+	assert.NoError(t, err)
+	assert.Len(t, result.Errors(), 2, result.Errors())
+}
+
+func TestSnapshotCRDValidation(t *testing.T) {
+	s := databasev1.DatabaseSnapshot{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "nightly", Namespace: "default"},
+		TypeMeta:   meta_v1.TypeMeta{Kind: "DatabaseSnapshot", APIVersion: "k8s.io/v1"},
+		Spec: databasev1.DatabaseSnapshotSpec{
+			Database:       "pgsql",
+			DeletionPolicy: databasev1.SnapshotRetain,
+			Schedule:       &databasev1.SnapshotSchedule{Cron: "0 3 * * *", Retention: 7},
+		},
+	}
+	loader := gojsonschema.NewGoLoader(NewDatabaseSnapshotCRD().Spec.Versions[0].Schema.OpenAPIV3Schema)
+	result, err := gojsonschema.Validate(loader, gojsonschema.NewGoLoader(s))
+	assert.NoError(t, err)
+	assert.True(t, result.Valid(), result.Errors())
+
+	s.Spec.DeletionPolicy = "Keep"
+	s.Spec.Schedule.Retention = -1
+	result, err = gojsonschema.Validate(loader, gojsonschema.NewGoLoader(s))
+	assert.NoError(t, err)
+	assert.Len(t, result.Errors(), 2, result.Errors())
+}
+
+func TestProviderConfigCRDValidation(t *testing.T) {
+	p := databasev1.ProviderConfig{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "team-a"},
+		TypeMeta:   meta_v1.TypeMeta{Kind: "ProviderConfig", APIVersion: "k8s.io/v1"},
+		Spec: databasev1.ProviderConfigSpec{
+			Region:               "eu-west-1",
+			RoleARN:              "arn:aws:iam::123456789012:role/k8s-rds",
+			CredentialsSecretRef: &v1.SecretReference{Name: "aws", Namespace: "kube-system"},
+			Tags:                 map[string]string{"team": "a"},
+			AllowedNamespaces:    []string{"team-a"},
+		},
+	}
+	crd := NewProviderConfigCRD()
+	assert.Equal(t, apiextv1.ClusterScoped, crd.Spec.Scope)
+	loader := gojsonschema.NewGoLoader(crd.Spec.Versions[0].Schema.OpenAPIV3Schema)
+	result, err := gojsonschema.Validate(loader, gojsonschema.NewGoLoader(p))
+	assert.NoError(t, err)
+	assert.True(t, result.Valid(), result.Errors())
+}
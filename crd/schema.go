@@ -0,0 +1,135 @@
+package crd
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The OpenAPI schema of the CRD is generated from the Go types so it can't drift from the JSON we read and write.
+// Besides the json tag the following struct tags are used:
+//
+//   description: description of the field
+//   pattern:     regular expression a string has to match
+//   minLength:   minimum length of a string
+//   maxLength:   maximum length of a string
+//   minimum:     minimum value of a number
+//   maximum:     maximum value of a number
+//   enum:        comma separated list of allowed string values
+
+var (
+	timeType     = reflect.TypeOf(meta_v1.Time{})
+	durationType = reflect.TypeOf(meta_v1.Duration{})
+)
+
+// schemaFor returns the OpenAPI schema of the JSON representation of t
+func schemaFor(t reflect.Type) apiextv1.JSONSchemaProps {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return apiextv1.JSONSchemaProps{Type: "string", Format: "date-time"}
+	}
+	if t == durationType {
+		// metav1.Duration is written as a string like 1h30m
+		return apiextv1.JSONSchemaProps{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return apiextv1.JSONSchemaProps{Type: "string"}
+	case reflect.Bool:
+		return apiextv1.JSONSchemaProps{Type: "boolean"}
+	case reflect.Int32, reflect.Uint32:
+		return apiextv1.JSONSchemaProps{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return apiextv1.JSONSchemaProps{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return apiextv1.JSONSchemaProps{Type: "number"}
+	case reflect.Slice:
+		items := schemaFor(t.Elem())
+		return apiextv1.JSONSchemaProps{Type: "array", Items: &apiextv1.JSONSchemaPropsOrArray{Schema: &items}}
+	case reflect.Map:
+		values := schemaFor(t.Elem())
+		return apiextv1.JSONSchemaProps{Type: "object", AdditionalProperties: &apiextv1.JSONSchemaPropsOrBool{Allows: true, Schema: &values}}
+	case reflect.Struct:
+		s := apiextv1.JSONSchemaProps{Type: "object", Properties: map[string]apiextv1.JSONSchemaProps{}}
+		addProperties(&s, t)
+		return s
+	}
+	panic(fmt.Sprintf("no OpenAPI schema for %v", t))
+}
+
+// addProperties adds the fields of t to the object schema s, inlined structs are flattened like encoding/json does
+func addProperties(s *apiextv1.JSONSchemaProps, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, inline := jsonName(f)
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if inline {
+			addProperties(s, f.Type)
+			continue
+		}
+		s.Properties[name] = fieldSchema(f)
+	}
+}
+
+// jsonName returns the name of the field in JSON and whether the field is inlined in its parent
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			return "", true
+		}
+		return f.Name, false
+	}
+	return name, false
+}
+
+// fieldSchema returns the schema of the field with the validation from its struct tags applied
+func fieldSchema(f reflect.StructField) apiextv1.JSONSchemaProps {
+	s := schemaFor(f.Type)
+	s.Description = f.Tag.Get("description")
+	s.Pattern = f.Tag.Get("pattern")
+	s.MinLength = tagInt(f, "minLength")
+	s.MaxLength = tagInt(f, "maxLength")
+	s.Minimum = tagFloat(f, "minimum")
+	s.Maximum = tagFloat(f, "maximum")
+	if enum := f.Tag.Get("enum"); enum != "" {
+		for _, v := range strings.Split(enum, ",") {
+			s.Enum = append(s.Enum, apiextv1.JSON{Raw: []byte(strconv.Quote(v))})
+		}
+	}
+	return s
+}
+
+func tagInt(f reflect.StructField, tag string) *int64 {
+	v, ok := f.Tag.Lookup(tag)
+	if !ok {
+		return nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %v tag on %v: %v", tag, f.Name, err))
+	}
+	return &i
+}
+
+func tagFloat(f reflect.StructField, tag string) *float64 {
+	v, ok := f.Tag.Lookup(tag)
+	if !ok {
+		return nil
+	}
+	x, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %v tag on %v: %v", tag, f.Name, err))
+	}
+	return &x
+}
@@ -0,0 +1,172 @@
+package crd
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func structural(t *testing.T, crd *apiextv1.CustomResourceDefinition) *structuralschema.Structural {
+	internal := &apiextensions.JSONSchemaProps{}
+	err := apiextv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(crd.Spec.Versions[0].Schema.OpenAPIV3Schema, internal, nil)
+	assert.NoError(t, err)
+	s, err := structuralschema.NewStructural(internal)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestSchemaIsStructural(t *testing.T) {
+	errs := structuralschema.ValidateStructural(field.NewPath("openAPIV3Schema"), structural(t, NewDatabaseCRD()))
+	assert.Empty(t, errs)
+	errs = structuralschema.ValidateStructural(field.NewPath("openAPIV3Schema"), structural(t, NewDatabaseClusterCRD()))
+	assert.Empty(t, errs)
+	errs = structuralschema.ValidateStructural(field.NewPath("openAPIV3Schema"), structural(t, NewDatabaseSnapshotCRD()))
+	assert.Empty(t, errs)
+	errs = structuralschema.ValidateStructural(field.NewPath("openAPIV3Schema"), structural(t, NewProviderConfigCRD()))
+	assert.Empty(t, errs)
+}
+
+func TestSpecFieldsInSchema(t *testing.T) {
+	props := NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"].Properties
+	typ := reflect.TypeOf(databasev1.DatabaseSpec{})
+	assert.Len(t, props, typ.NumField())
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _ := jsonName(f)
+		s, ok := props[name]
+		if !assert.True(t, ok, "%v is missing in the schema as %v", f.Name, name) {
+			continue
+		}
+		assert.Equal(t, f.Tag.Get("description"), s.Description, f.Name)
+		assert.Equal(t, f.Tag.Get("pattern"), s.Pattern, f.Name)
+		assert.Equal(t, tagValue(f, "minLength"), intValue(s.MinLength), f.Name)
+		assert.Equal(t, tagValue(f, "maxLength"), intValue(s.MaxLength), f.Name)
+		assert.Equal(t, tagValue(f, "minimum"), floatValue(s.Minimum), f.Name)
+		assert.Equal(t, tagValue(f, "maximum"), floatValue(s.Maximum), f.Name)
+	}
+
+	assert.Equal(t, DBUsernamePattern, props["username"].Pattern)
+	assert.Equal(t, DBNamePattern, props["dbname"].Pattern)
+	assert.Equal(t, StorageTypePattern, props["storagetype"].Pattern)
+	assert.Equal(t, "boolean", props["publicaccess"].Type)
+	assert.Equal(t, "boolean", props["encrypted"].Type)
+	assert.Equal(t, "object", props["password"].Type)
+	assert.Contains(t, props["password"].Properties, "name")
+	assert.Contains(t, props["password"].Properties, "key")
+	assert.Len(t, props["provider"].Enum, 2)
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	optional := true
+	now := meta_v1.Now()
+	d := databasev1.Database{
+		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
+		ObjectMeta: meta_v1.ObjectMeta{Name: "my-db", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Username:              "dbuser",
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "db-secret"}, Key: "password", Optional: &optional}},
+			DBName:                "database_name",
+			Engine:                "postgres",
+			Version:               "13.4",
+			Class:                 "db.t3.micro",
+			Size:                  20,
+			MaxAllocatedSize:      50,
+			MultiAZ:               true,
+			PubliclyAccessible:    true,
+			StorageEncrypted:      true,
+			StorageType:           "gp2",
+			Iops:                  1000,
+			BackupRetentionPeriod: 7,
+			DeleteProtection:      true,
+			Tags:                  "team=a",
+			Provider:              "aws",
+			SkipFinalSnapshot:     true,
+		},
+		Status: databasev1.DatabaseStatus{
+			State:              "available",
+			Message:            "Database is available",
+			LastError:          "boom",
+			Conditions:         []meta_v1.Condition{{Type: databasev1.ConditionReady, Status: meta_v1.ConditionTrue, ObservedGeneration: 1, LastTransitionTime: now, Reason: "Available", Message: "ok"}},
+			ObservedGeneration: 1,
+			Endpoint:           "my-db.rds.amazonaws.com",
+			Port:               5432,
+			Provider:           "aws",
+			ARN:                "arn:aws:rds:eu-west-1:123456789012:db:my-db",
+			ResourceID:         "db-ABC",
+			EngineVersion:      "13.4",
+			LastReconcileTime:  &now,
+		},
+	}
+	b, err := json.Marshal(d)
+	assert.NoError(t, err)
+	var obj map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &obj))
+
+	pruned := pruning.PruneWithOptions(obj, structural(t, NewDatabaseCRD()), true, pruning.PruneOptions{ReturnPruned: true})
+	assert.Empty(t, pruned, "fields would be dropped by the API server")
+
+	b, err = json.Marshal(obj)
+	assert.NoError(t, err)
+	var out databasev1.Database
+	assert.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, d.Spec, out.Spec)
+}
+
+// TestSchemaRoundTripUnset checks that a spec with only the required fields, with every other field at its zero
+// value, is accepted by the schema
+func TestSchemaRoundTripUnset(t *testing.T) {
+	d := databasev1.Database{
+		TypeMeta:   meta_v1.TypeMeta{Kind: "Database", APIVersion: "k8s.io/v1"},
+		ObjectMeta: meta_v1.ObjectMeta{Name: "my-db", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			Username: "dbuser",
+			Password: databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "db-secret"}, Key: "password"}},
+			DBName:   "database_name",
+			Engine:   "postgres",
+			Class:    "db.t3.micro",
+			Size:     20,
+		},
+	}
+	b, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "MaxAllocatedSize")
+
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(NewDatabaseCRD().Spec.Versions[0].Schema.OpenAPIV3Schema), gojsonschema.NewBytesLoader(b))
+	assert.NoError(t, err)
+	assert.True(t, result.Valid(), result.Errors())
+
+	var out databasev1.Database
+	assert.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, d.Spec, out.Spec)
+}
+
+func tagValue(f reflect.StructField, tag string) string {
+	return f.Tag.Get(tag)
+}
+
+func intValue(i *int64) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.FormatInt(*i, 10)
+}
+
+func floatValue(x *float64) string {
+	if x == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*x, 'f', -1, 64)
+}
@@ -0,0 +1,155 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ConnectionSecretName returns the name of the secret with the connection details of the database
+func ConnectionSecretName(db *databasev1.Database) string {
+	if db.Spec.ConnectionSecretName != "" {
+		return db.Spec.ConnectionSecretName
+	}
+	return db.Name + "-connection"
+}
+
+// ConnectionSecretLabel is set on the connection secrets, the value is the name of the database
+const ConnectionSecretLabel = "databases.k8s.io/connection-secret-of"
+
+// CreateConnectionSecret creates or updates the secret applications use to connect to the database through its
+// service, the secret is owned by the database so it is garbage collected together with it. When
+// spec.connectionSecretName changed the secret created under the previous name is deleted
+func (k *Kube) CreateConnectionSecret(ctx context.Context, db *databasev1.Database, port int32) error {
+	password, err := k.GetSecret(ctx, db.Namespace, db.Spec.Password.Name, db.Spec.Password.Key)
+	if err != nil {
+		return err
+	}
+	data := connectionData(db, db.Name+"."+db.Namespace+".svc", port, password)
+
+	name := ConnectionSecretName(db)
+	secrets := k.Client.CoreV1().Secrets(db.Namespace)
+	s, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		s = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       db.Namespace,
+				Labels:          map[string]string{ConnectionSecretLabel: db.Name},
+				Annotations:     map[string]string{"origin": "k8s-rds"},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(db, databasev1.SchemeGroupVersion.WithKind("Database"))},
+			},
+			Type: v1.SecretTypeOpaque,
+			Data: data,
+		}
+		log.Printf("creating connection secret %v for %v\n", name, db.Name)
+		_, err = secrets.Create(ctx, s, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		return k.deleteOldConnectionSecrets(ctx, db, name)
+	}
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to get connection secret %v", name))
+	}
+	if !metav1.IsControlledBy(s, db) {
+		return fmt.Errorf("secret %v in namespace %v already exists and is not owned by database %v", name, db.Namespace, db.Name)
+	}
+	if !reflect.DeepEqual(s.Data, data) || s.Labels[ConnectionSecretLabel] != db.Name {
+		s.Data = data
+		// secrets created before the label existed get it as well, so they are found after a rename
+		if s.Labels == nil {
+			s.Labels = map[string]string{}
+		}
+		s.Labels[ConnectionSecretLabel] = db.Name
+		log.Printf("updating connection secret %v for %v\n", name, db.Name)
+		_, err = secrets.Update(ctx, s, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return k.deleteOldConnectionSecrets(ctx, db, name)
+}
+
+// deleteOldConnectionSecrets deletes the connection secrets of the database that don't have the current name,
+// secrets that aren't owned by the database are left alone
+func (k *Kube) deleteOldConnectionSecrets(ctx context.Context, db *databasev1.Database, current string) error {
+	secrets := k.Client.CoreV1().Secrets(db.Namespace)
+	list, err := secrets.List(ctx, metav1.ListOptions{LabelSelector: labels.Set{ConnectionSecretLabel: db.Name}.String()})
+	if err != nil {
+		return errors.Wrap(err, "unable to list connection secrets")
+	}
+	for _, s := range list.Items {
+		if s.Name == current || !metav1.IsControlledBy(&s, db) {
+			continue
+		}
+		log.Printf("deleting connection secret %v of %v, it was renamed to %v\n", s.Name, db.Name, current)
+		err = secrets.Delete(ctx, s.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// connectionData returns the keys of the connection secret, besides the generic keys the
+// environment variables understood by the clients of the engine are added
+func connectionData(db *databasev1.Database, host string, port int32, password string) map[string][]byte {
+	p := strconv.Itoa(int(port))
+	user := db.Spec.Username
+	dbname := db.Spec.DBName
+	hostport := host + ":" + p
+	userinfo := url.UserPassword(user, password)
+
+	data := map[string]string{
+		"host":     host,
+		"port":     p,
+		"username": user,
+		"password": password,
+		"dbname":   dbname,
+		"engine":   db.Spec.Engine,
+	}
+
+	family, _ := provider.EnginePort(db.Spec.Engine)
+	switch family {
+	case "mysql":
+		data["DATABASE_URL"] = (&url.URL{Scheme: "mysql", User: userinfo, Host: hostport, Path: "/" + dbname}).String()
+		data["JDBC_URL"] = "jdbc:mysql://" + hostport + "/" + dbname
+		data["MYSQL_HOST"] = host
+		data["MYSQL_TCP_PORT"] = p
+		data["MYSQL_USER"] = user
+		data["MYSQL_PWD"] = password
+		data["MYSQL_DATABASE"] = dbname
+	case "mssql":
+		data["DATABASE_URL"] = (&url.URL{Scheme: "sqlserver", User: userinfo, Host: hostport, RawQuery: url.Values{"database": {dbname}}.Encode()}).String()
+		data["JDBC_URL"] = "jdbc:sqlserver://" + hostport + ";databaseName=" + dbname
+	case "oracle":
+		data["DATABASE_URL"] = (&url.URL{Scheme: "oracle", User: userinfo, Host: hostport, Path: "/" + dbname}).String()
+		data["JDBC_URL"] = "jdbc:oracle:thin:@" + hostport + "/" + dbname
+	default:
+		data["DATABASE_URL"] = (&url.URL{Scheme: "postgres", User: userinfo, Host: hostport, Path: "/" + dbname}).String()
+		data["JDBC_URL"] = "jdbc:postgresql://" + hostport + "/" + dbname
+		data["PGHOST"] = host
+		data["PGPORT"] = p
+		data["PGUSER"] = user
+		data["PGPASSWORD"] = password
+		data["PGDATABASE"] = dbname
+	}
+
+	result := make(map[string][]byte, len(data))
+	for k, v := range data {
+		result[k] = []byte(v)
+	}
+	return result
+}
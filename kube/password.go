@@ -0,0 +1,150 @@
+package kube
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GeneratedForAnnotation is set on password secrets created by the operator, the value is the database it was created for
+const GeneratedForAnnotation = "databases.k8s.io/generated-for"
+
+const (
+	lowerChars = "abcdefghijklmnopqrstuvwxyz"
+	upperChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars = "0123456789"
+	// RDS doesn't allow / " @ and space in master passwords, ' & \ and ` are left out as well as they break
+	// quoting in shells and connection strings
+	symbolChars = "!#$%()*+,-.:;<=>?[]^_{|}~"
+)
+
+// EnsurePassword creates the password secret of the database with a random password when spec.password.generate
+// is set and the secret doesn't exist. The operator never replaces a password that is already there, the key is
+// only added to secrets it created itself
+func (k *Kube) EnsurePassword(ctx context.Context, db *databasev1.Database) error {
+	if !db.Spec.Password.Generate {
+		return nil
+	}
+	name := db.Spec.Password.Name
+	key := db.Spec.Password.Key
+	secrets := k.Client.CoreV1().Secrets(db.Namespace)
+
+	s, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		password, err := GeneratePassword(db.Spec.Engine)
+		if err != nil {
+			return err
+		}
+		s = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   db.Namespace,
+				Annotations: map[string]string{GeneratedForAnnotation: db.Name},
+			},
+			Type: v1.SecretTypeOpaque,
+			Data: map[string][]byte{key: []byte(password)},
+		}
+		log.Printf("generating password secret %v for %v\n", name, db.Name)
+		_, err = secrets.Create(ctx, s, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to fetch secret %v", name))
+	}
+	if len(s.Data[key]) > 0 {
+		return nil
+	}
+	if s.Annotations[GeneratedForAnnotation] != db.Name {
+		return fmt.Errorf("secret %v has no key %v and was not created by the operator, refusing to change it", name, key)
+	}
+	password, err := GeneratePassword(db.Spec.Engine)
+	if err != nil {
+		return err
+	}
+	if s.Data == nil {
+		s.Data = map[string][]byte{}
+	}
+	s.Data[key] = []byte(password)
+	log.Printf("adding generated password %v to secret %v for %v\n", key, name, db.Name)
+	_, err = secrets.Update(ctx, s, metav1.UpdateOptions{})
+	return err
+}
+
+// RegeneratePassword replaces the password in a secret created by EnsurePassword with a new random password,
+// secrets provided by users are never changed
+func (k *Kube) RegeneratePassword(ctx context.Context, db *databasev1.Database) error {
+	name := db.Spec.Password.Name
+	secrets := k.Client.CoreV1().Secrets(db.Namespace)
+	s, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to fetch secret %v", name))
+	}
+	if s.Annotations[GeneratedForAnnotation] != db.Name {
+		return fmt.Errorf("secret %v was not created by the operator, only generated passwords are rotated", name)
+	}
+	password, err := GeneratePassword(db.Spec.Engine)
+	if err != nil {
+		return err
+	}
+	if s.Data == nil {
+		s.Data = map[string][]byte{}
+	}
+	s.Data[db.Spec.Password.Key] = []byte(password)
+	log.Printf("rotating generated password in secret %v for %v\n", name, db.Name)
+	_, err = secrets.Update(ctx, s, metav1.UpdateOptions{})
+	return err
+}
+
+// GeneratePassword returns a random password accepted as master password by the engine, it always starts with a
+// letter and contains upper and lower case letters, digits and symbols to meet the SQL Server complexity rules
+func GeneratePassword(engine string) (string, error) {
+	length := 32
+	if strings.HasPrefix(strings.ToLower(engine), "oracle") {
+		// Oracle master passwords are at most 30 characters
+		length = 30
+	}
+	all := lowerChars + upperChars + digitChars + symbolChars
+
+	password := make([]byte, 0, length)
+	for _, chars := range []string{lowerChars + upperChars, lowerChars, upperChars, digitChars, symbolChars} {
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	for len(password) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	// shuffle everything but the leading letter
+	for i := len(password) - 1; i > 1; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i)))
+		if err != nil {
+			return "", err
+		}
+		n := int(j.Int64()) + 1
+		password[i], password[n] = password[n], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to generate password")
+	}
+	return chars[n.Int64()], nil
+}
@@ -0,0 +1,110 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	for _, engine := range []string{"postgres", "mysql", "sqlserver-ex", "oracle-ee"} {
+		pw, err := GeneratePassword(engine)
+		assert.NoError(t, err)
+		assert.True(t, unicode.IsLetter(rune(pw[0])), pw)
+		assert.True(t, strings.ContainsAny(pw, lowerChars), pw)
+		assert.True(t, strings.ContainsAny(pw, upperChars), pw)
+		assert.True(t, strings.ContainsAny(pw, digitChars), pw)
+		assert.True(t, strings.ContainsAny(pw, symbolChars), pw)
+		assert.False(t, strings.ContainsAny(pw, `/"@ '`), pw)
+		if engine == "oracle-ee" {
+			assert.Len(t, pw, 30)
+		} else {
+			assert.Len(t, pw, 32)
+		}
+	}
+	a, _ := GeneratePassword("postgres")
+	b, _ := GeneratePassword("postgres")
+	assert.NotEqual(t, a, b)
+}
+
+func TestEnsurePasswordCreatesSecret(t *testing.T) {
+	ctx := context.Background()
+	kc := testclient.NewSimpleClientset()
+	db := testDatabase("postgres")
+	db.Spec.Password.Generate = true
+	k := New(kc)
+
+	assert.NoError(t, k.EnsurePassword(ctx, db))
+	pw, err := k.GetSecret(ctx, "default", "mysecret", "password")
+	assert.NoError(t, err)
+	assert.Len(t, pw, 32)
+
+	s, err := kc.CoreV1().Secrets("default").Get(ctx, "mysecret", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "mydb", s.Annotations[GeneratedForAnnotation])
+
+	// running it again keeps the password
+	assert.NoError(t, k.EnsurePassword(ctx, db))
+	again, err := k.GetSecret(ctx, "default", "mysecret", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, pw, again)
+}
+
+func TestEnsurePasswordKeepsUserSecret(t *testing.T) {
+	ctx := context.Background()
+	kc := testclient.NewSimpleClientset(passwordSecret("mine"))
+	db := testDatabase("postgres")
+	db.Spec.Password.Generate = true
+	k := New(kc)
+
+	assert.NoError(t, k.EnsurePassword(ctx, db))
+	pw, err := k.GetSecret(ctx, "default", "mysecret", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "mine", pw)
+
+	// a user provided secret without the key is not touched
+	db.Spec.Password.Key = "other"
+	assert.Error(t, k.EnsurePassword(ctx, db))
+}
+
+func TestEnsurePasswordNotRequested(t *testing.T) {
+	kc := testclient.NewSimpleClientset()
+	assert.NoError(t, New(kc).EnsurePassword(context.Background(), testDatabase("postgres")))
+	_, err := kc.CoreV1().Secrets("default").Get(context.Background(), "mysecret", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestGetSecretMissingKey(t *testing.T) {
+	kc := testclient.NewSimpleClientset(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "mysecret", Namespace: "default"}})
+	_, err := New(kc).GetSecret(context.Background(), "default", "mysecret", "password")
+	assert.Error(t, err)
+}
+
+func TestRegeneratePassword(t *testing.T) {
+	ctx := context.Background()
+	kc := testclient.NewSimpleClientset()
+	db := testDatabase("postgres")
+	db.Spec.Password.Generate = true
+	k := New(kc)
+
+	assert.NoError(t, k.EnsurePassword(ctx, db))
+	pw, err := k.GetSecret(ctx, "default", "mysecret", "password")
+	assert.NoError(t, err)
+	assert.NoError(t, k.RegeneratePassword(ctx, db))
+	rotated, err := k.GetSecret(ctx, "default", "mysecret", "password")
+	assert.NoError(t, err)
+	assert.NotEqual(t, pw, rotated)
+}
+
+func TestRegeneratePasswordKeepsUserSecret(t *testing.T) {
+	kc := testclient.NewSimpleClientset(passwordSecret("mine"))
+	db := testDatabase("postgres")
+	db.Spec.Password.Generate = true
+	assert.Error(t, New(kc).RegeneratePassword(context.Background(), db))
+}
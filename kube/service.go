@@ -7,48 +7,77 @@ import (
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 )
 
+// Kube implements provider.ServiceProvider for all providers, the shape of the
+// service in front of the database is set with ServiceOptions
 type Kube struct {
-	Client *kubernetes.Clientset
+	Client  kubernetes.Interface
+	options []ServiceOption
 }
 
-// create an External named service object for Kubernetes
-func (k *Kube) createServiceObj(s *v1.Service, namespace string, hostname string, internalname string) *v1.Service {
-	var ports []v1.ServicePort
+// ServiceOption shapes the service created in front of a database
+type ServiceOption func(s *v1.Service, hostname string, internalname string)
 
-	ports = append(ports, v1.ServicePort{
-		Name:       "pgsql",
-		Port:       int32(5432),
-		TargetPort: intstr.IntOrString{IntVal: int32(5432)},
-	})
-	s.Spec.Type = "ExternalName"
-	s.Spec.ExternalName = hostname
+// ExternalName points the service at the hostname of a database running outside of the cluster
+func ExternalName() ServiceOption {
+	return func(s *v1.Service, hostname string, internalname string) {
+		s.Spec.Type = v1.ServiceTypeExternalName
+		s.Spec.ExternalName = hostname
+		s.Spec.Selector = nil
+		s.Annotations = map[string]string{"origin": "rds"}
+	}
+}
+
+// ClusterIP selects the pods of a database running in the cluster, the container port is named after the engine
+func ClusterIP() ServiceOption {
+	return func(s *v1.Service, hostname string, internalname string) {
+		s.Spec.Type = v1.ServiceTypeClusterIP
+		s.Spec.ExternalName = ""
+		s.Spec.Selector = map[string]string{"db": internalname}
+		for i := range s.Spec.Ports {
+			s.Spec.Ports[i].TargetPort = intstr.FromString(s.Spec.Ports[i].Name)
+		}
+		s.Annotations = map[string]string{"origin": "k8s-rds"}
+	}
+}
+
+// New returns a service provider using client, without options an ExternalName service is created
+func New(client kubernetes.Interface, options ...ServiceOption) *Kube {
+	if len(options) == 0 {
+		options = []ServiceOption{ExternalName()}
+	}
+	return &Kube{Client: client, options: options}
+}
 
-	s.Spec.Ports = ports
+// create the service object for Kubernetes, s is the existing service if there is one
+func (k *Kube) createServiceObj(s *v1.Service, namespace string, hostname string, internalname string, port v1.ServicePort) *v1.Service {
+	s.Spec.Ports = []v1.ServicePort{port}
 	s.Name = internalname
-	s.Annotations = map[string]string{"origin": "rds"}
 	s.Namespace = namespace
+	for _, option := range k.options {
+		option(s, hostname, internalname)
+	}
 	return s
 }
 
 // CreateService Creates or updates a service in Kubernetes with the new information
-func (k *Kube) CreateService(ctx context.Context, namespace string, hostname string, internalname string) error {
-	// create a service in kubernetes that points to the AWS RDS instance
+func (k *Kube) CreateService(ctx context.Context, namespace string, hostname string, internalname string, port v1.ServicePort) error {
 	serviceInterface := k.Client.CoreV1().Services(namespace)
 
-	s, sErr := serviceInterface.Get(ctx, hostname, metav1.GetOptions{})
-
-	create := false
-	if sErr != nil {
+	s, err := serviceInterface.Get(ctx, internalname, metav1.GetOptions{})
+	create := apierrors.IsNotFound(err)
+	if err != nil && !create {
+		return errors.Wrap(err, fmt.Sprintf("unable to get service %v in namespace %v", internalname, namespace))
+	}
+	if create {
 		s = &v1.Service{}
-		create = true
 	}
-	s = k.createServiceObj(s, namespace, hostname, internalname)
-	var err error
+	s = k.createServiceObj(s, namespace, hostname, internalname, port)
 	if create {
 		_, err = serviceInterface.Create(ctx, s, metav1.CreateOptions{})
 	} else {
@@ -61,6 +90,10 @@ func (k *Kube) CreateService(ctx context.Context, namespace string, hostname str
 func (k *Kube) DeleteService(ctx context.Context, namespace string, dbname string) error {
 	serviceInterface := k.Client.CoreV1().Services(namespace)
 	err := serviceInterface.Delete(ctx, dbname, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		log.Printf("service %v in namespace %v is already deleted\n", dbname, namespace)
+		return nil
+	}
 	if err != nil {
 		log.Println(err)
 		return errors.Wrap(err, fmt.Sprintf("delete of service %v failed in namespace %v", dbname, namespace))
@@ -73,6 +106,9 @@ func (k *Kube) GetSecret(ctx context.Context, namespace string, name string, key
 	if err != nil {
 		return "", errors.Wrap(err, fmt.Sprintf("unable to fetch secret %v", name))
 	}
-	password := secret.Data[key]
+	password, ok := secret.Data[key]
+	if !ok || len(password) == 0 {
+		return "", fmt.Errorf("secret %v has no value for key %v", name, key)
+	}
 	return string(password), nil
 }
@@ -0,0 +1,78 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+var pgsql = v1.ServicePort{Name: "pgsql", Port: 5432, TargetPort: intstr.FromInt(5432)}
+
+func TestCreateExternalNameService(t *testing.T) {
+	ctx := context.Background()
+	kc := testclient.NewSimpleClientset()
+	k := New(kc, ExternalName())
+
+	err := k.CreateService(ctx, "default", "mydb.abc.eu-west-1.rds.amazonaws.com", "mydb", pgsql)
+	assert.NoError(t, err)
+
+	s, err := kc.CoreV1().Services("default").Get(ctx, "mydb", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, v1.ServiceTypeExternalName, s.Spec.Type)
+	assert.Equal(t, "mydb.abc.eu-west-1.rds.amazonaws.com", s.Spec.ExternalName)
+	assert.Equal(t, []v1.ServicePort{pgsql}, s.Spec.Ports)
+	assert.Equal(t, "rds", s.Annotations["origin"])
+}
+
+func TestCreateClusterIPService(t *testing.T) {
+	ctx := context.Background()
+	kc := testclient.NewSimpleClientset()
+	k := New(kc, ClusterIP())
+
+	err := k.CreateService(ctx, "default", "mydb", "mydb", pgsql)
+	assert.NoError(t, err)
+
+	s, err := kc.CoreV1().Services("default").Get(ctx, "mydb", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, v1.ServiceTypeClusterIP, s.Spec.Type)
+	assert.Equal(t, map[string]string{"db": "mydb"}, s.Spec.Selector)
+	assert.Equal(t, intstr.FromString("pgsql"), s.Spec.Ports[0].TargetPort)
+	assert.Equal(t, "k8s-rds", s.Annotations["origin"])
+}
+
+func TestCreateServiceUpdatesExisting(t *testing.T) {
+	ctx := context.Background()
+	kc := testclient.NewSimpleClientset(&v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "mydb", Namespace: "default"},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeExternalName, ExternalName: "old.rds.amazonaws.com"},
+	})
+	k := New(kc, ExternalName())
+
+	// the service is looked up by its name, not by the hostname of the database
+	err := k.CreateService(ctx, "default", "new.rds.amazonaws.com", "mydb", pgsql)
+	assert.NoError(t, err)
+
+	s, err := kc.CoreV1().Services("default").Get(ctx, "mydb", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "new.rds.amazonaws.com", s.Spec.ExternalName)
+}
+
+func TestDeleteServiceAlreadyDeleted(t *testing.T) {
+	k := New(testclient.NewSimpleClientset())
+	assert.NoError(t, k.DeleteService(context.Background(), "default", "mydb"))
+}
+
+func TestGetSecret(t *testing.T) {
+	kc := testclient.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysecret", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	})
+	pw, err := New(kc).GetSecret(context.Background(), "default", "mysecret", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", pw)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// runLeaderElection blocks while competing for the lease and calls run when this replica becomes the leader.
+// Losing the lease exits the process, so we never have two replicas talking to the provider at the same time.
+func runLeaderElection(ctx context.Context, kubectl kubernetes.Interface, opts options, run func(ctx context.Context)) error {
+	id, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("unable to get hostname: %v", err)
+	}
+	// make the identity unique in case two replicas end up with the same hostname
+	id = id + "_" + string(uuid.NewUUID())
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      opts.leaseName,
+			Namespace: leaseNamespace(opts.leaseNamespace),
+		},
+		Client: kubectl.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   opts.leaseDuration,
+		RenewDeadline:   opts.renewDeadline,
+		RetryPeriod:     opts.retryPeriod,
+		Name:            opts.leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Printf("%v became leader, starting to reconcile databases\n", id)
+				run(ctx)
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					log.Println("shutting down, leadership released")
+					return
+				}
+				log.Fatalf("%v lost leadership, exiting", id)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					log.Printf("%v is the leader, waiting on standby\n", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Trying to acquire lease %v/%v as %v\n", lock.LeaseMeta.Namespace, lock.LeaseMeta.Name, id)
+	le.Run(ctx)
+	return nil
+}
+
+// leaseNamespace returns the configured namespace, or the namespace the operator runs in
+func leaseNamespace(namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if ns, err := ioutil.ReadFile(serviceAccountNamespaceFile); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return "default"
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	e "github.com/pkg/errors"
-	"github.com/sorenmat/k8s-rds/crd"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/kube"
 	"github.com/sorenmat/k8s-rds/provider"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -18,30 +20,37 @@ import (
 )
 
 type Local struct {
-	ServiceProvider provider.ServiceProvider
-	kc              kubernetes.Interface
-	SkipWaiting     bool
-	repository      string
+	// ServiceProvider creates the ClusterIP service selecting the database pod and reads the password secret
+	provider.ServiceProvider
+	kc          kubernetes.Interface
+	SkipWaiting bool
+	repository  string
+	// ReplicationCIDR is the network the read replicas connect from, ex the pod CIDR of the cluster. Read replicas
+	// need it, without it only the subnets the database pod is connected to are allowed
+	ReplicationCIDR string
 }
 
-func New(db *crd.Database, kc kubernetes.Interface, repository string) (*Local, error) {
-	r := Local{kc: kc, repository: repository}
+func New(db *databasev1.Database, kc kubernetes.Interface, repository string) (*Local, error) {
+	r := Local{ServiceProvider: kube.New(kc, kube.ClusterIP()), kc: kc, repository: repository}
 	return &r, nil
 }
 
 // CreateDatabase creates a database from the CRD database object, is also ensures that the correct
 // subnets are created for the database so we can access it
-func (l *Local) CreateDatabase(ctx context.Context, db *crd.Database) (string, error) {
+func (l *Local) CreateDatabase(ctx context.Context, db *databasev1.Database) (*provider.DatabaseInfo, error) {
+	if source := provider.RestoreSource(db); source != "" {
+		return nil, fmt.Errorf("restoring from %v is not supported by the local provider", source)
+	}
 
-	if err := l.createPVC(ctx, db.Name, db.Namespace, db.Spec.Size); err != nil {
-		return "", err
+	if err := l.createPVC(ctx, db.Name, db.Namespace, db.Spec.Size, true); err != nil {
+		return nil, err
 	}
 
 	_new := false
 	d, err := l.kc.AppsV1().Deployments(db.Namespace).Get(ctx, db.Name, metav1.GetOptions{})
 	if err != nil && !errors.IsNotFound(err) {
 		// we got an error and it's not the NotFound, let's crash
-		return "", err
+		return nil, err
 	}
 	if errors.IsNotFound(err) {
 		// Deployment seems to be empty, let's assume it means we need to create it
@@ -49,29 +58,98 @@ func (l *Local) CreateDatabase(ctx context.Context, db *crd.Database) (string, e
 		_new = true
 	}
 
-	d.Name = db.Name
-	d.Labels = map[string]string{"db": "true"}
-
-	d.ObjectMeta = metav1.ObjectMeta{
-		Name: db.Name,
+	if _new {
+		d.ObjectMeta = metav1.ObjectMeta{
+			Name: db.Name,
+		}
 	}
+	// keep the annotations of the pod template, they are used to restart the pod when the password is rotated
+	annotations := d.Spec.Template.Annotations
 	d.Spec = toSpec(db, l.repository)
+	d.Spec.Template.Annotations = annotations
+	if db.Spec.Engine == "postgres" {
+		if err := l.ensureReplicationSecret(ctx, db); err != nil {
+			return nil, err
+		}
+		c := &d.Spec.Template.Spec.Containers[0]
+		c.Env = append(c.Env, replicationEnv(l.ReplicationCIDR), replicationPasswordEnv(db, "REPLICATION_PASSWORD"))
+	}
 
 	if _new {
 		log.Printf("creating database %v", db.Name)
 		_, err = l.kc.AppsV1().Deployments(db.Namespace).Create(ctx, d, metav1.CreateOptions{})
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	} else {
 		log.Printf("updating database %v", db.Name)
 		_, err = l.kc.AppsV1().Deployments(db.Namespace).Update(ctx, d, metav1.UpdateOptions{})
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
-	return db.Name, nil
+	return &provider.DatabaseInfo{
+		Hostname:      db.Name,
+		Port:          provider.DatabasePort(db, 0),
+		ResourceID:    db.Namespace + "/" + db.Name,
+		EngineVersion: imageVersion(db),
+	}, nil
+}
+
+// RotatePassword restarts the database pod, the postStart hook of the container sets the password from the secret
+func (l *Local) RotatePassword(ctx context.Context, db *databasev1.Database, password string) error {
+	deployments := l.kc.AppsV1().Deployments(db.Namespace)
+	d, err := deployments.Get(ctx, db.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if d.Spec.Template.Annotations == nil {
+		d.Spec.Template.Annotations = map[string]string{}
+	}
+	d.Spec.Template.Annotations[passwordRotatedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	log.Printf("restarting database %v to apply the new password", db.Name)
+	_, err = deployments.Update(ctx, d, metav1.UpdateOptions{})
+	return err
+}
+
+// UpdateDatabase rolls the deployment and resizes the pvc according to the new spec
+func (l *Local) UpdateDatabase(ctx context.Context, db *databasev1.Database) error {
+	_, err := l.CreateDatabase(ctx, db)
+	return err
+}
+
+// passwordRotatedAnnotation on the pod template triggers a restart of the database when the password changed
+const passwordRotatedAnnotation = "databases.k8s.io/password-rotated-at"
+
+// postStartScript sets the password of the database user from the environment when the container starts, the
+// entrypoint of the postgres image only does that when the database is initialized. The socket connection is trusted
+// so the old password isn't needed, a failure doesn't stop the container as it keeps the previous password.
+// It also creates the replicator role the read replicas stream with, it gets the password of the replication secret,
+// and only allows replication connections of that role from REPLICATION_CIDR. Only postgres images run it.
+const postStartScript = `for i in $(seq 1 60); do
+  if pg_isready -q -U "$POSTGRES_USER" -d "$POSTGRES_DB"; then
+    rule="host replication replicator $REPLICATION_CIDR md5"
+    if ! grep -qxF "$rule" "$PGDATA/pg_hba.conf"; then
+      sed -i '/^host replication /d' "$PGDATA/pg_hba.conf"
+      echo "$rule" >> "$PGDATA/pg_hba.conf"
+      psql -q -U "$POSTGRES_USER" -d "$POSTGRES_DB" -c "SELECT pg_reload_conf()"
+    fi
+    printf '%s\n' "SELECT 'CREATE ROLE replicator REPLICATION LOGIN' WHERE NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'replicator')" '\gexec' \
+      "ALTER ROLE replicator PASSWORD :'rpw';" "ALTER ROLE CURRENT_USER PASSWORD :'pw';" |
+      psql -q -U "$POSTGRES_USER" -d "$POSTGRES_DB" -v pw="$POSTGRES_PASSWORD" -v rpw="$REPLICATION_PASSWORD" && exit 0
+  fi
+  sleep 2
+done
+exit 0`
+
+// replicationEnv tells postStartScript where replication connections may come from, samenet matches the subnets
+// the database pod is connected to
+func replicationEnv(cidr string) corev1.EnvVar {
+	if cidr == "" {
+		cidr = "samenet"
+	}
+	return corev1.EnvVar{Name: "REPLICATION_CIDR", Value: cidr}
 }
 
 const (
@@ -80,7 +158,8 @@ const (
 	iterationWaitPeriodSec    = 5 * time.Second
 )
 
-func (l *Local) createPVC(ctx context.Context, name, namespace string, size int64) error {
+// createPVC creates or resizes the claim, with wait it waits for the claim to be bound
+func (l *Local) createPVC(ctx context.Context, name, namespace string, size int64, wait bool) error {
 	newPVC := false
 
 	pvc, err := l.kc.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
@@ -137,19 +216,24 @@ func (l *Local) createPVC(ctx context.Context, name, namespace string, size int6
 			return err
 		}
 
-		if oldPvc.Spec.Resources.Requests.StorageEphemeral().Cmp(*pvc.Spec.Resources.Requests.StorageEphemeral()) == 0 {
+		if oldPvc.Spec.Resources.Requests.Storage().Cmp(*pvc.Spec.Resources.Requests.Storage()) == 0 {
 			log.Printf("Specs %s has same size: not updating pvc \n",
 				name)
 			return nil
 		}
-		_, err = l.kc.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, pvc, metav1.UpdateOptions{})
+		// only the requested storage can be changed on a bound claim
+		if oldPvc.Spec.Resources.Requests == nil {
+			oldPvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		oldPvc.Spec.Resources.Requests[corev1.ResourceStorage] = *pvc.Spec.Resources.Requests.Storage()
+		_, err = l.kc.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, oldPvc, metav1.UpdateOptions{})
 		if err != nil {
 			return e.Wrap(err,
 				fmt.Sprintf("Error: PVC %s has problems while updating %v", name, err))
 		}
 	}
 
-	if !l.SkipWaiting {
+	if wait && !l.SkipWaiting {
 		pvcIsReady := false
 		for i := 0; i < maxAmountOfWaitIterations; i++ {
 
@@ -188,11 +272,15 @@ const (
 )
 
 // DeleteDatabase deletes the db pod and pvc
-func (l *Local) DeleteDatabase(ctx context.Context, db *crd.Database) error {
+func (l *Local) DeleteDatabase(ctx context.Context, db *databasev1.Database) error {
+	if err := l.deleteReplicas(ctx, db, 0); err != nil {
+		return err
+	}
+
 	// delete the database instance
 
 	for i := 0; i < nDeleteAttempts; i++ {
-		if err := l.kc.AppsV1().Deployments(db.Namespace).Delete(ctx, db.Name, metav1.DeleteOptions{}); err != nil {
+		if err := l.kc.AppsV1().Deployments(db.Namespace).Delete(ctx, db.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
 			fmt.Printf("ERROR: error while deleting the deployment: %v\n", err)
 			continue
 		}
@@ -200,7 +288,7 @@ func (l *Local) DeleteDatabase(ctx context.Context, db *crd.Database) error {
 		if db.Spec.DeleteProtection {
 			log.Printf("Trying to delete a %v in %v which is a deleted protected database", db.Name, db.Namespace)
 		} else {
-			if err := l.kc.CoreV1().PersistentVolumeClaims(db.Namespace).Delete(ctx, db.Name, metav1.DeleteOptions{}); err != nil {
+			if err := l.kc.CoreV1().PersistentVolumeClaims(db.Namespace).Delete(ctx, db.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
 				fmt.Printf("ERROR: error while deleting the pvc: %v\n", err)
 				continue
 			}
@@ -214,17 +302,44 @@ func (l *Local) DeleteDatabase(ctx context.Context, db *crd.Database) error {
 
 func int32Ptr(i int32) *int32 { return &i }
 
-func toSpec(db *crd.Database, repository string) v1.DeploymentSpec {
-	version := db.Spec.Version
-	if version == "" {
-		version = "latest"
+// imageVersion returns the tag of the database image
+func imageVersion(db *databasev1.Database) string {
+	if db.Spec.Version == "" {
+		return "latest"
 	}
+	return db.Spec.Version
+}
 
-	image := fmt.Sprintf("%v:%v", db.Spec.Engine, version)
+// image returns the image of the database engine, from repository if it's set
+func image(db *databasev1.Database, repository string) string {
 	if repository != "" {
-		image = fmt.Sprintf("%v/%v:%v", repository, db.Spec.Engine, version)
+		return fmt.Sprintf("%v/%v:%v", repository, db.Spec.Engine, imageVersion(db))
 	}
-	return v1.DeploymentSpec{
+	return fmt.Sprintf("%v:%v", db.Spec.Engine, imageVersion(db))
+}
+
+// engineArgs passes spec.parameters to the database server, -c name=value for postgres and --name=value for mysql
+func engineArgs(db *databasev1.Database) []string {
+	names := make([]string, 0, len(db.Spec.Parameters))
+	for name := range db.Spec.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var args []string
+	for _, name := range names {
+		if db.Spec.Engine == "postgres" {
+			args = append(args, "-c", name+"="+db.Spec.Parameters[name])
+		} else {
+			args = append(args, "--"+name+"="+db.Spec.Parameters[name])
+		}
+	}
+	return args
+}
+
+func toSpec(db *databasev1.Database, repository string) v1.DeploymentSpec {
+	portName, port := provider.EnginePort(db.Spec.Engine)
+	image := image(db, repository)
+	spec := v1.DeploymentSpec{
 		Replicas: int32Ptr(1),
 		Selector: &metav1.LabelSelector{
 			MatchLabels: map[string]string{
@@ -245,6 +360,7 @@ func toSpec(db *crd.Database, repository string) v1.DeploymentSpec {
 					{
 						Name:  db.Name,
 						Image: image, // TODO is this correct
+						Args:  engineArgs(db),
 						Env: []corev1.EnvVar{corev1.EnvVar{
 							Name: "POSTGRES_PASSWORD",
 							ValueFrom: &corev1.EnvVarSource{
@@ -267,12 +383,11 @@ func toSpec(db *crd.Database, repository string) v1.DeploymentSpec {
 								MountPath: "/var/lib/postgresql/data",
 							},
 						},
-
 						Ports: []corev1.ContainerPort{
 							{
-								Name:          "pgsql",
+								Name:          portName,
 								Protocol:      corev1.ProtocolTCP,
-								ContainerPort: 5432,
+								ContainerPort: port,
 							},
 						}},
 				},
@@ -290,5 +405,12 @@ func toSpec(db *crd.Database, repository string) v1.DeploymentSpec {
 			},
 		},
 	}
-
+	if db.Spec.Engine == "postgres" {
+		spec.Template.Spec.Containers[0].Lifecycle = &corev1.Lifecycle{
+			PostStart: &corev1.LifecycleHandler{
+				Exec: &corev1.ExecAction{Command: []string{"sh", "-c", postStartScript}},
+			},
+		}
+	}
+	return spec
 }
@@ -4,7 +4,7 @@ import (
 	"context"
 	"testing"
 
-	"github.com/sorenmat/k8s-rds/crd"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
 
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -13,9 +13,9 @@ import (
 )
 
 func TestConvertSpecToDeployment(t *testing.T) {
-	db := &crd.Database{
+	db := &databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb"},
-		Spec: crd.DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			DBName:             "mydb",
 			Engine:             "postgres",
 			Username:           "myuser",
@@ -26,19 +26,40 @@ func TestConvertSpecToDeployment(t *testing.T) {
 			StorageEncrypted:   true,
 			StorageType:        "bad",
 			Iops:               1000,
-			Password:           v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"},
+			Password:           databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
 		},
 	}
 	repository := "registry.bwtsi.cn"
 	spec := toSpec(db, repository)
 	assert.Equal(t, "mydb", spec.Template.Spec.Containers[0].Name)
 	assert.Equal(t, "registry.bwtsi.cn/postgres:latest", spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "pgsql", spec.Template.Spec.Containers[0].Ports[0].Name)
+	assert.Equal(t, int32(5432), spec.Template.Spec.Containers[0].Ports[0].ContainerPort)
+
+	db.Spec.Engine = "mysql"
+	spec = toSpec(db, repository)
+	assert.Equal(t, "mysql", spec.Template.Spec.Containers[0].Ports[0].Name)
+	assert.Equal(t, int32(3306), spec.Template.Spec.Containers[0].Ports[0].ContainerPort)
+	assert.Empty(t, spec.Template.Spec.Containers[0].Args)
+	// the postStart hook only works with the postgres image
+	assert.Nil(t, spec.Template.Spec.Containers[0].Lifecycle)
+}
+
+func TestEngineArgs(t *testing.T) {
+	db := &databasev1.Database{Spec: databasev1.DatabaseSpec{
+		Engine:     "postgres",
+		Parameters: map[string]string{"work_mem": "64MB", "max_connections": "200"},
+	}}
+	assert.Equal(t, []string{"-c", "max_connections=200", "-c", "work_mem=64MB"}, engineArgs(db))
+
+	db.Spec.Engine = "mysql"
+	assert.Equal(t, []string{"--max_connections=200", "--work_mem=64MB"}, engineArgs(db))
 }
 
 func TestCreateDatabase(t *testing.T) {
-	db := &crd.Database{
+	db := &databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb"},
-		Spec: crd.DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			DBName:             "mydb",
 			Engine:             "postgres",
 			Username:           "myuser",
@@ -49,7 +70,7 @@ func TestCreateDatabase(t *testing.T) {
 			StorageEncrypted:   true,
 			StorageType:        "bad",
 			Iops:               1000,
-			Password:           v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"},
+			Password:           databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
 		},
 	}
 	kc := testclient.NewSimpleClientset()
@@ -82,6 +103,16 @@ func TestCreateDatabase(t *testing.T) {
 			Group:    "apps",
 			Resource: "deployments",
 		},
+		{
+			Action:   "get",
+			Group:    "",
+			Resource: "secrets",
+		},
+		{
+			Action:   "create",
+			Group:    "",
+			Resource: "secrets",
+		},
 		{
 			Action:   "create",
 			Group:    "apps",
@@ -95,12 +126,27 @@ func TestCreateDatabase(t *testing.T) {
 		assert.Equal(t, sequence[i].Resource, action.GetResource().GroupResource().Resource)
 	}
 
+	d, err := kc.AppsV1().Deployments("").Get(context.Background(), "mydb", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, d.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{Name: "REPLICATION_CIDR", Value: "samenet"})
+	assert.Contains(t, d.Spec.Template.Spec.Containers[0].Env, replicationPasswordEnv(db, "REPLICATION_PASSWORD"))
+
+	// the replicator role gets a password of its own
+	secret, err := kc.CoreV1().Secrets("").Get(context.Background(), "mydb-replication", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, secret.Data[replicationPasswordKey])
+	assert.True(t, meta_v1.IsControlledBy(secret, db))
+}
+
+func TestReplicationEnv(t *testing.T) {
+	assert.Equal(t, "samenet", replicationEnv("").Value)
+	assert.Equal(t, "10.244.0.0/16", replicationEnv("10.244.0.0/16").Value)
 }
 
 func TestUpdateDatabase(t *testing.T) {
-	db := &crd.Database{
+	db := &databasev1.Database{
 		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb"},
-		Spec: crd.DatabaseSpec{
+		Spec: databasev1.DatabaseSpec{
 			DBName:             "mydb",
 			Engine:             "postgres",
 			Username:           "myuser",
@@ -111,7 +157,7 @@ func TestUpdateDatabase(t *testing.T) {
 			StorageEncrypted:   true,
 			StorageType:        "bad",
 			Iops:               1000,
-			Password:           v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"},
+			Password:           databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
 		},
 	}
 	kc := testclient.NewSimpleClientset()
@@ -123,10 +169,10 @@ func TestUpdateDatabase(t *testing.T) {
 	host, err := l.CreateDatabase(context.Background(), db)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, host)
-	assert.Equal(t, 4, len(kc.Fake.Actions()))
+	assert.Equal(t, 6, len(kc.Fake.Actions()))
 	_, err = l.CreateDatabase(context.Background(), db)
 	assert.NoError(t, err)
-	assert.Equal(t, 8, len(kc.Fake.Actions()))
+	assert.Equal(t, 11, len(kc.Fake.Actions()))
 
 	sequence := []struct {
 		Action   string
@@ -148,6 +194,16 @@ func TestUpdateDatabase(t *testing.T) {
 			Group:    "apps",
 			Resource: "deployments",
 		},
+		{
+			Action:   "get",
+			Group:    "",
+			Resource: "secrets",
+		},
+		{
+			Action:   "create",
+			Group:    "",
+			Resource: "secrets",
+		},
 		{
 			Action:   "create",
 			Group:    "apps",
@@ -169,6 +225,11 @@ func TestUpdateDatabase(t *testing.T) {
 			Group:    "apps",
 			Resource: "deployments",
 		},
+		{
+			Action:   "get",
+			Group:    "",
+			Resource: "secrets",
+		},
 		{
 			Action:   "update",
 			Group:    "apps",
@@ -182,3 +243,106 @@ func TestUpdateDatabase(t *testing.T) {
 		assert.Equal(t, sequence[i].Resource, action.GetResource().GroupResource().Resource)
 	}
 }
+
+func TestUpdateDatabaseResizesPVC(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb"},
+		Spec: databasev1.DatabaseSpec{
+			DBName:   "mydb",
+			Engine:   "postgres",
+			Username: "myuser",
+			Size:     100,
+			Password: databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
+		},
+	}
+	kc := testclient.NewSimpleClientset()
+	l, err := New(db, kc, "")
+	assert.NoError(t, err)
+	l.SkipWaiting = true
+	_, err = l.CreateDatabase(context.Background(), db)
+	assert.NoError(t, err)
+
+	db.Spec.Size = 200
+	db.Spec.Version = "13"
+	err = l.UpdateDatabase(context.Background(), db)
+	assert.NoError(t, err)
+
+	pvc, err := kc.CoreV1().PersistentVolumeClaims("").Get(context.Background(), "mydb", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "200Gi", pvc.Spec.Resources.Requests.Storage().String())
+
+	d, err := kc.AppsV1().Deployments("").Get(context.Background(), "mydb", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres:13", d.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestDeleteDatabaseAlreadyDeleted(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb"},
+		Spec: databasev1.DatabaseSpec{
+			DBName: "mydb",
+			Engine: "postgres",
+			Size:   100,
+		},
+	}
+	kc := testclient.NewSimpleClientset()
+	l, err := New(db, kc, "")
+	assert.NoError(t, err)
+	l.SkipWaiting = true
+	_, err = l.CreateDatabase(context.Background(), db)
+	assert.NoError(t, err)
+
+	assert.NoError(t, l.DeleteDatabase(context.Background(), db))
+	// deleting a second time should be a noop so a retried deletion succeeds
+	assert.NoError(t, l.DeleteDatabase(context.Background(), db))
+}
+
+func TestRotatePasswordRestartsDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			DBName:   "mydb",
+			Engine:   "postgres",
+			Username: "myuser",
+			Size:     10,
+			Password: databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
+		},
+	}
+	kc := testclient.NewSimpleClientset()
+	l, err := New(db, kc, "")
+	assert.NoError(t, err)
+	l.SkipWaiting = true
+	_, err = l.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+
+	assert.NoError(t, l.RotatePassword(ctx, db, "new"))
+	d, err := kc.AppsV1().Deployments("default").Get(ctx, "mydb", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	rotatedAt := d.Spec.Template.Annotations[passwordRotatedAnnotation]
+	assert.NotEmpty(t, rotatedAt)
+	assert.NotNil(t, d.Spec.Template.Spec.Containers[0].Lifecycle.PostStart.Exec)
+
+	// applying the spec again doesn't undo the restart
+	assert.NoError(t, l.UpdateDatabase(ctx, db))
+	d, err = kc.AppsV1().Deployments("default").Get(ctx, "mydb", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, rotatedAt, d.Spec.Template.Annotations[passwordRotatedAnnotation])
+}
+
+func TestCreateDatabaseRestoreNotSupported(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb"},
+		Spec: databasev1.DatabaseSpec{
+			DBName:      "mydb",
+			Engine:      "postgres",
+			Size:        100,
+			RestoreFrom: &databasev1.RestoreFrom{SnapshotIdentifier: "mydb-default-1650000000"},
+		},
+	}
+	kc := testclient.NewSimpleClientset()
+	l, err := New(db, kc, "")
+	assert.NoError(t, err)
+	_, err = l.CreateDatabase(context.Background(), db)
+	assert.Error(t, err)
+}
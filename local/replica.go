@@ -0,0 +1,180 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/kube"
+	"github.com/sorenmat/k8s-rds/provider"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// replicaOfLabel is set on the deployments of the read replicas, the value is the name of the database
+const replicaOfLabel = "replica-of"
+
+// replicationPasswordKey is the key of the password in the replication secret
+const replicationPasswordKey = "password"
+
+// replicaScript clones the database with pg_basebackup as the replicator role when the data directory is empty and
+// starts postgres as a streaming standby with the parameters of the database, the data is kept in an emptyDir so a
+// restarted replica clones the database again
+const replicaScript = `if [ ! -s "$PGDATA/PG_VERSION" ]; then
+  mkdir -p "$PGDATA" && chown postgres "$PGDATA" && chmod 700 "$PGDATA"
+  until su postgres -c 'pg_basebackup -h "$PRIMARY_HOST" -p "$PRIMARY_PORT" -U replicator -D "$PGDATA" -X stream -R'; do
+    echo "waiting for $PRIMARY_HOST to accept replication connections"
+    rm -rf "$PGDATA"/*
+    sleep 5
+  done
+fi
+exec docker-entrypoint.sh postgres "$@"`
+
+// EnsureReadReplicas runs a deployment per read replica streaming from the database and deletes the deployments
+// above spec.readReplicas.count
+func (l *Local) EnsureReadReplicas(ctx context.Context, db *databasev1.Database) ([]provider.ReplicaInfo, error) {
+	deployments := l.kc.AppsV1().Deployments(db.Namespace)
+	count := provider.ReplicaCount(db)
+	if count > 0 && db.Spec.Engine != "postgres" {
+		return nil, fmt.Errorf("read replicas are only supported for postgres by the local provider, not %v", db.Spec.Engine)
+	}
+	if count > 0 && l.ReplicationCIDR == "" {
+		// the replicas are scheduled on any node, samenet would only let the ones next to the database in
+		return nil, fmt.Errorf("read replicas of the local provider need --local-replication-cidr, ex the pod CIDR of the cluster")
+	}
+	var infos []provider.ReplicaInfo
+	for i := 0; i < count; i++ {
+		name := provider.ReplicaName(db, i)
+		d, err := deployments.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			d = &v1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{replicaOfLabel: db.Name}}}
+			d.Spec = toReplicaSpec(db, name, l.repository)
+			log.Printf("creating read replica %v of database %v", name, db.Name)
+			d, err = deployments.Create(ctx, d, metav1.CreateOptions{})
+		} else if err == nil {
+			d.Spec = toReplicaSpec(db, name, l.repository)
+			d, err = deployments.Update(ctx, d, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		state := "creating"
+		if d.Status.ReadyReplicas > 0 {
+			state = provider.ReplicaAvailable
+		}
+		infos = append(infos, provider.ReplicaInfo{
+			DatabaseInfo: provider.DatabaseInfo{
+				Hostname:      name,
+				Port:          provider.DatabasePort(db, 0),
+				ResourceID:    db.Namespace + "/" + name,
+				EngineVersion: imageVersion(db),
+			},
+			Name:  name,
+			State: state,
+		})
+	}
+
+	return infos, l.deleteReplicas(ctx, db, count)
+}
+
+// deleteReplicas deletes the read replicas of the database from index from on
+func (l *Local) deleteReplicas(ctx context.Context, db *databasev1.Database, from int) error {
+	deployments := l.kc.AppsV1().Deployments(db.Namespace)
+	list, err := deployments.List(ctx, metav1.ListOptions{LabelSelector: labels.Set{replicaOfLabel: db.Name}.String()})
+	if err != nil {
+		return err
+	}
+	for _, d := range list.Items {
+		i, err := strconv.Atoi(strings.TrimPrefix(d.Name, db.Name+"-ro-"))
+		if err != nil || i < from {
+			continue
+		}
+		log.Printf("deleting read replica %v of database %v", d.Name, db.Name)
+		err = deployments.Delete(ctx, d.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// toReplicaSpec returns the deployment of a read replica, it uses the image of the database and streams with the
+// password of the replication secret
+func toReplicaSpec(db *databasev1.Database, name string, repository string) v1.DeploymentSpec {
+	spec := toSpec(db, repository)
+	spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"db": name}}
+	spec.Template.Labels = map[string]string{"db": name, replicaOfLabel: db.Name}
+
+	c := &spec.Template.Spec.Containers[0]
+	c.Name = name
+	c.Command = append([]string{"sh", "-c", replicaScript, "replica"}, c.Args...)
+	c.Args = nil
+	c.Lifecycle = nil
+	c.Env = append(c.Env,
+		replicationPasswordEnv(db, "PGPASSWORD"),
+		corev1.EnvVar{Name: "PRIMARY_HOST", Value: db.Name},
+		corev1.EnvVar{Name: "PRIMARY_PORT", Value: fmt.Sprint(provider.DatabasePort(db, 0))},
+	)
+	c.VolumeMounts[0].Name = fmt.Sprintf("%s-data", name)
+	spec.Template.Spec.Volumes = []corev1.Volume{
+		{
+			Name:         fmt.Sprintf("%s-data", name),
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
+	}
+	return spec
+}
+
+// replicationSecretName is the secret with the password of the replicator role, it is kept apart from the master
+// password so the replicas can't log in as the database user
+func replicationSecretName(db *databasev1.Database) string {
+	return db.Name + "-replication"
+}
+
+// ensureReplicationSecret creates the replication secret with a random password, the secret is owned by the
+// database so it is garbage collected together with it
+func (l *Local) ensureReplicationSecret(ctx context.Context, db *databasev1.Database) error {
+	name := replicationSecretName(db)
+	secrets := l.kc.CoreV1().Secrets(db.Namespace)
+	_, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	password, err := kube.GeneratePassword(db.Spec.Engine)
+	if err != nil {
+		return err
+	}
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       db.Namespace,
+			Annotations:     map[string]string{"origin": "k8s-rds"},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(db, databasev1.SchemeGroupVersion.WithKind("Database"))},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{replicationPasswordKey: []byte(password)},
+	}
+	log.Printf("creating replication secret %v for %v", name, db.Name)
+	_, err = secrets.Create(ctx, s, metav1.CreateOptions{})
+	return err
+}
+
+// replicationPasswordEnv is the environment variable name holding the password of the replication secret
+func replicationPasswordEnv(db *databasev1.Database, name string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: replicationSecretName(db)},
+				Key:                  replicationPasswordKey,
+			},
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package local
+
+import (
+	"context"
+	"testing"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEnsureReadReplicas(t *testing.T) {
+	ctx := context.Background()
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			DBName:       "mydb",
+			Engine:       "postgres",
+			Username:     "myuser",
+			Size:         10,
+			Password:     databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
+			ReadReplicas: &databasev1.ReadReplicas{Count: 2},
+		},
+	}
+	kc := testclient.NewSimpleClientset()
+	l, err := New(db, kc, "")
+	assert.NoError(t, err)
+
+	// replicas on other nodes can't connect without the network of the pods
+	_, err = l.EnsureReadReplicas(ctx, db)
+	assert.Error(t, err)
+
+	l.ReplicationCIDR = "10.244.0.0/16"
+	replicas, err := l.EnsureReadReplicas(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, replicas, 2)
+	assert.Equal(t, "mydb-ro-0", replicas[0].Name)
+	assert.Equal(t, "mydb-ro-0", replicas[0].Hostname)
+	assert.Equal(t, "creating", replicas[0].State)
+
+	d, err := kc.AppsV1().Deployments("default").Get(ctx, "mydb-ro-1", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "mydb", d.Labels[replicaOfLabel])
+	assert.Equal(t, "mydb-ro-1", d.Spec.Selector.MatchLabels["db"])
+	c := d.Spec.Template.Spec.Containers[0]
+	assert.Nil(t, c.Lifecycle)
+	assert.Contains(t, c.Env, v1.EnvVar{Name: "PRIMARY_HOST", Value: "mydb"})
+	assert.Contains(t, c.Env, replicationPasswordEnv(db, "PGPASSWORD"))
+	assert.Equal(t, "mydb-replication", replicationPasswordEnv(db, "PGPASSWORD").ValueFrom.SecretKeyRef.Name)
+	assert.NotNil(t, d.Spec.Template.Spec.Volumes[0].EmptyDir)
+
+	// scaling down removes the replicas above the count
+	db.Spec.ReadReplicas.Count = 1
+	replicas, err = l.EnsureReadReplicas(ctx, db)
+	assert.NoError(t, err)
+	assert.Len(t, replicas, 1)
+	_, err = kc.AppsV1().Deployments("default").Get(ctx, "mydb-ro-1", meta_v1.GetOptions{})
+	assert.Error(t, err)
+	_, err = kc.AppsV1().Deployments("default").Get(ctx, "mydb-ro-0", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+
+	db.Spec.Engine = "mysql"
+	_, err = l.EnsureReadReplicas(ctx, db)
+	assert.Error(t, err)
+}
@@ -0,0 +1,127 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// snapshotOfLabel is set on the pg_dump jobs of the snapshots, the value is the name of the database
+const snapshotOfLabel = "snapshot-of"
+
+// dumpScript writes the dump under a temporary name so an interrupted run never leaves a partial dump behind
+const dumpScript = `pg_dump -h "$PGHOST" -p "$PGPORT" -U "$PGUSER" -Fc -f /backup/dump.tmp "$PGDATABASE" && mv /backup/dump.tmp /backup/dump`
+
+// CreateSnapshot runs a job with pg_dump writing the database to a PVC, the PVC has the size of the database
+func (l *Local) CreateSnapshot(ctx context.Context, db *databasev1.Database, s *databasev1.DatabaseSnapshot) (*provider.SnapshotInfo, error) {
+	if db.Spec.Engine != "postgres" {
+		return nil, fmt.Errorf("snapshots are only supported for postgres by the local provider, not %v", db.Spec.Engine)
+	}
+	name := dumpName(s)
+	// the claim might only be bound once the pod of the job is scheduled, so it isn't waited for
+	if err := l.createPVC(ctx, name, s.Namespace, db.Spec.Size, false); err != nil {
+		return nil, err
+	}
+
+	jobs := l.kc.BatchV1().Jobs(s.Namespace)
+	job, err := jobs.Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		job = &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{snapshotOfLabel: db.Name}},
+			Spec:       toDumpJobSpec(db, name, l.repository),
+		}
+		log.Printf("creating snapshot %v of database %v", name, db.Name)
+		job, err = jobs.Create(ctx, job, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return nil, fmt.Errorf("pg_dump of database %v failed: %v", db.Name, c.Message)
+		}
+	}
+	info := &provider.SnapshotInfo{ID: name, State: "creating", Size: db.Spec.Size}
+	if job.Status.Succeeded > 0 {
+		info.State = provider.SnapshotAvailable
+		info.Progress = 100
+		if job.Status.CompletionTime != nil {
+			info.CreationTime = &job.Status.CompletionTime.Time
+		}
+	}
+	return info, nil
+}
+
+// DeleteSnapshot deletes the pg_dump job and the PVC holding the dump
+func (l *Local) DeleteSnapshot(ctx context.Context, s *databasev1.DatabaseSnapshot) error {
+	name := s.Status.SnapshotID
+	if name == "" {
+		return nil
+	}
+	log.Printf("deleting snapshot %v", name)
+	background := metav1.DeletePropagationBackground
+	err := l.kc.BatchV1().Jobs(s.Namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &background})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	err = l.kc.CoreV1().PersistentVolumeClaims(s.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// dumpName is the name of the job and the PVC of a snapshot
+func dumpName(s *databasev1.DatabaseSnapshot) string {
+	return s.Name + "-dump"
+}
+
+// toDumpJobSpec returns the job running pg_dump against the service of the database
+func toDumpJobSpec(db *databasev1.Database, name string, repository string) batchv1.JobSpec {
+	password := corev1.EnvVarSource{
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: db.Spec.Password.Name},
+			Key:                  db.Spec.Password.Key,
+		},
+	}
+	return batchv1.JobSpec{
+		BackoffLimit: int32Ptr(3),
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{snapshotOfLabel: db.Name}},
+			Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers: []corev1.Container{
+					{
+						Name:    "pg-dump",
+						Image:   image(db, repository),
+						Command: []string{"sh", "-c", dumpScript},
+						Env: []corev1.EnvVar{
+							{Name: "PGHOST", Value: db.Name},
+							{Name: "PGPORT", Value: fmt.Sprint(provider.DatabasePort(db, 0))},
+							{Name: "PGUSER", Value: db.Spec.Username},
+							{Name: "PGDATABASE", Value: db.Spec.DBName},
+							{Name: "PGPASSWORD", ValueFrom: &password},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "dump", MountPath: "/backup"}},
+					},
+				},
+				Volumes: []corev1.Volume{
+					{
+						Name: "dump",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package local
+
+import (
+	"context"
+	"testing"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			DBName:   "mydb",
+			Engine:   "postgres",
+			Username: "myuser",
+			Size:     10,
+			Password: databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
+		},
+	}
+	s := &databasev1.DatabaseSnapshot{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "nightly", Namespace: "default"},
+		Spec:       databasev1.DatabaseSnapshotSpec{Database: "mydb"},
+	}
+	kc := testclient.NewSimpleClientset()
+	l, err := New(db, kc, "")
+	assert.NoError(t, err)
+
+	info, err := l.CreateSnapshot(ctx, db, s)
+	assert.NoError(t, err)
+	assert.Equal(t, "nightly-dump", info.ID)
+	assert.Equal(t, "creating", info.State)
+	assert.Equal(t, int64(10), info.Size)
+
+	job, err := kc.BatchV1().Jobs("default").Get(ctx, "nightly-dump", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "nightly-dump", job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	assert.Contains(t, job.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{Name: "PGHOST", Value: "mydb"})
+	_, err = kc.CoreV1().PersistentVolumeClaims("default").Get(ctx, "nightly-dump", meta_v1.GetOptions{})
+	assert.NoError(t, err)
+
+	job.Status.Succeeded = 1
+	_, err = kc.BatchV1().Jobs("default").UpdateStatus(ctx, job, meta_v1.UpdateOptions{})
+	assert.NoError(t, err)
+	info, err = l.CreateSnapshot(ctx, db, s)
+	assert.NoError(t, err)
+	assert.Equal(t, provider.SnapshotAvailable, info.State)
+	assert.Equal(t, int32(100), info.Progress)
+
+	s.Status.SnapshotID = info.ID
+	assert.NoError(t, l.DeleteSnapshot(ctx, s))
+	_, err = kc.CoreV1().PersistentVolumeClaims("default").Get(ctx, "nightly-dump", meta_v1.GetOptions{})
+	assert.Error(t, err)
+	// deleting again is a noop
+	assert.NoError(t, l.DeleteSnapshot(ctx, s))
+
+	db.Spec.Engine = "mysql"
+	_, err = l.CreateSnapshot(ctx, db, s)
+	assert.Error(t, err)
+}
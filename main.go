@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"reflect"
 	"time"
 
-	"github.com/sorenmat/k8s-rds/client"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/client/clientset/versioned"
+	databaseclient "github.com/sorenmat/k8s-rds/client/clientset/versioned/typed/database/v1"
 	"github.com/sorenmat/k8s-rds/crd"
 	"github.com/sorenmat/k8s-rds/kube"
 	"github.com/sorenmat/k8s-rds/local"
@@ -14,14 +18,22 @@ import (
 	"github.com/sorenmat/k8s-rds/rds"
 	"github.com/spf13/cobra"
 	apiextcs "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
 
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-const Failed = "Failed"
+// States of a database, while the provider is working on it the state reported by the provider is used
+const (
+	Failed    = "Failed"
+	Creating  = "Creating"
+	Available = "Available"
+	Deleting  = "Deleting"
+)
 
 // return rest config, if path not specified assume in cluster config
 func getClientConfig(kubeconfig string) (*rest.Config, error) {
@@ -34,54 +46,73 @@ func getClientConfig(kubeconfig string) (*rest.Config, error) {
 	return cfg, err
 }
 
-func getKubectl() (*kubernetes.Clientset, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		log.Println("Appears we are not running in a cluster")
-		config, err = clientcmd.BuildConfigFromFlags("", kube.Config())
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		log.Println("Seems like we are running in a Kubernetes cluster!!")
-	}
-
-	kubectl, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, err
-	}
-	return kubectl, nil
+// options holds the command line configuration of the operator
+type options struct {
+	provider          string
+	excludeNamespaces []string
+	includeNamespaces []string
+	repository        string
+	replicationCIDR   string
+	workers           int
+	resyncPeriod      time.Duration
+	retryBaseDelay    time.Duration
+	retryMaxDelay     time.Duration
+	requeueAfter      time.Duration
+	awsWaitTimeout    time.Duration
+	awsPollInterval   time.Duration
+	aws               rds.Options
+	leaderElect       bool
+	leaseName         string
+	leaseNamespace    string
+	leaseDuration     time.Duration
+	renewDeadline     time.Duration
+	retryPeriod       time.Duration
 }
 
 func main() {
-	var (
-		_provider         string
-		excludeNamespaces []string
-		includeNamespaces []string
-		repository        string
-	)
+	var opts options
 	var rootCmd = &cobra.Command{
 		Use:   "k8s-rds",
 		Short: "Kubernetes database provisioner",
 		Long:  `Kubernetes database provisioner`,
 		Run: func(cmd *cobra.Command, args []string) {
-			execute(_provider, excludeNamespaces, includeNamespaces, repository)
+			if len(opts.excludeNamespaces) > 0 && len(opts.includeNamespaces) > 0 {
+				panic("--include-namespaces and --exclude-namespaces are mutually exclusive")
+			}
+			execute(opts)
 		},
 	}
-	rootCmd.PersistentFlags().StringVar(&_provider, "provider", "aws", "Type of provider (aws, local)")
-	rootCmd.PersistentFlags().StringSliceVar(&excludeNamespaces, "exclude-namespaces", nil, "list of namespaces to exclude. Mutually exclusive with --include-namespaces.")
-	rootCmd.PersistentFlags().StringSliceVar(&includeNamespaces, "include-namespaces", nil, "list of namespaces to include. Mutually exclusive with --exclude-namespaces.")
-	rootCmd.PersistentFlags().StringVar(&repository, "repository", "", "Docker image repository, default is hub.docker.com)")
-	if len(excludeNamespaces) > 0 && len(includeNamespaces) > 0 {
-		panic("--include-namespaces and --exclude-namespaces are mutually exclusive")
-	}
+	rootCmd.PersistentFlags().StringVar(&opts.provider, "provider", "aws", "Type of provider (aws, local)")
+	rootCmd.PersistentFlags().StringSliceVar(&opts.excludeNamespaces, "exclude-namespaces", nil, "list of namespaces to exclude. Mutually exclusive with --include-namespaces.")
+	rootCmd.PersistentFlags().StringSliceVar(&opts.includeNamespaces, "include-namespaces", nil, "list of namespaces to include. Mutually exclusive with --exclude-namespaces.")
+	rootCmd.PersistentFlags().StringVar(&opts.repository, "repository", "", "Docker image repository, default is hub.docker.com)")
+	rootCmd.PersistentFlags().StringVar(&opts.replicationCIDR, "local-replication-cidr", "", "Network the read replicas of the local provider connect from, ex the pod CIDR of the cluster. Required for read replicas with the local provider")
+	rootCmd.PersistentFlags().IntVar(&opts.workers, "workers", 2, "Number of databases reconciled in parallel")
+	rootCmd.PersistentFlags().DurationVar(&opts.resyncPeriod, "resync-period", 2*time.Minute, "How often all databases are reconciled")
+	rootCmd.PersistentFlags().DurationVar(&opts.retryBaseDelay, "retry-base-delay", 5*time.Second, "Delay before the first retry of a failed reconcile, doubled on every failure")
+	rootCmd.PersistentFlags().DurationVar(&opts.retryMaxDelay, "retry-max-delay", 5*time.Minute, "Maximum delay between retries of a failed reconcile")
+	rootCmd.PersistentFlags().DurationVar(&opts.requeueAfter, "requeue-after", 30*time.Second, "Delay before checking a database again while the provider is still working on it")
+	rootCmd.PersistentFlags().DurationVar(&opts.awsWaitTimeout, "aws-wait-timeout", time.Minute, "How long to wait on a RDS instance before checking back later")
+	rootCmd.PersistentFlags().DurationVar(&opts.awsPollInterval, "aws-poll-interval", 10*time.Second, "How often the state of a RDS instance is polled while waiting on it")
+	rootCmd.PersistentFlags().StringVar(&opts.aws.Region, "aws-region", "", "AWS region of the databases, defaults to AWS_REGION, the region label of the nodes or the instance metadata service")
+	rootCmd.PersistentFlags().StringVar(&opts.aws.VpcID, "aws-vpc-id", "", "VPC of the databases, defaults to the VPC of the nodes")
+	rootCmd.PersistentFlags().StringSliceVar(&opts.aws.Subnets, "aws-subnets", nil, "Subnets of the shared subnet group of private databases, defaults to the private subnets of the VPC")
+	rootCmd.PersistentFlags().StringSliceVar(&opts.aws.PublicSubnets, "aws-public-subnets", nil, "Subnets of the shared subnet group of publicly accessible databases, defaults to the public subnets of the VPC")
+	rootCmd.PersistentFlags().StringSliceVar(&opts.aws.SecurityGroups, "aws-security-groups", nil, "Security groups of the workloads allowed to reach the databases, defaults to the security groups of the nodes")
+	rootCmd.PersistentFlags().StringVar(&opts.aws.EndpointURL, "aws-endpoint-url", "", "URL replacing the endpoints of the AWS APIs, ex for a local AWS emulator")
+	rootCmd.PersistentFlags().BoolVar(&opts.leaderElect, "leader-elect", false, "Use leader election so only one of several replicas reconciles databases")
+	rootCmd.PersistentFlags().StringVar(&opts.leaseName, "leader-elect-lease-name", "k8s-rds", "Name of the Lease object used for leader election")
+	rootCmd.PersistentFlags().StringVar(&opts.leaseNamespace, "leader-elect-lease-namespace", "", "Namespace of the Lease object, defaults to the namespace the operator is running in")
+	rootCmd.PersistentFlags().DurationVar(&opts.leaseDuration, "leader-elect-lease-duration", 15*time.Second, "How long standby replicas wait before trying to take over from a leader that stopped renewing")
+	rootCmd.PersistentFlags().DurationVar(&opts.renewDeadline, "leader-elect-renew-deadline", 10*time.Second, "How long the leader keeps trying to renew the lease before giving up leadership")
+	rootCmd.PersistentFlags().DurationVar(&opts.retryPeriod, "leader-elect-retry-period", 2*time.Second, "How often replicas try to acquire or renew the lease")
 	err := rootCmd.Execute()
 	if err != nil {
 		panic(err)
 	}
 }
 
-func execute(dbprovider string, excludeNamespaces, includeNamespaces []string, repository string) {
+func execute(opts options) {
 	log.Println("Starting k8s-rds")
 
 	config, err := getClientConfig(kube.Config())
@@ -95,141 +126,204 @@ func execute(dbprovider string, excludeNamespaces, includeNamespaces []string, r
 		panic(err.Error())
 	}
 
-	// note: if the CRD exist our CreateCRD function is set to exit without an error
+	// note: if the CRD exist our CreateCRD function updates it when the definition changed
 	err = crd.CreateCRD(clientset)
 	if err != nil {
 		panic(err)
 	}
 
-	// Create a new clientset which include our CRD schema
-	crdcs, scheme, err := crd.NewClient(config)
+	dbclientset, err := versioned.NewForConfig(config)
 	if err != nil {
 		panic(err)
 	}
 
-	// Create a CRD client interface
-	crdclient := client.CrdClient(crdcs, scheme, "")
-	log.Println("Watching for database changes...")
-	_, controller := cache.NewInformer(
-		crdclient.NewListWatch(),
-		&crd.Database{},
-		time.Minute*2,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				db := obj.(*crd.Database)
-				if excluded(db, excludeNamespaces, includeNamespaces) {
-					return
-				}
-				_client := client.CrdClient(crdcs, scheme, db.Namespace) // add the database namespace to the client
-				err = handleCreateDatabase(context.Background(), db, _client, dbprovider, repository)
-				if err != nil {
-					log.Printf("database creation failed: %v", err)
-					err := updateStatus(context.Background(), db, crd.DatabaseStatus{Message: fmt.Sprintf("%v", err), State: Failed}, _client)
-					if err != nil {
-						log.Printf("database CRD status update failed: %v", err)
-					}
-				}
-			},
-			DeleteFunc: func(obj interface{}) {
-				ctx := context.Background()
-
-				db := obj.(*crd.Database)
-				if excluded(db, excludeNamespaces, includeNamespaces) {
-					return
-				}
-				log.Printf("deleting database: %s \n", db.Name)
-
-				r, err := getProvider(db, dbprovider, repository)
-				if err != nil {
-					log.Println(err)
-					return
-				}
-
-				err = r.DeleteDatabase(ctx, db)
-				if err != nil {
-					log.Println(err)
-				}
-
-				err = r.DeleteService(ctx, db.Namespace, db.Name)
-				if err != nil {
-					log.Println(err)
-				}
-				log.Printf("Deletion of database %v done\n", db.Name)
-			},
-			UpdateFunc: func(oldObj, newObj interface{}) {
-			},
-		},
-	)
+	kubectl, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		panic(err)
+	}
 
-	stop := make(chan struct{})
-	go controller.Run(stop)
+	c := NewController(dbclientset, kubectl, opts)
 
-	// Wait forever
-	select {}
-}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log.Println("Watching for database changes...")
+	c.StartInformer(ctx.Done())
 
-func getProvider(db *crd.Database, dbprovider, repository string) (provider.DatabaseProvider, error) {
-	kubectl, err := getKubectl()
-	if err != nil {
-		log.Println(err)
-		return nil, err
+	if !opts.leaderElect {
+		c.Run(opts.workers, ctx.Done())
+		return
 	}
-	_provider := dbprovider
-	if db.Spec.Provider != "" {
-		_provider = db.Spec.Provider
+
+	err = runLeaderElection(ctx, kubectl, opts, func(ctx context.Context) {
+		c.Run(opts.workers, ctx.Done())
+	})
+	if err != nil {
+		panic(err)
 	}
+}
+
+func (c *Controller) getProvider(db *databasev1.Database) (provider.DatabaseProvider, error) {
+	_provider := c.providerName(db)
 	switch _provider {
 	case "aws":
-		r, err := rds.New(context.Background(), db, kubectl)
+		opts, err := c.awsOptions(context.Background(), c.kubeclient, db.Namespace, db.Spec.ProviderConfigRef)
+		if err != nil {
+			return nil, err
+		}
+		r, err := rds.New(context.Background(), db, c.kubeclient, opts)
 		if err != nil {
 			return nil, err
 		}
+		r.WaitTimeout = c.opts.awsWaitTimeout
+		r.PollInterval = c.opts.awsPollInterval
 		return r, nil
 
 	case "local":
-		r, err := local.New(db, kubectl, repository)
+		r, err := local.New(db, c.kubeclient, c.opts.repository)
 		if err != nil {
 			return nil, err
 		}
+		r.ReplicationCIDR = c.opts.replicationCIDR
 		return r, nil
 	}
-	return nil, fmt.Errorf("unable to find provider for %v", dbprovider)
+	return nil, fmt.Errorf("unable to find provider for %v", _provider)
 }
 
-func handleCreateDatabase(ctx context.Context, db *crd.Database, crdclient *client.Crdclient, dbprovider, repository string) error {
-	// we don't need to skip when it is a local provider without running pod
-	if db.Status.State == "Created" && dbprovider == "aws" {
-		log.Printf("database %v already created, skipping\n", db.Name)
-		return nil
+// providerName returns the provider to use for the database, the spec overrides the operator default
+func (c *Controller) providerName(db *databasev1.Database) string {
+	if db.Spec.Provider != "" {
+		return db.Spec.Provider
+	}
+	return c.opts.provider
+}
+
+// reconcileDatabase brings the database at the provider in line with the object, errors are recorded in the status
+func (c *Controller) reconcileDatabase(ctx context.Context, db *databasev1.Database, crdclient databaseclient.DatabaseInterface) error {
+	if db.DeletionTimestamp != nil {
+		return c.handleDeleteDatabase(ctx, db, crdclient)
+	}
+
+	if db.Status.State != Available {
+		err := c.handleCreateDatabase(ctx, db, crdclient)
+		if pending, ok := provider.IsPending(err); ok {
+			serr := updateStatus(ctx, db, crdclient, setPending(pending))
+			if serr != nil {
+				log.Printf("database CRD status update failed: %v", serr)
+			}
+			return err
+		}
+		if err != nil {
+			log.Printf("database creation failed: %v", err)
+			serr := updateStatus(ctx, db, crdclient, setFailed(err))
+			if serr != nil {
+				log.Printf("database CRD status update failed: %v", serr)
+			}
+		}
+		return err
+	}
+
+	err := c.handleUpdateDatabase(ctx, db, crdclient)
+	if pending, ok := provider.IsPending(err); ok {
+		// the next reconcile goes through the create path, which waits for the database to be available again
+		serr := updateStatus(ctx, db, crdclient, setPending(pending))
+		if serr != nil {
+			log.Printf("database CRD status update failed: %v", serr)
+		}
+		return err
+	}
+	if err != nil {
+		log.Printf("database update failed: %v", err)
+		serr := updateStatus(ctx, db, crdclient, setUpdateFailed(err))
+		if serr != nil {
+			log.Printf("database CRD status update failed: %v", serr)
+		}
+	}
+	return err
+}
+
+func (c *Controller) handleCreateDatabase(ctx context.Context, db *databasev1.Database, crdclient databaseclient.DatabaseInterface) error {
+	// make sure we get to clean up the provider resources when the database is deleted
+	if !hasFinalizer(db) {
+		err := addFinalizer(ctx, db, databaseFinalizers(crdclient))
+		if err != nil {
+			return fmt.Errorf("unable to add finalizer: %v", err)
+		}
 	}
-	// validate dbname is only alpha numeric
-	// This check is needed in case local provider with already created db
-	if db.Status.State != "Created" {
-		err := updateStatus(context.Background(), db, crd.DatabaseStatus{Message: "Creating", State: "Creating"}, crdclient)
+	if db.Status.State == "" || db.Status.State == Failed {
+		err := updateStatus(ctx, db, crdclient, setCreating(c.providerName(db)))
 		if err != nil {
 			return fmt.Errorf("database CRD status update failed: %v", err)
 		}
 	}
 
-	log.Println("trying to get kubectl")
+	restore := provider.RestoreSource(db)
+	if restore != "" && db.Status.RestoredFrom != restore {
+		err := updateStatus(ctx, db, crdclient, setRestoredFrom(restore))
+		if err != nil {
+			return fmt.Errorf("database CRD status update failed: %v", err)
+		}
+	}
 
-	r, err := getProvider(db, dbprovider, repository)
+	r, err := c.getProvider(db)
 	if err != nil {
 		return err
 	}
 
-	hostname, err := r.CreateDatabase(ctx, db)
+	err = r.EnsurePassword(ctx, db)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Creating service '%v' for %v\n", db.Name, hostname)
-	err = r.CreateService(ctx, db.Namespace, hostname, db.Name)
+	// returns a PendingError until the database is available, so the service is only created once we have an endpoint
+	info, err := r.CreateDatabase(ctx, db)
 	if err != nil {
 		return err
 	}
 
-	err = updateStatus(context.Background(), db, crd.DatabaseStatus{Message: "Created", State: "Created"}, crdclient)
+	password, err := r.GetSecret(ctx, db.Namespace, db.Spec.Password.Name, db.Spec.Password.Key)
+	if err != nil {
+		return err
+	}
+	hash := passwordHash(db, password)
+	if restore != "" && db.Status.PasswordHash == "" {
+		// the restored database has the password of the snapshot or of the source database
+		log.Printf("Setting the password of restored database %v\n", db.Name)
+		err = r.RotatePassword(ctx, db, password)
+		if _, ok := provider.IsPending(err); err != nil && !ok {
+			return err
+		}
+		serr := updateStatus(ctx, db, crdclient, setPasswordHash(hash))
+		if serr != nil {
+			return serr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Creating service '%v' for %v\n", db.Name, info.Hostname)
+	err = r.CreateService(ctx, db.Namespace, info.Hostname, db.Name, provider.ServicePort(db, info.Port))
+	if err != nil {
+		return err
+	}
+
+	err = r.CreateConnectionSecret(ctx, db, provider.DatabasePort(db, info.Port))
+	if err != nil {
+		return err
+	}
+
+	err = reconcileReadReplicas(ctx, r, db, crdclient)
+	if err != nil {
+		return err
+	}
+
+	err = updateStatus(ctx, db, crdclient, func(s *databasev1.DatabaseStatus, generation int64) {
+		// the generation that was applied, a spec changed in the meantime is applied by the next update
+		setAvailable(info)(s, db.Generation)
+		if s.PasswordHash == "" {
+			setPasswordHash(hash)(s, generation)
+		}
+	})
 	if err != nil {
 		return err
 	}
@@ -237,27 +331,290 @@ func handleCreateDatabase(ctx context.Context, db *crd.Database, crdclient *clie
 	return nil
 }
 
-func updateStatus(ctx context.Context, db *crd.Database, status crd.DatabaseStatus, crdclient *client.Crdclient) error {
-	db, err := crdclient.Get(ctx, db.Name)
+// handleDeleteDatabase removes the database and service from the provider, the finalizer is only
+// removed once the cleanup succeeded so a failed deletion is retried
+func (c *Controller) handleDeleteDatabase(ctx context.Context, db *databasev1.Database, crdclient databaseclient.DatabaseInterface) error {
+	if !hasFinalizer(db) {
+		return nil
+	}
+	log.Printf("deleting database: %s \n", db.Name)
+
+	if db.Status.State != Deleting {
+		err := updateStatus(ctx, db, crdclient, setDeleting("Deleting", nil))
+		if err != nil {
+			return fmt.Errorf("database CRD status update failed: %v", err)
+		}
+	}
+
+	r, err := c.getProvider(db)
+	if err == nil {
+		err = deleteDatabase(ctx, r, db)
+	}
+	if pending, ok := provider.IsPending(err); ok {
+		serr := updateStatus(ctx, db, crdclient, setDeleting(pending.Message, nil))
+		if serr != nil {
+			log.Printf("database CRD status update failed: %v", serr)
+		}
+		return err
+	}
 	if err != nil {
+		serr := updateStatus(ctx, db, crdclient, setDeleting("Deletion failed, will retry", err))
+		if serr != nil {
+			log.Printf("database CRD status update failed: %v", serr)
+		}
 		return err
 	}
 
-	db.Status = status
-	_, err = crdclient.Update(ctx, db)
+	err = removeFinalizer(ctx, db, databaseFinalizers(crdclient))
+	if err != nil {
+		return fmt.Errorf("unable to remove finalizer: %v", err)
+	}
+	log.Printf("Deletion of database %v done\n", db.Name)
+	return nil
+}
+
+// deleteDatabase deletes the database, its read replicas and the services pointing to them
+func deleteDatabase(ctx context.Context, r provider.DatabaseProvider, db *databasev1.Database) error {
+	err := r.DeleteDatabase(ctx, db)
 	if err != nil {
 		return err
 	}
+	for _, replica := range db.Status.ReadReplicas {
+		err = r.DeleteService(ctx, db.Namespace, replica.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return r.DeleteService(ctx, db.Namespace, db.Name)
+}
+
+// reconcileReadReplicas creates and removes the read replicas of the database and their services, the replicas
+// are recorded in the status. A provider.PendingError is returned until all replicas are available
+func reconcileReadReplicas(ctx context.Context, r provider.DatabaseProvider, db *databasev1.Database, crdclient databaseclient.DatabaseInterface) error {
+	if provider.ReplicaCount(db) == 0 && len(db.Status.ReadReplicas) == 0 {
+		return nil
+	}
+	replicas, err := r.EnsureReadReplicas(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	wanted := map[string]bool{}
+	for _, replica := range replicas {
+		wanted[replica.Name] = true
+	}
+	for _, old := range db.Status.ReadReplicas {
+		if wanted[old.Name] {
+			continue
+		}
+		log.Printf("Deleting service '%v' of removed read replica\n", old.Name)
+		err = r.DeleteService(ctx, db.Namespace, old.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	var pending *provider.PendingError
+	statuses := []databasev1.ReplicaStatus{}
+	for _, replica := range replicas {
+		statuses = append(statuses, databasev1.ReplicaStatus{Name: replica.Name, Endpoint: replica.Hostname, State: replica.State})
+		if replica.State != provider.ReplicaAvailable || replica.Hostname == "" {
+			pending = &provider.PendingError{State: replica.State, Message: fmt.Sprintf("waiting for read replica %v to become available", replica.Name)}
+			continue
+		}
+		err = r.CreateService(ctx, db.Namespace, replica.Hostname, replica.Name, provider.ServicePort(db, replica.Port))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = updateStatus(ctx, db, crdclient, setReadReplicas(statuses))
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		return pending
+	}
 	return nil
 }
 
-func excluded(db *crd.Database, excludeNamespaces, includeNamespaces []string) bool {
-	if len(excludeNamespaces) > 0 && stringInSlice(db.Namespace, excludeNamespaces) {
-		log.Printf("database %s is in excluded namespace %s. Ignoring...", db.Name, db.Namespace)
+// handleUpdateDatabase applies the spec to an already created database, the providers only
+// change what differs so this is safe to call on every resync
+func (c *Controller) handleUpdateDatabase(ctx context.Context, db *databasev1.Database, crdclient databaseclient.DatabaseInterface) error {
+	r, err := c.getProvider(db)
+	if err != nil {
+		return err
+	}
+
+	err = rotatePassword(ctx, r, db, crdclient, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = r.UpdateDatabase(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	// the password or the name of the secret might have changed
+	err = r.CreateConnectionSecret(ctx, db, provider.DatabasePort(db, db.Status.Port))
+	if err != nil {
+		return err
+	}
+
+	err = reconcileReadReplicas(ctx, r, db, crdclient)
+	if err != nil {
+		return err
+	}
+
+	// clear any error left by a previously failed update, the generation that was applied is recorded so a spec
+	// changed in the meantime is applied by the next update
+	return updateStatus(ctx, db, crdclient, func(s *databasev1.DatabaseStatus, generation int64) {
+		setAvailable(nil)(s, db.Generation)
+	})
+}
+
+// rotatePassword applies a changed password secret to the database, when spec.password.rotationInterval has passed
+// a generated password is replaced first. The hash of the applied password is kept in the status
+func rotatePassword(ctx context.Context, r provider.DatabaseProvider, db *databasev1.Database, crdclient databaseclient.DatabaseInterface, now time.Time) error {
+	password, err := r.GetSecret(ctx, db.Namespace, db.Spec.Password.Name, db.Spec.Password.Key)
+	if err != nil {
+		return err
+	}
+	hash := passwordHash(db, password)
+	// while the secret differs from the applied password it is retried as is, regenerating it again would leave
+	// the secret holding a password the database never got
+	if hash == db.Status.PasswordHash && rotationDue(db, now) {
+		log.Printf("password of database %v is due for rotation\n", db.Name)
+		err = r.RegeneratePassword(ctx, db)
+		if err != nil {
+			return err
+		}
+		password, err = r.GetSecret(ctx, db.Namespace, db.Spec.Password.Name, db.Spec.Password.Key)
+		if err != nil {
+			return err
+		}
+		hash = passwordHash(db, password)
+	}
+	if hash == db.Status.PasswordHash {
+		return nil
+	}
+	if db.Status.PasswordHash == "" {
+		// created before the operator recorded passwords, assume the secret matches the database
+		return updateStatus(ctx, db, crdclient, setPasswordHash(hash))
+	}
+
+	log.Printf("password secret of database %v changed, updating the master password\n", db.Name)
+	err = r.RotatePassword(ctx, db, password)
+	if _, ok := provider.IsPending(err); err != nil && !ok {
+		return err
+	}
+	serr := updateStatus(ctx, db, crdclient, setPasswordRotated(hash))
+	if serr != nil {
+		return serr
+	}
+	return err
+}
+
+// rotationDue reports whether the generated password of the database is older than spec.password.rotationInterval
+func rotationDue(db *databasev1.Database, now time.Time) bool {
+	interval := db.Spec.Password.RotationInterval
+	if !db.Spec.Password.Generate || interval == nil || interval.Duration <= 0 || db.Status.PasswordRotationTime == nil {
+		return false
+	}
+	return !now.Before(db.Status.PasswordRotationTime.Add(interval.Duration))
+}
+
+// specChanged reports whether the spec differs between two versions of the same database
+func specChanged(oldDB, db *databasev1.Database) bool {
+	return !reflect.DeepEqual(oldDB.Spec, db.Spec)
+}
+
+func hasFinalizer(obj metav1.Object) bool {
+	return stringInSlice(crd.Finalizer, obj.GetFinalizers())
+}
+
+// finalizerClient gets and merge patches objects of one kind by name, it lets the finalizer helpers work for
+// databases, clusters and snapshots alike
+type finalizerClient struct {
+	get   func(ctx context.Context, name string) (metav1.Object, error)
+	patch func(ctx context.Context, name string, patch []byte) error
+}
+
+func databaseFinalizers(crdclient databaseclient.DatabaseInterface) finalizerClient {
+	return finalizerClient{
+		get: func(ctx context.Context, name string) (metav1.Object, error) {
+			return crdclient.Get(ctx, name, metav1.GetOptions{})
+		},
+		patch: func(ctx context.Context, name string, patch []byte) error {
+			_, err := crdclient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	}
+}
+
+// addFinalizer adds the finalizer of the operator to the latest version of obj
+func addFinalizer(ctx context.Context, obj metav1.Object, client finalizerClient) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := client.get(ctx, obj.GetName())
+		if err != nil {
+			return err
+		}
+		if hasFinalizer(latest) {
+			return nil
+		}
+		patch, err := finalizerPatch(latest, append(latest.GetFinalizers(), crd.Finalizer))
+		if err != nil {
+			return err
+		}
+		return client.patch(ctx, obj.GetName(), patch)
+	})
+}
+
+// removeFinalizer removes the finalizer of the operator from the latest version of obj
+func removeFinalizer(ctx context.Context, obj metav1.Object, client finalizerClient) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := client.get(ctx, obj.GetName())
+		if err != nil {
+			return err
+		}
+		patch, err := finalizerPatch(latest, withoutFinalizer(latest))
+		if err != nil {
+			return err
+		}
+		return client.patch(ctx, obj.GetName(), patch)
+	})
+}
+
+// withoutFinalizer returns the finalizers of obj except the one of the operator
+func withoutFinalizer(obj metav1.Object) []string {
+	var finalizers []string
+	for _, f := range obj.GetFinalizers() {
+		if f != crd.Finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	return finalizers
+}
+
+// finalizerPatch is a merge patch that only sets the finalizers of obj, the resourceVersion in the patch makes the
+// API server reject it with a conflict when obj has been changed in the meantime
+func finalizerPatch(obj metav1.Object, finalizers []string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers,
+			"resourceVersion": obj.GetResourceVersion(),
+		},
+	})
+}
+
+func excluded(obj metav1.Object, excludeNamespaces, includeNamespaces []string) bool {
+	if len(excludeNamespaces) > 0 && stringInSlice(obj.GetNamespace(), excludeNamespaces) {
+		log.Printf("%s is in excluded namespace %s. Ignoring...", obj.GetName(), obj.GetNamespace())
 		return true
 	}
-	if len(includeNamespaces) > 0 && !stringInSlice(db.Namespace, includeNamespaces) {
-		log.Printf("database %s is in a non included namespace %s. Ignoring...", db.Name, db.Namespace)
+	if len(includeNamespaces) > 0 && !stringInSlice(obj.GetNamespace(), includeNamespaces) {
+		log.Printf("%s is in a non included namespace %s. Ignoring...", obj.GetName(), obj.GetNamespace())
 		return true
 	}
 	return false
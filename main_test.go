@@ -1,47 +1,58 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/client/clientset/versioned/fake"
 	"github.com/sorenmat/k8s-rds/crd"
+	"github.com/sorenmat/k8s-rds/kube"
+	"github.com/sorenmat/k8s-rds/local"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestExcluded(t *testing.T) {
 	tests := []struct {
 		name     string
-		db       *crd.Database
+		db       *databasev1.Database
 		exclNS   []string
 		inclNS   []string
 		excluded bool
 	}{
 		{
 			name:     "no excluded or included namespaces",
-			db:       &crd.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
 			excluded: false,
 		},
 		{
 			name:     "namespace not in excluded namespaces",
-			db:       &crd.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
 			exclNS:   []string{"test"},
 			excluded: false,
 		},
 		{
 			name:     "namespace not in included namespaces",
-			db:       &crd.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
 			inclNS:   []string{"test"},
 			excluded: true,
 		},
 		{
 			name:     "namespace in excluded namespaces",
-			db:       &crd.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
 			exclNS:   []string{"default"},
 			excluded: true,
 		},
 		{
 			name:     "namespace in included namespaces",
-			db:       &crd.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
 			inclNS:   []string{"default"},
 			excluded: false,
 		},
@@ -86,3 +97,238 @@ func TestStringInSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestSpecChanged(t *testing.T) {
+	oldDB := &databasev1.Database{Spec: databasev1.DatabaseSpec{Class: "db.t2.micro", Size: 20}}
+
+	if specChanged(oldDB, &databasev1.Database{Spec: databasev1.DatabaseSpec{Class: "db.t2.micro", Size: 20}, Status: databasev1.DatabaseStatus{State: "Created"}}) {
+		t.Errorf("status change should not be seen as a spec change")
+	}
+	if !specChanged(oldDB, &databasev1.Database{Spec: databasev1.DatabaseSpec{Class: "db.t2.large", Size: 20}}) {
+		t.Errorf("class change should be seen as a spec change")
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	rotated := metav1.NewTime(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	db := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{Password: databasev1.PasswordSecret{
+			Generate:         true,
+			RotationInterval: &metav1.Duration{Duration: 24 * time.Hour},
+		}},
+		Status: databasev1.DatabaseStatus{PasswordRotationTime: &rotated},
+	}
+	if rotationDue(db, rotated.Add(time.Hour)) {
+		t.Errorf("rotation should not be due before the interval passed")
+	}
+	if !rotationDue(db, rotated.Add(25*time.Hour)) {
+		t.Errorf("rotation should be due after the interval passed")
+	}
+	db.Spec.Password.Generate = false
+	if rotationDue(db, rotated.Add(25*time.Hour)) {
+		t.Errorf("passwords provided by the user should not be rotated")
+	}
+}
+
+// failingRotation is a provider that can't change the master password
+type failingRotation struct {
+	*local.Local
+	err       error
+	rotations int
+}
+
+func (p *failingRotation) RotatePassword(ctx context.Context, db *databasev1.Database, password string) error {
+	p.rotations++
+	return p.err
+}
+
+func TestRotatePasswordRetriesFailedRotation(t *testing.T) {
+	ctx := context.Background()
+	rotated := metav1.NewTime(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	db := &databasev1.Database{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "1234"},
+		Spec: databasev1.DatabaseSpec{Engine: "postgres", Password: databasev1.PasswordSecret{
+			SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "pw"}, Key: "password"},
+			Generate:          true,
+			RotationInterval:  &metav1.Duration{Duration: 24 * time.Hour},
+		}},
+	}
+	db.Status.PasswordRotationTime = &rotated
+	db.Status.PasswordHash = passwordHash(db, "old")
+	kc := kubefake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pw", Namespace: "default", Annotations: map[string]string{kube.GeneratedForAnnotation: "test"}},
+		Data:       map[string][]byte{"password": []byte("old")},
+	})
+	l, err := local.New(db, kc, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &failingRotation{Local: l, err: errors.New("throttled")}
+	crdclient := fake.NewSimpleClientset(db).DatabaseV1().Databases("default")
+	now := rotated.Add(25 * time.Hour)
+
+	if err := rotatePassword(ctx, r, db, crdclient, now); err == nil {
+		t.Fatal("expected the failed rotation to be returned")
+	}
+	regenerated, err := r.GetSecret(ctx, "default", "pw", "password")
+	if err != nil || regenerated == "old" {
+		t.Fatalf("expected a regenerated password, got %v", err)
+	}
+
+	// the retry applies the same password instead of generating another one
+	if err := rotatePassword(ctx, r, db, crdclient, now); err == nil {
+		t.Fatal("expected the failed rotation to be returned")
+	}
+	password, _ := r.GetSecret(ctx, "default", "pw", "password")
+	if password != regenerated || r.rotations != 2 {
+		t.Errorf("expected the secret to be kept after a failed rotation, got %v rotations", r.rotations)
+	}
+
+	r.err = nil
+	if err := rotatePassword(ctx, r, db, crdclient, now); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := crdclient.Get(ctx, "test", metav1.GetOptions{})
+	if err != nil || updated.Status.PasswordHash != passwordHash(db, regenerated) {
+		t.Errorf("expected the applied password in the status, got %v", err)
+	}
+}
+
+func TestPasswordSecretKey(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	keys, err := passwordSecretKey(db)
+	if err != nil || len(keys) != 0 {
+		t.Errorf("expected no index keys without a password secret, got %v %v", keys, err)
+	}
+	db.Spec.Password.Name = "mysecret"
+	keys, err = passwordSecretKey(db)
+	if err != nil || len(keys) != 1 || keys[0] != "default/mysecret" {
+		t.Errorf("expected default/mysecret, got %v %v", keys, err)
+	}
+}
+
+func TestHasFinalizer(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	if hasFinalizer(db) {
+		t.Errorf("expected no finalizer")
+	}
+	db.Finalizers = []string{"other", crd.Finalizer}
+	if !hasFinalizer(db) {
+		t.Errorf("expected finalizer %v to be found", crd.Finalizer)
+	}
+}
+
+func TestFinalizer(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Finalizers: []string{"other"}}}
+	clientset := fake.NewSimpleClientset(db)
+	crdclient := clientset.DatabaseV1().Databases("default")
+
+	if err := addFinalizer(context.Background(), db, databaseFinalizers(crdclient)); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := crdclient.Get(context.Background(), "test", metav1.GetOptions{})
+	if err != nil || !reflect.DeepEqual(updated.Finalizers, []string{"other", crd.Finalizer}) {
+		t.Errorf("expected finalizers [other %v], got %v %v", crd.Finalizer, updated.Finalizers, err)
+	}
+	if err := removeFinalizer(context.Background(), updated, databaseFinalizers(crdclient)); err != nil {
+		t.Fatal(err)
+	}
+	updated, err = crdclient.Get(context.Background(), "test", metav1.GetOptions{})
+	if err != nil || !reflect.DeepEqual(updated.Finalizers, []string{"other"}) {
+		t.Errorf("expected finalizers [other], got %v %v", updated.Finalizers, err)
+	}
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("expected the finalizers to be patched, got %v", action)
+		}
+	}
+}
+
+func TestFinalizerPatch(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", ResourceVersion: "42"}}
+	patch, err := finalizerPatch(db, nil)
+	if err != nil || string(patch) != `{"metadata":{"finalizers":null,"resourceVersion":"42"}}` {
+		t.Errorf("unexpected patch %s %v", patch, err)
+	}
+}
+
+func TestHandleDeleteOnlyQueues(t *testing.T) {
+	// a standby replica has informers but no workers, deleting must not reach a provider before a worker picks it up
+	c := NewController(fake.NewSimpleClientset(), kubefake.NewSimpleClientset(), options{})
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+
+	c.handleDelete(&databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", Finalizers: []string{crd.Finalizer}}})
+	if c.queue.Len() != 0 {
+		t.Errorf("expected a database with the finalizer not to be queued, got %v", c.queue.Len())
+	}
+	c.handleDelete(cache.DeletedFinalStateUnknown{Key: "default/test", Obj: db})
+	if c.queue.Len() != 1 {
+		t.Errorf("expected the deleted database to be queued, got %v", c.queue.Len())
+	}
+	if deleted := c.deletedDatabase("default/test"); deleted != db {
+		t.Errorf("expected the deleted database to be kept for the worker, got %v", deleted)
+	}
+	c.forgetDeleted("default/test")
+	if deleted := c.deletedDatabase("default/test"); deleted != nil {
+		t.Errorf("expected the deleted database to be forgotten, got %v", deleted)
+	}
+}
+
+func TestShouldEnqueue(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name     string
+		oldDB    *databasev1.Database
+		db       *databasev1.Database
+		expected bool
+	}{
+		{
+			name:     "resync",
+			oldDB:    &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}},
+			expected: true,
+		},
+		{
+			name:     "status update",
+			oldDB:    &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}, Status: databasev1.DatabaseStatus{State: "Created"}},
+			expected: false,
+		},
+		{
+			name:     "spec update",
+			oldDB:    &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}, Spec: databasev1.DatabaseSpec{Size: 30}},
+			expected: true,
+		},
+		{
+			name:     "deleted",
+			oldDB:    &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2", DeletionTimestamp: &now}},
+			expected: true,
+		},
+		{
+			name:     "status update while deleting",
+			oldDB:    &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2", DeletionTimestamp: &now}},
+			db:       &databasev1.Database{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "3", DeletionTimestamp: &now}},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := shouldEnqueue(test.oldDB, test.db); actual != test.expected {
+				t.Errorf("expected %v, actual %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLeaseNamespace(t *testing.T) {
+	if ns := leaseNamespace("operators"); ns != "operators" {
+		t.Errorf("expected the configured namespace, actual %v", ns)
+	}
+	t.Setenv("POD_NAMESPACE", "k8s-rds")
+	if ns := leaseNamespace(""); ns != "k8s-rds" {
+		t.Errorf("expected the pod namespace, actual %v", ns)
+	}
+}
@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PendingError is returned by a provider when an operation has been started but isn't done yet,
+// the database should be checked again after RequeueAfter
+type PendingError struct {
+	State        string
+	Message      string
+	RequeueAfter time.Duration
+}
+
+func (e *PendingError) Error() string {
+	return fmt.Sprintf("database is %v: %v", e.State, e.Message)
+}
+
+// IsPending returns the PendingError if err is or wraps one
+func IsPending(err error) (*PendingError, bool) {
+	var pending *PendingError
+	if errors.As(err, &pending) {
+		return pending, true
+	}
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"strings"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// EnginePort returns the port name and the default port of a database engine, unknown engines are
+// treated as PostgreSQL
+func EnginePort(engine string) (string, int32) {
+	engine = strings.ToLower(engine)
+	switch {
+	case strings.Contains(engine, "postgres"):
+		return "pgsql", 5432
+	case strings.Contains(engine, "mysql"), strings.HasPrefix(engine, "mariadb"), engine == "aurora":
+		return "mysql", 3306
+	case strings.HasPrefix(engine, "sqlserver"):
+		return "mssql", 1433
+	case strings.HasPrefix(engine, "oracle"):
+		return "oracle", 1521
+	}
+	return "pgsql", 5432
+}
+
+// DatabasePort returns the port the database listens on. The port reported by the provider wins,
+// then spec.port and last the default port of the engine
+func DatabasePort(db *databasev1.Database, reported int32) int32 {
+	if reported > 0 {
+		return reported
+	}
+	if db.Spec.Port > 0 {
+		return db.Spec.Port
+	}
+	_, port := EnginePort(db.Spec.Engine)
+	return port
+}
+
+// ServicePort returns the port of the Service in front of the database
+func ServicePort(db *databasev1.Database, reported int32) corev1.ServicePort {
+	return servicePort(db.Spec.Engine, DatabasePort(db, reported))
+}
+
+// ClusterPort returns the port the cluster listens on, like DatabasePort does for databases
+func ClusterPort(c *databasev1.DatabaseCluster, reported int32) int32 {
+	if reported > 0 {
+		return reported
+	}
+	if c.Spec.Port > 0 {
+		return c.Spec.Port
+	}
+	_, port := EnginePort(c.Spec.Engine)
+	return port
+}
+
+// ClusterServicePort returns the port of the writer and reader Services in front of the cluster
+func ClusterServicePort(c *databasev1.DatabaseCluster, reported int32) corev1.ServicePort {
+	return servicePort(c.Spec.Engine, ClusterPort(c, reported))
+}
+
+func servicePort(engine string, port int32) corev1.ServicePort {
+	name, _ := EnginePort(engine)
+	return corev1.ServicePort{
+		Name:       name,
+		Protocol:   corev1.ProtocolTCP,
+		Port:       port,
+		TargetPort: intstr.FromInt(int(port)),
+	}
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"testing"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestEnginePort(t *testing.T) {
+	tests := []struct {
+		engine string
+		name   string
+		port   int32
+	}{
+		{"postgres", "pgsql", 5432},
+		{"aurora-postgresql", "pgsql", 5432},
+		{"mysql", "mysql", 3306},
+		{"mariadb", "mysql", 3306},
+		{"aurora", "mysql", 3306},
+		{"aurora-mysql", "mysql", 3306},
+		{"sqlserver-ex", "mssql", 1433},
+		{"oracle-ee", "oracle", 1521},
+		{"", "pgsql", 5432},
+	}
+	for _, tt := range tests {
+		name, port := EnginePort(tt.engine)
+		assert.Equal(t, tt.name, name, tt.engine)
+		assert.Equal(t, tt.port, port, tt.engine)
+	}
+}
+
+func TestServicePort(t *testing.T) {
+	db := &databasev1.Database{Spec: databasev1.DatabaseSpec{Engine: "mysql"}}
+	p := ServicePort(db, 0)
+	assert.Equal(t, "mysql", p.Name)
+	assert.Equal(t, int32(3306), p.Port)
+	assert.Equal(t, intstr.FromInt(3306), p.TargetPort)
+
+	db.Spec.Port = 3307
+	assert.Equal(t, int32(3307), ServicePort(db, 0).Port)
+	// the port reported by the provider is the truth
+	assert.Equal(t, int32(3308), ServicePort(db, 3308).Port)
+}
+
+func TestClusterServicePort(t *testing.T) {
+	c := &databasev1.DatabaseCluster{Spec: databasev1.DatabaseClusterSpec{Engine: "aurora-postgresql"}}
+	p := ClusterServicePort(c, 0)
+	assert.Equal(t, "pgsql", p.Name)
+	assert.Equal(t, int32(5432), p.Port)
+
+	c.Spec.Port = 5433
+	assert.Equal(t, int32(5433), ClusterServicePort(c, 0).Port)
+	assert.Equal(t, int32(5434), ClusterServicePort(c, 5434).Port)
+}
@@ -2,19 +2,126 @@ package provider
 
 import (
 	"context"
-	"github.com/sorenmat/k8s-rds/crd"
+	"fmt"
+	"time"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	corev1 "k8s.io/api/core/v1"
 )
 
-// DatabaseProvider is the interface for creating and deleting databases
+// DatabaseProvider is the interface for creating, updating and deleting databases
 // this is the main interface that should be implemented if a new provider is created
 type DatabaseProvider interface {
-	CreateDatabase(context.Context, *crd.Database) (string, error)
-	DeleteDatabase(context.Context, *crd.Database) error
+	CreateDatabase(context.Context, *databasev1.Database) (*DatabaseInfo, error)
+	// UpdateDatabase applies changes in the spec to an already created database
+	UpdateDatabase(context.Context, *databasev1.Database) error
+	DeleteDatabase(context.Context, *databasev1.Database) error
+	// RotatePassword sets a new password for the database user
+	RotatePassword(ctx context.Context, db *databasev1.Database, password string) error
+	// EnsureReadReplicas creates the missing read replicas, deletes the ones above spec.readReplicas.count and
+	// returns the replicas the database should have. It doesn't wait for new replicas to become available
+	EnsureReadReplicas(ctx context.Context, db *databasev1.Database) ([]ReplicaInfo, error)
+	// CreateSnapshot starts the snapshot of the database if it doesn't exist yet and reports its progress, it
+	// doesn't wait for the snapshot to complete
+	CreateSnapshot(ctx context.Context, db *databasev1.Database, snapshot *databasev1.DatabaseSnapshot) (*SnapshotInfo, error)
+	// DeleteSnapshot deletes the snapshot recorded in the status, a snapshot that is already gone is not an error
+	DeleteSnapshot(ctx context.Context, snapshot *databasev1.DatabaseSnapshot) error
 	ServiceProvider
 }
 
 type ServiceProvider interface {
-	CreateService(ctx context.Context, namespace string, hostname string, internalname string) error
+	// CreateService creates or updates the service named internalname pointing at the database
+	CreateService(ctx context.Context, namespace string, hostname string, internalname string, port corev1.ServicePort) error
 	DeleteService(ctx context.Context, namespace string, dbname string) error
+	// CreateConnectionSecret creates or updates the secret with the connection details of the database
+	CreateConnectionSecret(ctx context.Context, db *databasev1.Database, port int32) error
 	GetSecret(ctx context.Context, namepspace string, pwname string, pwkey string) (string, error)
+	// EnsurePassword generates the password secret if the database asks for it
+	EnsurePassword(ctx context.Context, db *databasev1.Database) error
+	// RegeneratePassword replaces a generated password with a new one
+	RegeneratePassword(ctx context.Context, db *databasev1.Database) error
+}
+
+// DatabaseInfo describes a created database as reported by the provider
+type DatabaseInfo struct {
+	Hostname      string
+	Port          int32
+	ARN           string
+	ResourceID    string
+	EngineVersion string
+	// ParameterApplyStatus is pending-reboot when changed parameters only take effect after a reboot
+	ParameterApplyStatus string
+}
+
+// ReplicaAvailable is the state of a read replica that can be used
+const ReplicaAvailable = "available"
+
+// ReplicaInfo describes a read replica as reported by the provider, Name is the name of its service
+type ReplicaInfo struct {
+	DatabaseInfo
+	Name  string
+	State string
+}
+
+// ReplicaName is the name of the service of read replica i of the database
+func ReplicaName(db *databasev1.Database, i int) string {
+	return fmt.Sprintf("%s-ro-%d", db.Name, i)
+}
+
+// ReplicaCount is the number of read replicas the database should have
+func ReplicaCount(db *databasev1.Database) int {
+	if db.Spec.ReadReplicas == nil {
+		return 0
+	}
+	return int(db.Spec.ReadReplicas.Count)
+}
+
+// RestoreSource describes the snapshot or database a new database is restored from, ex snapshot/mydb-default-1650000000
+// or database/mydb@latest. It is empty when there is nothing to restore, a database is only restored while it is
+// first created, once the provider reported a resource id spec.restoreFrom is ignored
+func RestoreSource(db *databasev1.Database) string {
+	from := db.Spec.RestoreFrom
+	if from == nil || db.Status.ResourceID != "" {
+		return ""
+	}
+	if from.SnapshotIdentifier != "" {
+		return "snapshot/" + from.SnapshotIdentifier
+	}
+	if from.PointInTime != nil {
+		at := "latest"
+		if from.PointInTime.RestoreTime != nil {
+			at = from.PointInTime.RestoreTime.UTC().Format(time.RFC3339)
+		}
+		return fmt.Sprintf("database/%s@%s", from.PointInTime.Database, at)
+	}
+	return ""
+}
+
+// SnapshotAvailable is the state of a completed snapshot
+const SnapshotAvailable = "available"
+
+// SnapshotInfo describes a snapshot as reported by the provider
+type SnapshotInfo struct {
+	ID       string
+	State    string
+	Progress int32
+	// Size in Gb
+	Size         int64
+	CreationTime *time.Time
+}
+
+// ClusterProvider is implemented by providers that support database clusters with a writer and reader instances
+type ClusterProvider interface {
+	CreateCluster(context.Context, *databasev1.DatabaseCluster) (*ClusterInfo, error)
+	// UpdateCluster applies changes in the spec, including the number of instances, to an already created cluster
+	UpdateCluster(context.Context, *databasev1.DatabaseCluster) error
+	DeleteCluster(context.Context, *databasev1.DatabaseCluster) error
+	ServiceProvider
+}
+
+// ClusterInfo describes a created cluster as reported by the provider, Hostname is the writer endpoint
+type ClusterInfo struct {
+	DatabaseInfo
+	ReaderHostname string
+	Members        []string
 }
@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRestoreSource(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "mydb", Namespace: "default"}}
+	assert.Equal(t, "", RestoreSource(db))
+
+	db.Spec.RestoreFrom = &databasev1.RestoreFrom{SnapshotIdentifier: "mydb-default-1650000000"}
+	assert.Equal(t, "snapshot/mydb-default-1650000000", RestoreSource(db))
+
+	db.Spec.RestoreFrom = &databasev1.RestoreFrom{PointInTime: &databasev1.PointInTime{Database: "orders"}}
+	assert.Equal(t, "database/orders@latest", RestoreSource(db))
+
+	at := metav1.NewTime(time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC))
+	db.Spec.RestoreFrom.PointInTime.RestoreTime = &at
+	assert.Equal(t, "database/orders@2022-04-01T12:00:00Z", RestoreSource(db))
+
+	// only restored while the database is first created
+	db.Status.ResourceID = "db-ABC"
+	assert.Equal(t, "", RestoreSource(db))
+}
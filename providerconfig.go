@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/rds"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Keys of the credentials secret of a ProviderConfig
+const (
+	accessKeyIDKey     = "accessKeyId"
+	secretAccessKeyKey = "secretAccessKey"
+	sessionTokenKey    = "sessionToken"
+)
+
+// awsOptions returns the AWS options for a database or cluster in namespace, the ProviderConfig named ref overrides
+// the options of the operator. Only the namespaces allowed by the ProviderConfig may use it
+func (c *Controller) awsOptions(ctx context.Context, kubectl kubernetes.Interface, namespace, ref string) (rds.Options, error) {
+	if ref == "" {
+		return c.opts.aws, nil
+	}
+	pc, err := c.providerConfigLister.Get(ref)
+	if err != nil {
+		return rds.Options{}, fmt.Errorf("unable to get ProviderConfig %v: %v", ref, err)
+	}
+	if !namespaceAllowed(pc, namespace) {
+		return rds.Options{}, fmt.Errorf("ProviderConfig %v can't be used in namespace %v", ref, namespace)
+	}
+
+	opts := withProviderConfig(c.opts.aws, pc)
+	if s := pc.Spec.CredentialsSecretRef; s != nil {
+		secret, err := kubectl.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+		if err != nil {
+			return rds.Options{}, fmt.Errorf("unable to get the credentials of ProviderConfig %v: %v", ref, err)
+		}
+		id, key := string(secret.Data[accessKeyIDKey]), string(secret.Data[secretAccessKeyKey])
+		if id == "" || key == "" {
+			return rds.Options{}, fmt.Errorf("secret %v/%v of ProviderConfig %v needs %v and %v", s.Namespace, s.Name, ref, accessKeyIDKey, secretAccessKeyKey)
+		}
+		opts.Credentials = credentials.NewStaticCredentialsProvider(id, key, string(secret.Data[sessionTokenKey]))
+	}
+	return opts, nil
+}
+
+// withProviderConfig overrides the options with the fields set in the ProviderConfig
+func withProviderConfig(opts rds.Options, pc *databasev1.ProviderConfig) rds.Options {
+	spec := pc.Spec
+	if spec.Region != "" {
+		opts.Region = spec.Region
+	}
+	if spec.VpcID != "" {
+		opts.VpcID = spec.VpcID
+	}
+	if len(spec.Subnets) > 0 {
+		opts.Subnets = spec.Subnets
+	}
+	if len(spec.PublicSubnets) > 0 {
+		opts.PublicSubnets = spec.PublicSubnets
+	}
+	if len(spec.SecurityGroupIDs) > 0 {
+		opts.SecurityGroups = spec.SecurityGroupIDs
+	}
+	opts.RoleARN = spec.RoleARN
+	opts.ExternalID = spec.ExternalID
+	opts.Tags = spec.Tags
+	return opts
+}
+
+// namespaceAllowed reports whether databases in namespace may use the ProviderConfig, a config that doesn't list
+// any namespace can't be used at all and * allows every namespace
+func namespaceAllowed(pc *databasev1.ProviderConfig, namespace string) bool {
+	for _, ns := range pc.Spec.AllowedNamespaces {
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	databaselisters "github.com/sorenmat/k8s-rds/client/listers/database/v1"
+	"github.com/sorenmat/k8s-rds/rds"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestAWSOptions(t *testing.T) {
+	pc := &databasev1.ProviderConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: databasev1.ProviderConfigSpec{
+			Region:               "eu-central-1",
+			RoleARN:              "arn:aws:iam::123456789012:role/k8s-rds",
+			ExternalID:           "team-a",
+			VpcID:                "vpc-a",
+			Subnets:              []string{"subnet-a"},
+			Tags:                 map[string]string{"team": "a"},
+			CredentialsSecretRef: &corev1.SecretReference{Name: "aws", Namespace: "kube-system"},
+			AllowedNamespaces:    []string{"team-a"},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "kube-system"},
+		Data:       map[string][]byte{accessKeyIDKey: []byte("AKIA"), secretAccessKeyKey: []byte("secret")},
+	}
+	kubectl := kubefake.NewSimpleClientset(secret)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(pc))
+	c := &Controller{
+		providerConfigLister: databaselisters.NewProviderConfigLister(indexer),
+		opts:                 options{aws: rds.Options{Region: "eu-west-1", SecurityGroups: []string{"sg-nodes"}}},
+	}
+	ctx := context.Background()
+
+	opts, err := c.awsOptions(ctx, kubectl, "team-a", "")
+	assert.NoError(t, err)
+	assert.Equal(t, c.opts.aws, opts)
+
+	opts, err = c.awsOptions(ctx, kubectl, "team-a", "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-central-1", opts.Region)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/k8s-rds", opts.RoleARN)
+	assert.Equal(t, "team-a", opts.ExternalID)
+	assert.Equal(t, "vpc-a", opts.VpcID)
+	assert.Equal(t, []string{"subnet-a"}, opts.Subnets)
+	assert.Equal(t, []string{"sg-nodes"}, opts.SecurityGroups)
+	assert.Equal(t, map[string]string{"team": "a"}, opts.Tags)
+	creds, err := opts.Credentials.Retrieve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "AKIA", creds.AccessKeyID)
+
+	_, err = c.awsOptions(ctx, kubectl, "team-b", "team-a")
+	assert.Error(t, err)
+	_, err = c.awsOptions(ctx, kubectl, "team-a", "missing")
+	assert.Error(t, err)
+
+	delete(secret.Data, secretAccessKeyKey)
+	_, err = c.awsOptions(ctx, kubefake.NewSimpleClientset(secret), "team-a", "team-a")
+	assert.Error(t, err)
+}
+
+func TestNamespaceAllowed(t *testing.T) {
+	pc := &databasev1.ProviderConfig{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	assert.False(t, namespaceAllowed(pc, "team-a"))
+
+	pc.Spec.AllowedNamespaces = []string{"team-a"}
+	assert.True(t, namespaceAllowed(pc, "team-a"))
+	assert.False(t, namespaceAllowed(pc, "team-b"))
+
+	pc.Spec.AllowedNamespaces = []string{"*"}
+	assert.True(t, namespaceAllowed(pc, "team-b"))
+}
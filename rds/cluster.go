@@ -0,0 +1,535 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+)
+
+// serverlessClass is the instance class of Aurora Serverless v2 instances
+const serverlessClass = "db.serverless"
+
+// CreateCluster creates the Aurora cluster and its member instances, the first instance becomes the writer
+func (r *RDS) CreateCluster(ctx context.Context, c *databasev1.DatabaseCluster) (*provider.ClusterInfo, error) {
+	log.Println("Trying to find the correct subnets")
+	subnetName, err := r.ensureSubnets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	securityGroups, err := r.ensureClusterSecurityGroups(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	pw, err := r.GetSecret(ctx, c.Namespace, c.Spec.Password.Name, c.Spec.Password.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := r.Client
+	id := clusteridentifier(c)
+	_, err = describeCluster(ctx, svc, id)
+	if isClusterNotFound(err) {
+		log.Printf("DB cluster %v not found trying to create it\n", id)
+		input := convertClusterSpecToInput(c, subnetName, securityGroups, pw)
+		input.Tags = withDefaultTags(input.Tags, r.Tags)
+		_, err = svc.CreateDBCluster(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(err, "CreateDBCluster")
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	_, err = r.syncClusterMembers(ctx, svc, c)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Waiting for db cluster %v to become available\n", id)
+	cluster, err := r.waitForCluster(ctx, svc, c)
+	if err != nil {
+		return nil, err
+	}
+	return toClusterInfo(cluster), nil
+}
+
+func toClusterInfo(cluster *rdstypes.DBCluster) *provider.ClusterInfo {
+	info := &provider.ClusterInfo{
+		DatabaseInfo: provider.DatabaseInfo{
+			Hostname:      aws.ToString(cluster.Endpoint),
+			Port:          aws.ToInt32(cluster.Port),
+			ARN:           aws.ToString(cluster.DBClusterArn),
+			ResourceID:    aws.ToString(cluster.DbClusterResourceId),
+			EngineVersion: aws.ToString(cluster.EngineVersion),
+		},
+		ReaderHostname: aws.ToString(cluster.ReaderEndpoint),
+	}
+	for _, m := range cluster.DBClusterMembers {
+		info.Members = append(info.Members, aws.ToString(m.DBInstanceIdentifier))
+	}
+	return info
+}
+
+// syncClusterMembers creates the missing member instances and deletes the ones above spec.instances,
+// it returns true if it changed anything
+func (r *RDS) syncClusterMembers(ctx context.Context, svc RDSAPI, c *databasev1.DatabaseCluster) (bool, error) {
+	changed := false
+	wanted := clusterInstances(c)
+	for i := 0; i < wanted; i++ {
+		id := memberidentifier(c, i)
+		_, err := describeInstance(ctx, svc, id)
+		if !isInstanceNotFound(err) {
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+		log.Printf("Creating instance %v in db cluster %v\n", id, clusteridentifier(c))
+		input := convertClusterSpecToInstanceInput(c, i)
+		input.Tags = withDefaultTags(input.Tags, r.Tags)
+		_, err = svc.CreateDBInstance(ctx, input)
+		if err != nil {
+			return false, errors.Wrap(err, "CreateDBInstance")
+		}
+		changed = true
+	}
+
+	cluster, err := describeCluster(ctx, svc, clusteridentifier(c))
+	if err != nil {
+		return false, err
+	}
+	for _, m := range cluster.DBClusterMembers {
+		id := aws.ToString(m.DBInstanceIdentifier)
+		index, ok := memberIndex(c, id)
+		if !ok || index < wanted {
+			continue
+		}
+		instance, err := describeInstance(ctx, svc, id)
+		if err != nil {
+			return false, err
+		}
+		if aws.ToString(instance.DBInstanceStatus) == "deleting" {
+			continue
+		}
+		log.Printf("Deleting instance %v of db cluster %v\n", id, clusteridentifier(c))
+		_, err = svc.DeleteDBInstance(ctx, &rds.DeleteDBInstanceInput{DBInstanceIdentifier: aws.String(id), SkipFinalSnapshot: true})
+		if err != nil && !isInstanceNotFound(err) {
+			return false, errors.Wrap(err, "DeleteDBInstance")
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// waitForCluster polls the cluster and the instances it should have until they are available, a provider.PendingError
+// is returned after WaitTimeout
+func (r *RDS) waitForCluster(ctx context.Context, svc RDSAPI, c *databasev1.DatabaseCluster) (*rdstypes.DBCluster, error) {
+	id := clusteridentifier(c)
+	deadline := time.Now().Add(r.WaitTimeout)
+	state := ""
+	for {
+		cluster, err := describeCluster(ctx, svc, id)
+		if err != nil {
+			return nil, err
+		}
+		if aws.ToString(cluster.Status) != state {
+			state = aws.ToString(cluster.Status)
+			log.Printf("db cluster %v is %v\n", id, state)
+		}
+		if state == "available" && cluster.Endpoint != nil {
+			break
+		}
+		for _, s := range instanceFailedStates {
+			if s == state {
+				return nil, fmt.Errorf("db cluster %v is in state %v", id, state)
+			}
+		}
+		if !time.Now().Add(r.PollInterval).Before(deadline) {
+			return nil, &provider.PendingError{State: state, Message: fmt.Sprintf("waiting for db cluster %v to become available", id)}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+
+	for i := 0; i < clusterInstances(c); i++ {
+		_, err := r.waitForInstance(ctx, svc, memberidentifier(c, i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// describe it again so the members created while waiting are included
+	return describeCluster(ctx, svc, id)
+}
+
+// UpdateCluster compares the spec with the running cluster, modifies the cluster and the instance class of the
+// members and adds or removes members. The changes are applied immediately.
+func (r *RDS) UpdateCluster(ctx context.Context, c *databasev1.DatabaseCluster) error {
+	svc := r.Client
+	id := clusteridentifier(c)
+
+	cluster, err := describeCluster(ctx, svc, id)
+	if err != nil {
+		return err
+	}
+
+	// like the groups of a database the security group of the cluster only changes with the spec
+	var securityGroups []string
+	if !clusterReconciled(c) {
+		securityGroups, err = r.ensureClusterSecurityGroups(ctx, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	changed := false
+	input := convertClusterSpecToModifyInput(c, cluster, securityGroups)
+	if input != nil {
+		log.Printf("Modifying db cluster %v\n", id)
+		_, err = svc.ModifyDBCluster(ctx, input)
+		if err != nil {
+			return errors.Wrap(err, "ModifyDBCluster")
+		}
+		changed = true
+	}
+
+	class := clusterInstanceClass(c)
+	for _, m := range cluster.DBClusterMembers {
+		instance, err := describeInstance(ctx, svc, aws.ToString(m.DBInstanceIdentifier))
+		if isInstanceNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		pending := instance.PendingModifiedValues
+		if pending == nil {
+			pending = &rdstypes.PendingModifiedValues{}
+		}
+		if class == "" || aws.ToString(instance.DBInstanceStatus) == "deleting" ||
+			class == pendingString(pending.DBInstanceClass, instance.DBInstanceClass) {
+			continue
+		}
+		log.Printf("Changing the class of instance %v to %v\n", aws.ToString(instance.DBInstanceIdentifier), class)
+		_, err = svc.ModifyDBInstance(ctx, &rds.ModifyDBInstanceInput{
+			DBInstanceIdentifier: instance.DBInstanceIdentifier,
+			DBInstanceClass:      aws.String(class),
+			ApplyImmediately:     true,
+		})
+		if err != nil {
+			return errors.Wrap(err, "ModifyDBInstance")
+		}
+		changed = true
+	}
+
+	membersChanged, err := r.syncClusterMembers(ctx, svc, c)
+	if err != nil {
+		return err
+	}
+
+	// tags are only added or updated, tags set outside of k8s-rds are left alone
+	if cluster.DBClusterArn != nil {
+		tags := withDefaultTags(append(toTags(c.Annotations, c.Labels), splitTags(c.Spec.Tags)...), r.Tags)
+		err = updateTags(ctx, svc, cluster.DBClusterArn, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	if changed || membersChanged {
+		return &provider.PendingError{State: "modifying", Message: fmt.Sprintf("waiting for the changes to db cluster %v to be applied", id)}
+	}
+	return nil
+}
+
+// convertClusterSpecToModifyInput returns the modifications needed to bring the cluster in line with the spec,
+// values already pending on the cluster are taken into account. It returns nil if nothing has changed.
+func convertClusterSpecToModifyInput(c *databasev1.DatabaseCluster, cluster *rdstypes.DBCluster, securityGroups []string) *rds.ModifyDBClusterInput {
+	pending := cluster.PendingModifiedValues
+	if pending == nil {
+		pending = &rdstypes.ClusterPendingModifiedValues{}
+	}
+	changed := false
+	input := &rds.ModifyDBClusterInput{
+		DBClusterIdentifier: aws.String(clusteridentifier(c)),
+		ApplyImmediately:    true,
+	}
+
+	if version := pendingString(pending.EngineVersion, cluster.EngineVersion); c.Spec.Version != "" && !sameVersion(c.Spec.Version, version) {
+		input.EngineVersion = aws.String(c.Spec.Version)
+		input.AllowMajorVersionUpgrade = c.Spec.AllowMajorUpgrade && majorVersion(c.Spec.Engine, c.Spec.Version) != majorVersion(c.Spec.Engine, version)
+		changed = true
+	}
+	if retention := clusterBackupRetention(c); retention != aws.ToInt32(cluster.BackupRetentionPeriod) {
+		input.BackupRetentionPeriod = aws.Int32(retention)
+		changed = true
+	}
+	if c.Spec.DeleteProtection != aws.ToBool(cluster.DeletionProtection) {
+		input.DeletionProtection = aws.Bool(c.Spec.DeleteProtection)
+		changed = true
+	}
+	if c.Spec.Port > 0 && c.Spec.Port != aws.ToInt32(cluster.Port) {
+		input.Port = aws.Int32(c.Spec.Port)
+		changed = true
+	}
+	if len(securityGroups) > 0 && !sameIDs(clusterSecurityGroups(cluster), securityGroups) {
+		input.VpcSecurityGroupIds = securityGroups
+		changed = true
+	}
+	if s := c.Spec.ServerlessV2; s != nil {
+		current := cluster.ServerlessV2ScalingConfiguration
+		if current == nil || aws.ToFloat64(current.MinCapacity) != s.MinCapacity || aws.ToFloat64(current.MaxCapacity) != s.MaxCapacity {
+			input.ServerlessV2ScalingConfiguration = serverlessV2Scaling(s)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return input
+}
+
+// DeleteCluster deletes the member instances and then the cluster, a final cluster snapshot is created
+// unless spec.skipfinalsnapshot is set
+func (r *RDS) DeleteCluster(ctx context.Context, c *databasev1.DatabaseCluster) error {
+	if c.Spec.DeleteProtection {
+		log.Printf("Trying to delete a %v in %v which is a deleted protected cluster", c.Name, c.Namespace)
+		return nil
+	}
+	svc := r.Client
+	id := clusteridentifier(c)
+
+	cluster, err := describeCluster(ctx, svc, id)
+	if isClusterNotFound(err) {
+		log.Printf("db cluster %v is already deleted\n", id)
+		err = r.releaseSharedSubnetGroup(ctx, svc, "")
+		if err != nil {
+			return err
+		}
+		return r.deleteSecurityGroup(ctx, clustersecuritygroupname(c))
+	}
+	if err != nil {
+		return err
+	}
+
+	// a cluster can only be deleted once all its instances are being deleted
+	for _, m := range cluster.DBClusterMembers {
+		instance, err := describeInstance(ctx, svc, aws.ToString(m.DBInstanceIdentifier))
+		if isInstanceNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if aws.ToString(instance.DBInstanceStatus) == "deleting" {
+			continue
+		}
+		log.Printf("Deleting instance %v of db cluster %v\n", aws.ToString(m.DBInstanceIdentifier), id)
+		_, err = svc.DeleteDBInstance(ctx, &rds.DeleteDBInstanceInput{DBInstanceIdentifier: m.DBInstanceIdentifier, SkipFinalSnapshot: true})
+		if err != nil && !isInstanceNotFound(err) {
+			return errors.Wrap(err, "DeleteDBInstance")
+		}
+	}
+
+	if aws.ToString(cluster.Status) != "deleting" {
+		input := convertClusterSpecToDeleteInput(c, time.Now().UnixNano())
+		_, err = svc.DeleteDBCluster(ctx, input)
+		if err != nil && !isClusterNotFound(err) {
+			return errors.Wrap(err, fmt.Sprintf("unable to delete db cluster %v", id))
+		}
+		if input.FinalDBSnapshotIdentifier != nil {
+			log.Printf("Will create DB cluster final snapshot: %v\n", *input.FinalDBSnapshotIdentifier)
+		}
+	}
+
+	log.Printf("Waiting for db cluster %v to be deleted\n", id)
+	err = r.waitForClusterDeleted(ctx, svc, id)
+	if err != nil {
+		return err
+	}
+	err = r.releaseSharedSubnetGroup(ctx, svc, aws.ToString(cluster.DBSubnetGroup))
+	if err != nil {
+		return err
+	}
+	return r.deleteSecurityGroup(ctx, clustersecuritygroupname(c))
+}
+
+// waitForClusterDeleted polls the cluster until it is gone, returning a provider.PendingError after WaitTimeout
+func (r *RDS) waitForClusterDeleted(ctx context.Context, svc RDSAPI, id string) error {
+	deadline := time.Now().Add(r.WaitTimeout)
+	for {
+		cluster, err := describeCluster(ctx, svc, id)
+		if isClusterNotFound(err) {
+			log.Printf("db cluster %v is deleted\n", id)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !time.Now().Add(r.PollInterval).Before(deadline) {
+			return &provider.PendingError{State: aws.ToString(cluster.Status), Message: fmt.Sprintf("waiting for db cluster %v to be deleted", id)}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+func convertClusterSpecToDeleteInput(c *databasev1.DatabaseCluster, timestamp int64) *rds.DeleteDBClusterInput {
+	input := &rds.DeleteDBClusterInput{
+		DBClusterIdentifier: aws.String(clusteridentifier(c)),
+		SkipFinalSnapshot:   c.Spec.SkipFinalSnapshot,
+	}
+	if !c.Spec.SkipFinalSnapshot {
+		input.FinalDBSnapshotIdentifier = aws.String(fmt.Sprintf("%s-%s-%d", c.Name, c.Namespace, timestamp))
+	}
+	return input
+}
+
+// describeCluster returns the cluster with the given identifier, the error wraps a DBClusterNotFoundFault
+// if it doesn't exist
+func describeCluster(ctx context.Context, svc RDSAPI, id string) (*rdstypes.DBCluster, error) {
+	res, err := svc.DescribeDBClusters(ctx, &rds.DescribeDBClustersInput{DBClusterIdentifier: aws.String(id)})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("wasn't able to describe the db cluster with id %v", id))
+	}
+	if len(res.DBClusters) == 0 {
+		return nil, errors.Wrap(&rdstypes.DBClusterNotFoundFault{}, fmt.Sprintf("unable to find db cluster %v", id))
+	}
+	return &res.DBClusters[0], nil
+}
+
+func isClusterNotFound(err error) bool {
+	var notFound *rdstypes.DBClusterNotFoundFault
+	return errors.As(err, &notFound)
+}
+
+func convertClusterSpecToInput(c *databasev1.DatabaseCluster, subnetName string, securityGroups []string, password string) *rds.CreateDBClusterInput {
+	tags := toTags(c.Annotations, c.Labels)
+	tags = append(tags, splitTags(c.Spec.Tags)...)
+
+	input := &rds.CreateDBClusterInput{
+		DBClusterIdentifier:   aws.String(clusteridentifier(c)),
+		Engine:                aws.String(c.Spec.Engine),
+		DatabaseName:          aws.String(c.Spec.DBName),
+		MasterUsername:        aws.String(c.Spec.Username),
+		MasterUserPassword:    aws.String(password),
+		DBSubnetGroupName:     aws.String(subnetName),
+		VpcSecurityGroupIds:   securityGroups,
+		StorageEncrypted:      aws.Bool(c.Spec.StorageEncrypted),
+		BackupRetentionPeriod: aws.Int32(clusterBackupRetention(c)),
+		DeletionProtection:    aws.Bool(c.Spec.DeleteProtection),
+		Tags:                  tags,
+	}
+	if c.Spec.Version != "" {
+		input.EngineVersion = aws.String(c.Spec.Version)
+	}
+	if c.Spec.Port > 0 {
+		input.Port = aws.Int32(c.Spec.Port)
+	}
+	if c.Spec.ServerlessV2 != nil {
+		input.ServerlessV2ScalingConfiguration = serverlessV2Scaling(c.Spec.ServerlessV2)
+	}
+	return input
+}
+
+// convertClusterSpecToInstanceInput returns the input to create member i of the cluster, the instances get their
+// storage, credentials and network from the cluster
+func convertClusterSpecToInstanceInput(c *databasev1.DatabaseCluster, i int) *rds.CreateDBInstanceInput {
+	tags := toTags(c.Annotations, c.Labels)
+	tags = append(tags, splitTags(c.Spec.Tags)...)
+
+	return &rds.CreateDBInstanceInput{
+		DBClusterIdentifier:  aws.String(clusteridentifier(c)),
+		DBInstanceIdentifier: aws.String(memberidentifier(c, i)),
+		DBInstanceClass:      aws.String(clusterInstanceClass(c)),
+		Engine:               aws.String(c.Spec.Engine),
+		PubliclyAccessible:   aws.Bool(c.Spec.PubliclyAccessible),
+		// the first instance is the writer, keep it first in line when it comes back after a failover
+		PromotionTier: aws.Int32(promotionTier(i)),
+		Tags:          tags,
+	}
+}
+
+// promotionTier is the failover priority of member i, RDS allows tiers from 0 to 15
+func promotionTier(i int) int32 {
+	if i > 15 {
+		return 15
+	}
+	return int32(i)
+}
+
+func serverlessV2Scaling(s *databasev1.ServerlessV2Scaling) *rdstypes.ServerlessV2ScalingConfiguration {
+	return &rdstypes.ServerlessV2ScalingConfiguration{
+		MinCapacity: aws.Float64(s.MinCapacity),
+		MaxCapacity: aws.Float64(s.MaxCapacity),
+	}
+}
+
+// clusterInstances is the number of instances in the cluster, there is always a writer
+func clusterInstances(c *databasev1.DatabaseCluster) int {
+	if c.Spec.Instances < 1 {
+		return 1
+	}
+	return int(c.Spec.Instances)
+}
+
+// clusterInstanceClass is the class of the members, serverless v2 clusters default to db.serverless
+func clusterInstanceClass(c *databasev1.DatabaseCluster) string {
+	if c.Spec.Class == "" && c.Spec.ServerlessV2 != nil {
+		return serverlessClass
+	}
+	return c.Spec.Class
+}
+
+// clusterBackupRetention is the backup retention of the cluster, Aurora always keeps at least one day
+func clusterBackupRetention(c *databasev1.DatabaseCluster) int32 {
+	if c.Spec.BackupRetentionPeriod < 1 {
+		return 1
+	}
+	return int32(c.Spec.BackupRetentionPeriod)
+}
+
+// clusterReconciled reports whether the current generation of the spec has been applied successfully
+func clusterReconciled(c *databasev1.DatabaseCluster) bool {
+	return c.Status.ObservedGeneration > 0 && c.Status.ObservedGeneration == c.Generation
+}
+
+func clusteridentifier(c *databasev1.DatabaseCluster) string {
+	return c.Name + "-" + c.Namespace
+}
+
+// memberidentifier is the identifier of instance i of the cluster
+func memberidentifier(c *databasev1.DatabaseCluster, i int) string {
+	return fmt.Sprintf("%s-%d", clusteridentifier(c), i)
+}
+
+// memberIndex returns the index of a member created by the operator, instances added outside of k8s-rds are ignored
+func memberIndex(c *databasev1.DatabaseCluster, id string) (int, bool) {
+	prefix := clusteridentifier(c) + "-"
+	if !strings.HasPrefix(id, prefix) {
+		return 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(id, prefix))
+	if err != nil || i < 0 {
+		return 0, false
+	}
+	return i, true
+}
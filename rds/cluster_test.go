@@ -0,0 +1,111 @@
+package rds
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testCluster() *databasev1.DatabaseCluster {
+	return &databasev1.DatabaseCluster{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mycluster", Namespace: "default"},
+		Spec: databasev1.DatabaseClusterSpec{
+			Username:     "myuser",
+			DBName:       "app",
+			Engine:       "aurora-postgresql",
+			Version:      "13.6",
+			Instances:    2,
+			ServerlessV2: &databasev1.ServerlessV2Scaling{MinCapacity: 0.5, MaxCapacity: 4},
+			Tags:         "team=db",
+		},
+	}
+}
+
+func TestConvertClusterSpecToInput(t *testing.T) {
+	i := convertClusterSpecToInput(testCluster(), "mysubnet", []string{"sg-1234"}, "mypassword")
+	assert.Equal(t, "mycluster-default", *i.DBClusterIdentifier)
+	assert.Equal(t, "aurora-postgresql", *i.Engine)
+	assert.Equal(t, "app", *i.DatabaseName)
+	assert.Equal(t, "mypassword", *i.MasterUserPassword)
+	assert.Equal(t, "mysubnet", *i.DBSubnetGroupName)
+	assert.Equal(t, "13.6", *i.EngineVersion)
+	assert.Equal(t, int32(1), *i.BackupRetentionPeriod)
+	assert.Equal(t, 0.5, *i.ServerlessV2ScalingConfiguration.MinCapacity)
+	assert.Equal(t, 4.0, *i.ServerlessV2ScalingConfiguration.MaxCapacity)
+	assert.Nil(t, i.Port)
+	assert.Len(t, i.Tags, 1)
+}
+
+func TestConvertClusterSpecToInstanceInput(t *testing.T) {
+	c := testCluster()
+	writer := convertClusterSpecToInstanceInput(c, 0)
+	assert.Equal(t, "mycluster-default-0", *writer.DBInstanceIdentifier)
+	assert.Equal(t, "mycluster-default", *writer.DBClusterIdentifier)
+	assert.Equal(t, "db.serverless", *writer.DBInstanceClass)
+	assert.Equal(t, int32(0), *writer.PromotionTier)
+
+	c.Spec.Class = "db.r6g.large"
+	reader := convertClusterSpecToInstanceInput(c, 1)
+	assert.Equal(t, "mycluster-default-1", *reader.DBInstanceIdentifier)
+	assert.Equal(t, "db.r6g.large", *reader.DBInstanceClass)
+	assert.Equal(t, int32(1), *reader.PromotionTier)
+	assert.Equal(t, int32(15), promotionTier(20))
+}
+
+func TestConvertClusterSpecToModifyInput(t *testing.T) {
+	c := testCluster()
+	cluster := &rdstypes.DBCluster{
+		EngineVersion:         aws.String("13.6"),
+		BackupRetentionPeriod: aws.Int32(1),
+		DeletionProtection:    aws.Bool(false),
+		Port:                  aws.Int32(5432),
+		ServerlessV2ScalingConfiguration: &rdstypes.ServerlessV2ScalingConfigurationInfo{
+			MinCapacity: aws.Float64(0.5),
+			MaxCapacity: aws.Float64(4),
+		},
+	}
+	assert.Nil(t, convertClusterSpecToModifyInput(c, cluster, nil))
+
+	c.Spec.ServerlessV2.MaxCapacity = 16
+	c.Spec.DeleteProtection = true
+	i := convertClusterSpecToModifyInput(c, cluster, nil)
+	assert.NotNil(t, i)
+	assert.Equal(t, 16.0, *i.ServerlessV2ScalingConfiguration.MaxCapacity)
+	assert.Equal(t, true, *i.DeletionProtection)
+	assert.Nil(t, i.EngineVersion)
+	assert.True(t, i.ApplyImmediately)
+}
+
+func TestConvertClusterSpecToDeleteInput(t *testing.T) {
+	c := testCluster()
+	i := convertClusterSpecToDeleteInput(c, 10)
+	assert.Equal(t, "mycluster-default", *i.DBClusterIdentifier)
+	assert.Equal(t, "mycluster-default-10", *i.FinalDBSnapshotIdentifier)
+	assert.False(t, i.SkipFinalSnapshot)
+
+	c.Spec.SkipFinalSnapshot = true
+	i = convertClusterSpecToDeleteInput(c, 10)
+	assert.Nil(t, i.FinalDBSnapshotIdentifier)
+	assert.True(t, i.SkipFinalSnapshot)
+}
+
+func TestMemberIndex(t *testing.T) {
+	c := testCluster()
+	i, ok := memberIndex(c, memberidentifier(c, 3))
+	assert.True(t, ok)
+	assert.Equal(t, 3, i)
+	_, ok = memberIndex(c, "mycluster-default-reader")
+	assert.False(t, ok)
+	_, ok = memberIndex(c, "other-default-0")
+	assert.False(t, ok)
+}
+
+func TestIsClusterNotFound(t *testing.T) {
+	assert.True(t, isClusterNotFound(errors.Wrap(&rdstypes.DBClusterNotFoundFault{}, "describe")))
+	assert.False(t, isClusterNotFound(errors.New("throttled")))
+}
@@ -0,0 +1,189 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options configures the AWS environment of the provider. Values left empty are discovered from the nodes of the
+// cluster or, when the operator runs on EC2, from the instance metadata service
+type Options struct {
+	Region         string
+	VpcID          string
+	Subnets        []string
+	PublicSubnets  []string
+	SecurityGroups []string
+	// EndpointURL replaces the endpoints of the AWS APIs, ex for a local AWS emulator
+	EndpointURL string
+	// Credentials replace the credentials of the operator, ex the static credentials of a ProviderConfig
+	Credentials aws.CredentialsProvider
+	// RoleARN is assumed through STS for all AWS calls, with ExternalID when the trust policy requires it
+	RoleARN    string
+	ExternalID string
+	// Tags are added to the instances and clusters, the tags of a database win
+	Tags map[string]string
+}
+
+// regionLabels are the node labels holding the region, the deprecated label is still set by older clusters
+var regionLabels = []string{corev1.LabelTopologyRegion, corev1.LabelFailureDomainBetaRegion}
+
+// imdsTimeout bounds the calls to the instance metadata service, which isn't reachable from every pod
+const imdsTimeout = 5 * time.Second
+
+// awsConfig loads the SDK configuration, the region is taken from the options, the environment of the operator,
+// the labels of the nodes or the instance metadata service in that order. The role of the options is assumed with
+// the credentials of the options or else those of the operator
+func awsConfig(ctx context.Context, nodes []corev1.Node, opts Options) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return aws.Config{}, errors.Wrap(err, "unable to load the AWS SDK config")
+	}
+	if opts.EndpointURL != "" {
+		cfg.EndpointResolverWithOptions = aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: opts.EndpointURL, SigningRegion: region, HostnameImmutable: true}, nil
+		})
+	}
+
+	if opts.Region != "" {
+		cfg.Region = opts.Region
+	}
+	if cfg.Region == "" {
+		cfg.Region = nodeRegion(nodes)
+	}
+	if cfg.Region == "" {
+		ctx, cancel := context.WithTimeout(ctx, imdsTimeout)
+		defer cancel()
+		res, err := imds.NewFromConfig(cfg).GetRegion(ctx, &imds.GetRegionInput{})
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("unable to find the AWS region, no node has a region label and the instance metadata service isn't available (%v), set --aws-region", err)
+		}
+		cfg.Region = res.Region
+	}
+	log.Printf("Using AWS region %v\n", cfg.Region)
+
+	if opts.Credentials != nil {
+		cfg.Credentials = aws.NewCredentialsCache(opts.Credentials)
+	}
+	if opts.RoleARN != "" {
+		log.Printf("Assuming role %v\n", opts.RoleARN)
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), opts.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if opts.ExternalID != "" {
+				o.ExternalID = aws.String(opts.ExternalID)
+			}
+		}))
+	}
+	return cfg, nil
+}
+
+// nodeRegion returns the region in the labels of the nodes
+func nodeRegion(nodes []corev1.Node) string {
+	for _, label := range regionLabels {
+		for _, n := range nodes {
+			if region := n.Labels[label]; region != "" {
+				return region
+			}
+		}
+	}
+	return ""
+}
+
+// nodeInstanceID returns the EC2 instance id of the first node running on EC2, Fargate nodes are skipped
+func nodeInstanceID(nodes []corev1.Node) string {
+	for _, n := range nodes {
+		id := getIDFromProvider(n.Spec.ProviderID)
+		if strings.HasPrefix(n.Spec.ProviderID, "aws://") && strings.HasPrefix(id, "i-") {
+			return id
+		}
+	}
+	return ""
+}
+
+// listNodes returns the nodes of the cluster, discovery goes on without them when the operator can't list them
+func listNodes(ctx context.Context, kc kubernetes.Interface) []corev1.Node {
+	nodes, err := kc.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Printf("unable to list the nodes of the cluster: %v\n", err)
+		return nil
+	}
+	return nodes.Items
+}
+
+// nodeNetwork returns the VPC and the security groups of the nodes, taken from the EC2 instance of a node or from
+// the instance metadata service
+func nodeNetwork(ctx context.Context, nodes []corev1.Node, svc EC2API, cfg aws.Config) (string, []string, error) {
+	if id := nodeInstanceID(nodes); id != "" {
+		log.Printf("Taking the VPC and security groups from node %v\n", id)
+		res, err := svc.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{id}})
+		if err != nil {
+			return "", nil, errors.Wrap(err, fmt.Sprintf("unable to describe the instance %v of a node", id))
+		}
+		if len(res.Reservations) > 0 && len(res.Reservations[0].Instances) > 0 {
+			vpcID, sgs := instanceNetwork(res.Reservations[0].Instances[0])
+			return vpcID, sgs, nil
+		}
+	}
+
+	log.Println("No node is an EC2 instance, asking the instance metadata service")
+	vpcID, sgs, err := imdsNetwork(ctx, cfg)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to find the VPC of the cluster, no node is an EC2 instance and the instance metadata service isn't available (%v), set --aws-vpc-id", err)
+	}
+	return vpcID, sgs, nil
+}
+
+func instanceNetwork(instance ec2types.Instance) (string, []string) {
+	var sgs []string
+	for _, g := range instance.SecurityGroups {
+		sgs = append(sgs, aws.ToString(g.GroupId))
+	}
+	return aws.ToString(instance.VpcId), sgs
+}
+
+// imdsNetwork reads the VPC and the security groups of the instance the operator runs on from the metadata service
+func imdsNetwork(ctx context.Context, cfg aws.Config) (string, []string, error) {
+	ctx, cancel := context.WithTimeout(ctx, imdsTimeout)
+	defer cancel()
+	client := imds.NewFromConfig(cfg)
+	mac, err := getMetadata(ctx, client, "mac")
+	if err != nil {
+		return "", nil, err
+	}
+	vpcID, err := getMetadata(ctx, client, "network/interfaces/macs/"+mac+"/vpc-id")
+	if err != nil {
+		return "", nil, err
+	}
+	sgs, err := getMetadata(ctx, client, "network/interfaces/macs/"+mac+"/security-group-ids")
+	if err != nil {
+		return "", nil, err
+	}
+	return vpcID, strings.Fields(sgs), nil
+}
+
+func getMetadata(ctx context.Context, client *imds.Client, path string) (string, error) {
+	res, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+	if err != nil {
+		return "", err
+	}
+	defer res.Content.Close()
+	b, err := ioutil.ReadAll(res.Content)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,63 @@
+package rds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func node(providerID string, labels map[string]string) corev1.Node {
+	return corev1.Node{ObjectMeta: meta_v1.ObjectMeta{Labels: labels}, Spec: corev1.NodeSpec{ProviderID: providerID}}
+}
+
+func TestNodeRegion(t *testing.T) {
+	assert.Equal(t, "", nodeRegion(nil))
+	nodes := []corev1.Node{
+		node("", map[string]string{"failure-domain.beta.kubernetes.io/region": "eu-west-1"}),
+		node("", map[string]string{"topology.kubernetes.io/region": "eu-central-1"}),
+	}
+	assert.Equal(t, "eu-central-1", nodeRegion(nodes))
+	assert.Equal(t, "eu-west-1", nodeRegion(nodes[:1]))
+}
+
+func TestNodeInstanceID(t *testing.T) {
+	nodes := []corev1.Node{
+		node("aws:///eu-west-1a/fargate-ip-10-0-1-2.eu-west-1.compute.internal", nil),
+		node("kind://docker/kind/kind-control-plane", nil),
+		node("aws:///eu-west-1b/i-0123456789abcdef0", nil),
+	}
+	assert.Equal(t, "i-0123456789abcdef0", nodeInstanceID(nodes))
+	assert.Equal(t, "", nodeInstanceID(nodes[:2]))
+}
+
+func TestInstanceNetwork(t *testing.T) {
+	vpcID, sgs := instanceNetwork(ec2types.Instance{
+		VpcId:          aws.String("vpc-1"),
+		SecurityGroups: []ec2types.GroupIdentifier{{GroupId: aws.String("sg-1")}, {GroupId: aws.String("sg-2")}},
+	})
+	assert.Equal(t, "vpc-1", vpcID)
+	assert.Equal(t, []string{"sg-1", "sg-2"}, sgs)
+}
+
+func TestAWSConfig(t *testing.T) {
+	nodes := []corev1.Node{node("", map[string]string{"topology.kubernetes.io/region": "eu-central-1"})}
+	cfg, err := awsConfig(context.Background(), nodes, Options{Region: "us-east-2", EndpointURL: "http://localhost:4566"})
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-2", cfg.Region)
+	endpoint, err := cfg.EndpointResolverWithOptions.ResolveEndpoint("RDS", cfg.Region)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:4566", endpoint.URL)
+
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", "testdata/no-config")
+	cfg, err = awsConfig(context.Background(), nodes, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-central-1", cfg.Region)
+	assert.Nil(t, cfg.EndpointResolverWithOptions)
+}
@@ -0,0 +1,264 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// EC2 is an in-memory EC2 backend with the subnets and instances the provider discovers and the security groups
+// it manages
+type EC2 struct {
+	// Subnets and Instances are returned by DescribeSubnets and DescribeInstances
+	Subnets   []ec2types.Subnet
+	Instances []ec2types.Instance
+
+	mu             sync.Mutex
+	securityGroups []*ec2types.SecurityGroup
+	ids            int
+}
+
+// NewEC2 returns a backend with the subnets
+func NewEC2(subnets ...ec2types.Subnet) *EC2 {
+	return &EC2{Subnets: subnets}
+}
+
+func (f *EC2) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var instances []ec2types.Instance
+	for _, i := range f.Instances {
+		if len(params.InstanceIds) == 0 || contains(params.InstanceIds, aws.ToString(i.InstanceId)) {
+			instances = append(instances, i)
+		}
+	}
+	if len(instances) == 0 && len(params.InstanceIds) > 0 {
+		return nil, apiError("InvalidInstanceID.NotFound", fmt.Sprintf("The instance IDs '%v' do not exist", strings.Join(params.InstanceIds, ", ")))
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []ec2types.Reservation{{Instances: instances}}}, nil
+}
+
+func (f *EC2) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &ec2.DescribeSubnetsOutput{}
+	for _, s := range f.Subnets {
+		if matches(params.Filters, map[string]string{"vpc-id": aws.ToString(s.VpcId)}, s.Tags) {
+			out.Subnets = append(out.Subnets, s)
+		}
+	}
+	return out, nil
+}
+
+func (f *EC2) CreateSecurityGroup(ctx context.Context, params *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, g := range f.securityGroups {
+		if aws.ToString(g.GroupName) == aws.ToString(params.GroupName) && aws.ToString(g.VpcId) == aws.ToString(params.VpcId) {
+			return nil, apiError("InvalidGroup.Duplicate", fmt.Sprintf("The security group '%v' already exists for VPC '%v'", aws.ToString(params.GroupName), aws.ToString(params.VpcId)))
+		}
+	}
+	f.ids++
+	g := &ec2types.SecurityGroup{
+		GroupId:     aws.String(fmt.Sprintf("sg-%017d", f.ids)),
+		GroupName:   params.GroupName,
+		Description: params.Description,
+		VpcId:       params.VpcId,
+	}
+	for _, spec := range params.TagSpecifications {
+		g.Tags = append(g.Tags, spec.Tags...)
+	}
+	f.securityGroups = append(f.securityGroups, g)
+	return &ec2.CreateSecurityGroupOutput{GroupId: g.GroupId, Tags: g.Tags}, nil
+}
+
+func (f *EC2) DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &ec2.DescribeSecurityGroupsOutput{}
+	for _, g := range f.securityGroups {
+		if len(params.GroupIds) > 0 && !contains(params.GroupIds, aws.ToString(g.GroupId)) {
+			continue
+		}
+		if matches(params.Filters, map[string]string{"group-name": aws.ToString(g.GroupName), "vpc-id": aws.ToString(g.VpcId)}, g.Tags) {
+			c := *g
+			c.IpPermissions = append([]ec2types.IpPermission{}, g.IpPermissions...)
+			out.SecurityGroups = append(out.SecurityGroups, c)
+		}
+	}
+	return out, nil
+}
+
+// AuthorizeSecurityGroupIngress adds the permissions, a rule that already exists is an error like it is in EC2
+func (f *EC2) AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g, err := f.securityGroup(aws.ToString(params.GroupId))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range params.IpPermissions {
+		current := permission(g, p)
+		for _, pair := range p.UserIdGroupPairs {
+			if hasGroupPair(current.UserIdGroupPairs, aws.ToString(pair.GroupId)) {
+				return nil, apiError("InvalidPermission.Duplicate", fmt.Sprintf("the specified rule for %v already exists", aws.ToString(pair.GroupId)))
+			}
+			current.UserIdGroupPairs = append(current.UserIdGroupPairs, ec2types.UserIdGroupPair{GroupId: pair.GroupId})
+		}
+		for _, r := range p.IpRanges {
+			if hasIPRange(current.IpRanges, aws.ToString(r.CidrIp)) {
+				return nil, apiError("InvalidPermission.Duplicate", fmt.Sprintf("the specified rule for %v already exists", aws.ToString(r.CidrIp)))
+			}
+			current.IpRanges = append(current.IpRanges, ec2types.IpRange{CidrIp: r.CidrIp})
+		}
+		for _, r := range p.Ipv6Ranges {
+			current.Ipv6Ranges = append(current.Ipv6Ranges, ec2types.Ipv6Range{CidrIpv6: r.CidrIpv6})
+		}
+	}
+	return &ec2.AuthorizeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (f *EC2) RevokeSecurityGroupIngress(ctx context.Context, params *ec2.RevokeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g, err := f.securityGroup(aws.ToString(params.GroupId))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range params.IpPermissions {
+		current := permission(g, p)
+		var pairs []ec2types.UserIdGroupPair
+		for _, pair := range current.UserIdGroupPairs {
+			if !hasGroupPair(p.UserIdGroupPairs, aws.ToString(pair.GroupId)) {
+				pairs = append(pairs, pair)
+			}
+		}
+		var ranges []ec2types.IpRange
+		for _, r := range current.IpRanges {
+			if !hasIPRange(p.IpRanges, aws.ToString(r.CidrIp)) {
+				ranges = append(ranges, r)
+			}
+		}
+		var ipv6 []ec2types.Ipv6Range
+		for _, r := range current.Ipv6Ranges {
+			revoked := false
+			for _, rr := range p.Ipv6Ranges {
+				revoked = revoked || aws.ToString(rr.CidrIpv6) == aws.ToString(r.CidrIpv6)
+			}
+			if !revoked {
+				ipv6 = append(ipv6, r)
+			}
+		}
+		// a permission given without sources revokes the whole rule
+		if len(p.UserIdGroupPairs)+len(p.IpRanges)+len(p.Ipv6Ranges) == 0 {
+			pairs, ranges, ipv6 = nil, nil, nil
+		}
+		current.UserIdGroupPairs, current.IpRanges, current.Ipv6Ranges = pairs, ranges, ipv6
+	}
+
+	var kept []ec2types.IpPermission
+	for _, p := range g.IpPermissions {
+		if len(p.UserIdGroupPairs)+len(p.IpRanges)+len(p.Ipv6Ranges) > 0 {
+			kept = append(kept, p)
+		}
+	}
+	g.IpPermissions = kept
+	return &ec2.RevokeSecurityGroupIngressOutput{Return: aws.Bool(true)}, nil
+}
+
+func (f *EC2) DeleteSecurityGroup(ctx context.Context, params *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.GroupId)
+	if _, err := f.securityGroup(id); err != nil {
+		return nil, err
+	}
+	var kept []*ec2types.SecurityGroup
+	for _, g := range f.securityGroups {
+		if aws.ToString(g.GroupId) != id {
+			kept = append(kept, g)
+		}
+	}
+	f.securityGroups = kept
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (f *EC2) securityGroup(id string) (*ec2types.SecurityGroup, error) {
+	for _, g := range f.securityGroups {
+		if aws.ToString(g.GroupId) == id {
+			return g, nil
+		}
+	}
+	return nil, apiError("InvalidGroup.NotFound", fmt.Sprintf("The security group '%v' does not exist", id))
+}
+
+// permission returns the rule of the group for the protocol and ports of p, it is added if the group doesn't have it
+func permission(g *ec2types.SecurityGroup, p ec2types.IpPermission) *ec2types.IpPermission {
+	for n, current := range g.IpPermissions {
+		if aws.ToString(current.IpProtocol) == aws.ToString(p.IpProtocol) &&
+			aws.ToInt32(current.FromPort) == aws.ToInt32(p.FromPort) && aws.ToInt32(current.ToPort) == aws.ToInt32(p.ToPort) {
+			return &g.IpPermissions[n]
+		}
+	}
+	g.IpPermissions = append(g.IpPermissions, ec2types.IpPermission{IpProtocol: p.IpProtocol, FromPort: p.FromPort, ToPort: p.ToPort})
+	return &g.IpPermissions[len(g.IpPermissions)-1]
+}
+
+// matches reports whether a resource with the attributes and tags passes the filters, tag filters are written as
+// tag:<key>
+func matches(filters []ec2types.Filter, attributes map[string]string, tags []ec2types.Tag) bool {
+	for _, filter := range filters {
+		name := aws.ToString(filter.Name)
+		value, ok := attributes[name]
+		if key := strings.TrimPrefix(name, "tag:"); key != name {
+			ok = false
+			for _, t := range tags {
+				if aws.ToString(t.Key) == key {
+					value, ok = aws.ToString(t.Value), true
+				}
+			}
+		}
+		if !ok || !contains(filter.Values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasGroupPair(pairs []ec2types.UserIdGroupPair, id string) bool {
+	for _, p := range pairs {
+		if aws.ToString(p.GroupId) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func hasIPRange(ranges []ec2types.IpRange, cidr string) bool {
+	for _, r := range ranges {
+		if aws.ToString(r.CidrIp) == cidr {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// apiError is an error without a type of its own in the SDK, ex the errors of EC2
+func apiError(code, message string) error {
+	return &smithy.GenericAPIError{Code: code, Message: message, Fault: smithy.FaultClient}
+}
@@ -0,0 +1,357 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+type parameterGroup struct {
+	rdstypes.DBParameterGroup
+	// user are the parameters set on the group, keyed by name
+	user map[string]rdstypes.Parameter
+}
+
+func (f *RDS) CreateDBSubnetGroup(ctx context.Context, params *rds.CreateDBSubnetGroupInput, optFns ...func(*rds.Options)) (*rds.CreateDBSubnetGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBSubnetGroupName)
+	if _, ok := f.subnetGroups[name]; ok {
+		return nil, &rdstypes.DBSubnetGroupAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB subnet group %v already exists", name))}
+	}
+	if len(params.SubnetIds) == 0 {
+		return nil, &rdstypes.DBSubnetGroupDoesNotCoverEnoughAZs{Message: aws.String("The DB subnet group doesn't meet Availability Zone (AZ) coverage requirement.")}
+	}
+	g := &rdstypes.DBSubnetGroup{
+		DBSubnetGroupName:        aws.String(name),
+		DBSubnetGroupDescription: params.DBSubnetGroupDescription,
+		DBSubnetGroupArn:         f.arn("subgrp", name),
+		SubnetGroupStatus:        aws.String("Complete"),
+		Subnets:                  subnets(params.SubnetIds),
+	}
+	f.subnetGroups[name] = g
+	f.addTags(aws.ToString(g.DBSubnetGroupArn), params.Tags)
+	out := *g
+	return &rds.CreateDBSubnetGroupOutput{DBSubnetGroup: &out}, nil
+}
+
+func (f *RDS) DescribeDBSubnetGroups(ctx context.Context, params *rds.DescribeDBSubnetGroupsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBSubnetGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &rds.DescribeDBSubnetGroupsOutput{}
+	if name := aws.ToString(params.DBSubnetGroupName); name != "" {
+		g, ok := f.subnetGroups[name]
+		if !ok {
+			return nil, &rdstypes.DBSubnetGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DB subnet group '%v' not found.", name))}
+		}
+		out.DBSubnetGroups = append(out.DBSubnetGroups, *g)
+		return out, nil
+	}
+	var names []string
+	for name := range f.subnetGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.DBSubnetGroups = append(out.DBSubnetGroups, *f.subnetGroups[name])
+	}
+	return out, nil
+}
+
+func (f *RDS) ModifyDBSubnetGroup(ctx context.Context, params *rds.ModifyDBSubnetGroupInput, optFns ...func(*rds.Options)) (*rds.ModifyDBSubnetGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBSubnetGroupName)
+	g, ok := f.subnetGroups[name]
+	if !ok {
+		return nil, &rdstypes.DBSubnetGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DB subnet group '%v' not found.", name))}
+	}
+	if len(params.SubnetIds) == 0 {
+		return nil, &rdstypes.DBSubnetGroupDoesNotCoverEnoughAZs{Message: aws.String("The DB subnet group doesn't meet Availability Zone (AZ) coverage requirement.")}
+	}
+	if params.DBSubnetGroupDescription != nil {
+		g.DBSubnetGroupDescription = params.DBSubnetGroupDescription
+	}
+	g.Subnets = subnets(params.SubnetIds)
+	out := *g
+	return &rds.ModifyDBSubnetGroupOutput{DBSubnetGroup: &out}, nil
+}
+
+func (f *RDS) DeleteDBSubnetGroup(ctx context.Context, params *rds.DeleteDBSubnetGroupInput, optFns ...func(*rds.Options)) (*rds.DeleteDBSubnetGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBSubnetGroupName)
+	g, ok := f.subnetGroups[name]
+	if !ok {
+		return nil, &rdstypes.DBSubnetGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DB subnet group '%v' not found.", name))}
+	}
+	for _, i := range f.instances {
+		if i.DBSubnetGroup != nil && aws.ToString(i.DBSubnetGroup.DBSubnetGroupName) == name {
+			return nil, &rdstypes.InvalidDBSubnetGroupStateFault{Message: aws.String(fmt.Sprintf("Cannot delete the subnet group '%v' because at least one database instance: %v is still using it.", name, aws.ToString(i.DBInstanceIdentifier)))}
+		}
+	}
+	for _, c := range f.clusters {
+		if aws.ToString(c.DBSubnetGroup) == name {
+			return nil, &rdstypes.InvalidDBSubnetGroupStateFault{Message: aws.String(fmt.Sprintf("Cannot delete the subnet group '%v' because at least one database cluster: %v is still using it.", name, aws.ToString(c.DBClusterIdentifier)))}
+		}
+	}
+	delete(f.subnetGroups, name)
+	delete(f.tags, aws.ToString(g.DBSubnetGroupArn))
+	return &rds.DeleteDBSubnetGroupOutput{}, nil
+}
+
+func subnets(ids []string) []rdstypes.Subnet {
+	var s []rdstypes.Subnet
+	for _, id := range ids {
+		s = append(s, rdstypes.Subnet{SubnetIdentifier: aws.String(id), SubnetStatus: aws.String("Active")})
+	}
+	return s
+}
+
+func (f *RDS) CreateDBParameterGroup(ctx context.Context, params *rds.CreateDBParameterGroupInput, optFns ...func(*rds.Options)) (*rds.CreateDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBParameterGroupName)
+	if _, ok := f.parameterGroups[name]; ok {
+		return nil, &rdstypes.DBParameterGroupAlreadyExistsFault{Message: aws.String(fmt.Sprintf("Parameter group %v already exists", name))}
+	}
+	g := &parameterGroup{DBParameterGroup: rdstypes.DBParameterGroup{
+		DBParameterGroupName:   aws.String(name),
+		DBParameterGroupFamily: params.DBParameterGroupFamily,
+		DBParameterGroupArn:    f.arn("pg", name),
+		Description:            params.Description,
+	}, user: map[string]rdstypes.Parameter{}}
+	f.parameterGroups[name] = g
+	f.addTags(aws.ToString(g.DBParameterGroupArn), params.Tags)
+	out := g.DBParameterGroup
+	return &rds.CreateDBParameterGroupOutput{DBParameterGroup: &out}, nil
+}
+
+func (f *RDS) DescribeDBParameterGroups(ctx context.Context, params *rds.DescribeDBParameterGroupsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBParameterGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBParameterGroupName)
+	g, ok := f.parameterGroups[name]
+	if !ok {
+		return nil, &rdstypes.DBParameterGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DBParameterGroup not found: %v", name))}
+	}
+	return &rds.DescribeDBParameterGroupsOutput{DBParameterGroups: []rdstypes.DBParameterGroup{g.DBParameterGroup}}, nil
+}
+
+// DescribeDBParameters returns the parameters of the family of the group with the values set on the group, all
+// parameters are returned in one page
+func (f *RDS) DescribeDBParameters(ctx context.Context, params *rds.DescribeDBParametersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBParametersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBParameterGroupName)
+	g, ok := f.parameterGroups[name]
+	if !ok {
+		return nil, &rdstypes.DBParameterGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DBParameterGroup not found: %v", name))}
+	}
+	out := &rds.DescribeDBParametersOutput{}
+	for _, p := range f.Parameters[aws.ToString(g.DBParameterGroupFamily)] {
+		if set, ok := g.user[aws.ToString(p.ParameterName)]; ok {
+			p.ParameterValue = set.ParameterValue
+			p.Source = aws.String("user")
+		} else if p.Source == nil {
+			p.Source = aws.String("engine-default")
+		}
+		if s := aws.ToString(params.Source); s != "" && s != aws.ToString(p.Source) {
+			continue
+		}
+		out.Parameters = append(out.Parameters, p)
+	}
+	return out, nil
+}
+
+func (f *RDS) ModifyDBParameterGroup(ctx context.Context, params *rds.ModifyDBParameterGroupInput, optFns ...func(*rds.Options)) (*rds.ModifyDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBParameterGroupName)
+	g, ok := f.parameterGroups[name]
+	if !ok {
+		return nil, &rdstypes.DBParameterGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DBParameterGroup not found: %v", name))}
+	}
+	known := map[string]rdstypes.Parameter{}
+	for _, p := range f.Parameters[aws.ToString(g.DBParameterGroupFamily)] {
+		known[aws.ToString(p.ParameterName)] = p
+	}
+	for _, p := range params.Parameters {
+		k, ok := known[aws.ToString(p.ParameterName)]
+		if !ok || !k.IsModifiable {
+			return nil, &rdstypes.InvalidDBParameterGroupStateFault{Message: aws.String(fmt.Sprintf("Could not find parameter with name: %v", aws.ToString(p.ParameterName)))}
+		}
+	}
+	for _, p := range params.Parameters {
+		g.user[aws.ToString(p.ParameterName)] = p
+	}
+	f.applyParameters(name, params.Parameters)
+	return &rds.ModifyDBParameterGroupOutput{DBParameterGroupName: aws.String(name)}, nil
+}
+
+func (f *RDS) ResetDBParameterGroup(ctx context.Context, params *rds.ResetDBParameterGroupInput, optFns ...func(*rds.Options)) (*rds.ResetDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBParameterGroupName)
+	g, ok := f.parameterGroups[name]
+	if !ok {
+		return nil, &rdstypes.DBParameterGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DBParameterGroup not found: %v", name))}
+	}
+	reset := params.Parameters
+	if params.ResetAllParameters {
+		reset = nil
+		for _, p := range g.user {
+			reset = append(reset, p)
+		}
+	}
+	for _, p := range reset {
+		delete(g.user, aws.ToString(p.ParameterName))
+	}
+	f.applyParameters(name, reset)
+	return &rds.ResetDBParameterGroupOutput{DBParameterGroupName: aws.String(name)}, nil
+}
+
+// applyParameters sets the apply status of the instances in the group, parameters applied immediately are applying
+// until the instance is described again and the others wait for a reboot
+func (f *RDS) applyParameters(name string, parameters []rdstypes.Parameter) {
+	status := "applying"
+	for _, p := range parameters {
+		if p.ApplyMethod == rdstypes.ApplyMethodPendingReboot {
+			status = "pending-reboot"
+		}
+	}
+	for _, i := range f.instances {
+		for n, g := range i.DBParameterGroups {
+			if aws.ToString(g.DBParameterGroupName) == name && aws.ToString(g.ParameterApplyStatus) != "pending-reboot" {
+				i.DBParameterGroups[n].ParameterApplyStatus = aws.String(status)
+			}
+		}
+	}
+}
+
+func (f *RDS) DeleteDBParameterGroup(ctx context.Context, params *rds.DeleteDBParameterGroupInput, optFns ...func(*rds.Options)) (*rds.DeleteDBParameterGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBParameterGroupName)
+	g, ok := f.parameterGroups[name]
+	if !ok {
+		return nil, &rdstypes.DBParameterGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DBParameterGroup not found: %v", name))}
+	}
+	for _, i := range f.instances {
+		for _, pg := range i.DBParameterGroups {
+			if aws.ToString(pg.DBParameterGroupName) == name {
+				return nil, &rdstypes.InvalidDBParameterGroupStateFault{Message: aws.String(fmt.Sprintf("One or more database instances are still members of this parameter group %v", name))}
+			}
+		}
+	}
+	delete(f.parameterGroups, name)
+	delete(f.tags, aws.ToString(g.DBParameterGroupArn))
+	return &rds.DeleteDBParameterGroupOutput{}, nil
+}
+
+func (f *RDS) CreateOptionGroup(ctx context.Context, params *rds.CreateOptionGroupInput, optFns ...func(*rds.Options)) (*rds.CreateOptionGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.OptionGroupName)
+	if _, ok := f.optionGroups[name]; ok {
+		return nil, &rdstypes.OptionGroupAlreadyExistsFault{Message: aws.String(fmt.Sprintf("Option group %v already exists", name))}
+	}
+	g := &rdstypes.OptionGroup{
+		OptionGroupName:        aws.String(name),
+		OptionGroupArn:         f.arn("og", name),
+		OptionGroupDescription: params.OptionGroupDescription,
+		EngineName:             params.EngineName,
+		MajorEngineVersion:     params.MajorEngineVersion,
+	}
+	f.optionGroups[name] = g
+	f.addTags(aws.ToString(g.OptionGroupArn), params.Tags)
+	return &rds.CreateOptionGroupOutput{OptionGroup: copyOptionGroup(g)}, nil
+}
+
+func (f *RDS) DescribeOptionGroups(ctx context.Context, params *rds.DescribeOptionGroupsInput, optFns ...func(*rds.Options)) (*rds.DescribeOptionGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.OptionGroupName)
+	g, ok := f.optionGroups[name]
+	if !ok {
+		return nil, &rdstypes.OptionGroupNotFoundFault{Message: aws.String(fmt.Sprintf("Specified OptionGroupName: %v not found.", name))}
+	}
+	return &rds.DescribeOptionGroupsOutput{OptionGroupsList: []rdstypes.OptionGroup{*copyOptionGroup(g)}}, nil
+}
+
+func (f *RDS) ModifyOptionGroup(ctx context.Context, params *rds.ModifyOptionGroupInput, optFns ...func(*rds.Options)) (*rds.ModifyOptionGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.OptionGroupName)
+	g, ok := f.optionGroups[name]
+	if !ok {
+		return nil, &rdstypes.OptionGroupNotFoundFault{Message: aws.String(fmt.Sprintf("Specified OptionGroupName: %v not found.", name))}
+	}
+	for _, remove := range params.OptionsToRemove {
+		for n, o := range g.Options {
+			if aws.ToString(o.OptionName) != remove {
+				continue
+			}
+			if o.Permanent {
+				return nil, &rdstypes.InvalidOptionGroupStateFault{Message: aws.String(fmt.Sprintf("Option %v is permanent and can't be removed", remove))}
+			}
+			g.Options = append(g.Options[:n], g.Options[n+1:]...)
+			break
+		}
+	}
+	for _, c := range params.OptionsToInclude {
+		o := rdstypes.Option{
+			OptionName:    c.OptionName,
+			OptionVersion: c.OptionVersion,
+			Port:          c.Port,
+		}
+		for _, id := range c.VpcSecurityGroupMemberships {
+			o.VpcSecurityGroupMemberships = append(o.VpcSecurityGroupMemberships, rdstypes.VpcSecurityGroupMembership{VpcSecurityGroupId: aws.String(id), Status: aws.String("active")})
+		}
+		for _, s := range c.OptionSettings {
+			o.OptionSettings = append(o.OptionSettings, rdstypes.OptionSetting{Name: s.Name, Value: s.Value})
+		}
+		replaced := false
+		for n, current := range g.Options {
+			if aws.ToString(current.OptionName) == aws.ToString(c.OptionName) {
+				o.Permanent, o.Persistent = current.Permanent, current.Persistent
+				g.Options[n] = o
+				replaced = true
+			}
+		}
+		if !replaced {
+			g.Options = append(g.Options, o)
+		}
+	}
+	return &rds.ModifyOptionGroupOutput{OptionGroup: copyOptionGroup(g)}, nil
+}
+
+func (f *RDS) DeleteOptionGroup(ctx context.Context, params *rds.DeleteOptionGroupInput, optFns ...func(*rds.Options)) (*rds.DeleteOptionGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.OptionGroupName)
+	g, ok := f.optionGroups[name]
+	if !ok {
+		return nil, &rdstypes.OptionGroupNotFoundFault{Message: aws.String(fmt.Sprintf("Specified OptionGroupName: %v not found.", name))}
+	}
+	for _, i := range f.instances {
+		for _, m := range i.OptionGroupMemberships {
+			if aws.ToString(m.OptionGroupName) == name {
+				return nil, &rdstypes.InvalidOptionGroupStateFault{Message: aws.String(fmt.Sprintf("The option group '%v' cannot be deleted because it is in use.", name))}
+			}
+		}
+	}
+	delete(f.optionGroups, name)
+	delete(f.tags, aws.ToString(g.OptionGroupArn))
+	return &rds.DeleteOptionGroupOutput{}, nil
+}
+
+func copyOptionGroup(g *rdstypes.OptionGroup) *rdstypes.OptionGroup {
+	out := *g
+	out.Options = append([]rdstypes.Option{}, g.Options...)
+	return &out
+}
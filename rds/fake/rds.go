@@ -0,0 +1,965 @@
+// Package fake has in-memory RDS and EC2 backends for testing the rds provider without AWS
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// defaultVersions are the engine versions used when an instance or cluster doesn't ask for one
+var defaultVersions = map[string]string{
+	"postgres":          "13.4",
+	"mysql":             "8.0.28",
+	"mariadb":           "10.6.7",
+	"aurora-postgresql": "13.6",
+	"aurora-mysql":      "8.0.mysql_aurora.3.02.0",
+}
+
+// RDS is an in-memory RDS backend. Instances, clusters and snapshots go through the states RDS reports, every
+// describe returns the current state and moves the resource on to the next one, ex creating, backing-up, available
+type RDS struct {
+	// Region and AccountID are used in the ARNs and endpoints of the resources
+	Region    string
+	AccountID string
+	// Parameters are the parameters of the engines keyed by parameter group family, ex postgres13. A parameter group
+	// starts out with the parameters of its family
+	Parameters map[string][]rdstypes.Parameter
+
+	mu              sync.Mutex
+	instances       map[string]*instance
+	clusters        map[string]*cluster
+	snapshots       map[string]*snapshot
+	subnetGroups    map[string]*rdstypes.DBSubnetGroup
+	parameterGroups map[string]*parameterGroup
+	optionGroups    map[string]*rdstypes.OptionGroup
+	tags            map[string][]rdstypes.Tag
+	ids             int
+}
+
+type instance struct {
+	rdstypes.DBInstance
+	// next are the states the instance goes through, an empty state removes it
+	next []string
+}
+
+type cluster struct {
+	rdstypes.DBCluster
+	next []string
+}
+
+type snapshot struct {
+	rdstypes.DBSnapshot
+	next []string
+}
+
+// NewRDS returns an empty backend for region
+func NewRDS(region string) *RDS {
+	return &RDS{
+		Region:          region,
+		AccountID:       "123456789012",
+		Parameters:      map[string][]rdstypes.Parameter{},
+		instances:       map[string]*instance{},
+		clusters:        map[string]*cluster{},
+		snapshots:       map[string]*snapshot{},
+		subnetGroups:    map[string]*rdstypes.DBSubnetGroup{},
+		parameterGroups: map[string]*parameterGroup{},
+		optionGroups:    map[string]*rdstypes.OptionGroup{},
+		tags:            map[string][]rdstypes.Tag{},
+	}
+}
+
+// SetInstanceStatus puts an instance in status, ex storage-full, until it is modified
+func (f *RDS) SetInstanceStatus(id string, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i, ok := f.instances[id]; ok {
+		i.DBInstanceStatus = aws.String(status)
+		i.next = nil
+	}
+}
+
+func (f *RDS) arn(resource, name string) *string {
+	return aws.String(fmt.Sprintf("arn:aws:rds:%s:%s:%s:%s", f.Region, f.AccountID, resource, name))
+}
+
+func (f *RDS) resourceID(prefix string) *string {
+	f.ids++
+	return aws.String(fmt.Sprintf("%s-%026d", prefix, f.ids))
+}
+
+func (f *RDS) endpoint(name string) string {
+	return fmt.Sprintf("%s.fake.%s.rds.amazonaws.com", name, f.Region)
+}
+
+func (f *RDS) AddTagsToResource(ctx context.Context, params *rds.AddTagsToResourceInput, optFns ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.addTags(aws.ToString(params.ResourceName), params.Tags)
+	return &rds.AddTagsToResourceOutput{}, nil
+}
+
+func (f *RDS) ListTagsForResource(ctx context.Context, params *rds.ListTagsForResourceInput, optFns ...func(*rds.Options)) (*rds.ListTagsForResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &rds.ListTagsForResourceOutput{TagList: append([]rdstypes.Tag{}, f.tags[aws.ToString(params.ResourceName)]...)}, nil
+}
+
+// addTags adds or updates the tags of a resource
+func (f *RDS) addTags(arn string, tags []rdstypes.Tag) {
+	for _, t := range tags {
+		updated := false
+		for i, current := range f.tags[arn] {
+			if aws.ToString(current.Key) == aws.ToString(t.Key) {
+				f.tags[arn][i].Value = t.Value
+				updated = true
+			}
+		}
+		if !updated {
+			f.tags[arn] = append(f.tags[arn], rdstypes.Tag{Key: t.Key, Value: t.Value})
+		}
+	}
+}
+
+func (f *RDS) CreateDBInstance(ctx context.Context, params *rds.CreateDBInstanceInput, optFns ...func(*rds.Options)) (*rds.CreateDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBInstanceIdentifier)
+	if _, ok := f.instances[id]; ok {
+		return nil, &rdstypes.DBInstanceAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB instance %v already exists", id))}
+	}
+
+	i := &instance{DBInstance: rdstypes.DBInstance{
+		DBInstanceIdentifier:  aws.String(id),
+		DBInstanceClass:       params.DBInstanceClass,
+		Engine:                params.Engine,
+		EngineVersion:         params.EngineVersion,
+		DBName:                params.DBName,
+		MasterUsername:        params.MasterUsername,
+		AllocatedStorage:      aws.ToInt32(params.AllocatedStorage),
+		MaxAllocatedStorage:   params.MaxAllocatedStorage,
+		StorageType:           params.StorageType,
+		Iops:                  params.Iops,
+		StorageEncrypted:      aws.ToBool(params.StorageEncrypted),
+		BackupRetentionPeriod: aws.ToInt32(params.BackupRetentionPeriod),
+		MultiAZ:               aws.ToBool(params.MultiAZ),
+		PubliclyAccessible:    aws.ToBool(params.PubliclyAccessible),
+		DeletionProtection:    aws.ToBool(params.DeletionProtection),
+		PromotionTier:         params.PromotionTier,
+	}}
+	port := params.Port
+	if c := aws.ToString(params.DBClusterIdentifier); c != "" {
+		cl, ok := f.clusters[c]
+		if !ok {
+			return nil, &rdstypes.DBClusterNotFoundFault{Message: aws.String(fmt.Sprintf("DB cluster %v not found", c))}
+		}
+		// members get their storage, credentials and network from the cluster
+		i.DBClusterIdentifier = aws.String(c)
+		i.EngineVersion = cl.EngineVersion
+		i.StorageEncrypted = cl.StorageEncrypted
+		i.VpcSecurityGroups = cl.VpcSecurityGroups
+		i.DBSubnetGroup = f.subnetGroups[aws.ToString(cl.DBSubnetGroup)]
+		port = cl.Port
+		cl.DBClusterMembers = append(cl.DBClusterMembers, rdstypes.DBClusterMember{
+			DBInstanceIdentifier: aws.String(id),
+			IsClusterWriter:      len(cl.DBClusterMembers) == 0,
+			PromotionTier:        params.PromotionTier,
+		})
+	} else if err := f.placeInstance(&i.DBInstance, aws.ToString(params.DBSubnetGroupName), params.VpcSecurityGroupIds); err != nil {
+		return nil, err
+	}
+	if err := f.attachGroups(&i.DBInstance, aws.ToString(params.DBParameterGroupName), aws.ToString(params.OptionGroupName)); err != nil {
+		return nil, err
+	}
+	f.create(i, port, "creating", "backing-up", "available")
+	f.addTags(aws.ToString(i.DBInstanceArn), params.Tags)
+	return &rds.CreateDBInstanceOutput{DBInstance: copyInstance(i)}, nil
+}
+
+func (f *RDS) CreateDBInstanceReadReplica(ctx context.Context, params *rds.CreateDBInstanceReadReplicaInput, optFns ...func(*rds.Options)) (*rds.CreateDBInstanceReadReplicaOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBInstanceIdentifier)
+	if _, ok := f.instances[id]; ok {
+		return nil, &rdstypes.DBInstanceAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB instance %v already exists", id))}
+	}
+	// a replica in another region refers to its source by ARN, the source isn't known to this backend then
+	source := aws.ToString(params.SourceDBInstanceIdentifier)
+	src, ok := f.instances[source]
+	if params.SourceRegion == nil {
+		if !ok {
+			return nil, &rdstypes.DBInstanceNotFoundFault{Message: aws.String(fmt.Sprintf("DB instance %v not found", source))}
+		}
+		if aws.ToString(src.DBInstanceStatus) != "available" {
+			return nil, &rdstypes.InvalidDBInstanceStateFault{Message: aws.String(fmt.Sprintf("DB instance %v is not available", source))}
+		}
+	}
+
+	i := &instance{DBInstance: rdstypes.DBInstance{
+		DBInstanceIdentifier:                  aws.String(id),
+		DBInstanceClass:                       params.DBInstanceClass,
+		ReadReplicaSourceDBInstanceIdentifier: aws.String(source),
+		PubliclyAccessible:                    aws.ToBool(params.PubliclyAccessible),
+		AvailabilityZone:                      params.AvailabilityZone,
+	}}
+	var port *int32
+	if ok {
+		i.Engine = src.Engine
+		i.EngineVersion = src.EngineVersion
+		i.AllocatedStorage = src.AllocatedStorage
+		i.StorageType = src.StorageType
+		i.DBName = src.DBName
+		i.MasterUsername = src.MasterUsername
+		i.DBSubnetGroup = src.DBSubnetGroup
+		if i.DBInstanceClass == nil {
+			i.DBInstanceClass = src.DBInstanceClass
+		}
+		if src.Endpoint != nil {
+			port = aws.Int32(src.Endpoint.Port)
+		}
+	}
+	if len(params.VpcSecurityGroupIds) > 0 {
+		i.VpcSecurityGroups = securityGroupMemberships(params.VpcSecurityGroupIds)
+	}
+	if err := f.attachGroups(&i.DBInstance, aws.ToString(params.DBParameterGroupName), aws.ToString(params.OptionGroupName)); err != nil {
+		return nil, err
+	}
+	f.create(i, port, "creating", "available")
+	f.addTags(aws.ToString(i.DBInstanceArn), params.Tags)
+	if ok {
+		src.ReadReplicaDBInstanceIdentifiers = append(src.ReadReplicaDBInstanceIdentifiers, id)
+	}
+	return &rds.CreateDBInstanceReadReplicaOutput{DBInstance: copyInstance(i)}, nil
+}
+
+func (f *RDS) RestoreDBInstanceFromDBSnapshot(ctx context.Context, params *rds.RestoreDBInstanceFromDBSnapshotInput, optFns ...func(*rds.Options)) (*rds.RestoreDBInstanceFromDBSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBInstanceIdentifier)
+	if _, ok := f.instances[id]; ok {
+		return nil, &rdstypes.DBInstanceAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB instance %v already exists", id))}
+	}
+	name := aws.ToString(params.DBSnapshotIdentifier)
+	s, ok := f.snapshots[name]
+	if !ok {
+		return nil, &rdstypes.DBSnapshotNotFoundFault{Message: aws.String(fmt.Sprintf("DB snapshot %v not found", name))}
+	}
+	if aws.ToString(s.Status) != "available" {
+		return nil, &rdstypes.InvalidDBSnapshotStateFault{Message: aws.String(fmt.Sprintf("DB snapshot %v is not available", name))}
+	}
+
+	i := &instance{DBInstance: rdstypes.DBInstance{
+		DBInstanceIdentifier: aws.String(id),
+		DBInstanceClass:      params.DBInstanceClass,
+		Engine:               s.Engine,
+		EngineVersion:        s.EngineVersion,
+		MasterUsername:       s.MasterUsername,
+		AllocatedStorage:     s.AllocatedStorage,
+		StorageType:          s.StorageType,
+		Iops:                 s.Iops,
+		StorageEncrypted:     s.Encrypted,
+		// restored instances keep the backups of the default retention until they are modified
+		BackupRetentionPeriod: 1,
+		MultiAZ:               aws.ToBool(params.MultiAZ),
+		PubliclyAccessible:    aws.ToBool(params.PubliclyAccessible),
+		DeletionProtection:    aws.ToBool(params.DeletionProtection),
+	}}
+	if params.StorageType != nil {
+		i.StorageType = params.StorageType
+	}
+	if params.Iops != nil {
+		i.Iops = params.Iops
+	}
+	if err := f.placeInstance(&i.DBInstance, aws.ToString(params.DBSubnetGroupName), params.VpcSecurityGroupIds); err != nil {
+		return nil, err
+	}
+	if err := f.attachGroups(&i.DBInstance, aws.ToString(params.DBParameterGroupName), aws.ToString(params.OptionGroupName)); err != nil {
+		return nil, err
+	}
+	f.create(i, params.Port, "creating", "available")
+	f.addTags(aws.ToString(i.DBInstanceArn), params.Tags)
+	return &rds.RestoreDBInstanceFromDBSnapshotOutput{DBInstance: copyInstance(i)}, nil
+}
+
+func (f *RDS) RestoreDBInstanceToPointInTime(ctx context.Context, params *rds.RestoreDBInstanceToPointInTimeInput, optFns ...func(*rds.Options)) (*rds.RestoreDBInstanceToPointInTimeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.TargetDBInstanceIdentifier)
+	if _, ok := f.instances[id]; ok {
+		return nil, &rdstypes.DBInstanceAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB instance %v already exists", id))}
+	}
+	source := aws.ToString(params.SourceDBInstanceIdentifier)
+	src, ok := f.instances[source]
+	if !ok {
+		return nil, &rdstypes.DBInstanceNotFoundFault{Message: aws.String(fmt.Sprintf("DB instance %v not found", source))}
+	}
+
+	i := &instance{DBInstance: rdstypes.DBInstance{
+		DBInstanceIdentifier:  aws.String(id),
+		DBInstanceClass:       params.DBInstanceClass,
+		Engine:                src.Engine,
+		EngineVersion:         src.EngineVersion,
+		DBName:                src.DBName,
+		MasterUsername:        src.MasterUsername,
+		AllocatedStorage:      src.AllocatedStorage,
+		MaxAllocatedStorage:   params.MaxAllocatedStorage,
+		StorageType:           src.StorageType,
+		Iops:                  src.Iops,
+		StorageEncrypted:      src.StorageEncrypted,
+		BackupRetentionPeriod: 1,
+		MultiAZ:               aws.ToBool(params.MultiAZ),
+		PubliclyAccessible:    aws.ToBool(params.PubliclyAccessible),
+		DeletionProtection:    aws.ToBool(params.DeletionProtection),
+	}}
+	if params.StorageType != nil {
+		i.StorageType = params.StorageType
+	}
+	if params.Iops != nil {
+		i.Iops = params.Iops
+	}
+	if err := f.placeInstance(&i.DBInstance, aws.ToString(params.DBSubnetGroupName), params.VpcSecurityGroupIds); err != nil {
+		return nil, err
+	}
+	if err := f.attachGroups(&i.DBInstance, aws.ToString(params.DBParameterGroupName), aws.ToString(params.OptionGroupName)); err != nil {
+		return nil, err
+	}
+	f.create(i, params.Port, "creating", "available")
+	f.addTags(aws.ToString(i.DBInstanceArn), params.Tags)
+	return &rds.RestoreDBInstanceToPointInTimeOutput{DBInstance: copyInstance(i)}, nil
+}
+
+// placeInstance puts the instance in the subnet group and security groups
+func (f *RDS) placeInstance(i *rdstypes.DBInstance, subnetGroup string, securityGroups []string) error {
+	if subnetGroup != "" {
+		g, ok := f.subnetGroups[subnetGroup]
+		if !ok {
+			return &rdstypes.DBSubnetGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DB subnet group %v not found", subnetGroup))}
+		}
+		i.DBSubnetGroup = g
+	}
+	i.VpcSecurityGroups = securityGroupMemberships(securityGroups)
+	return nil
+}
+
+// attachGroups attaches the parameter and option groups, the default groups of the engine if they are empty
+func (f *RDS) attachGroups(i *rdstypes.DBInstance, parameterGroup string, optionGroup string) error {
+	if i.EngineVersion == nil {
+		i.EngineVersion = aws.String(defaultVersions[aws.ToString(i.Engine)])
+	}
+	family := family(aws.ToString(i.Engine), aws.ToString(i.EngineVersion))
+	if parameterGroup == "" {
+		parameterGroup = "default." + family
+	} else if _, ok := f.parameterGroups[parameterGroup]; !ok {
+		return &rdstypes.DBParameterGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DB parameter group %v not found", parameterGroup))}
+	}
+	i.DBParameterGroups = []rdstypes.DBParameterGroupStatus{{DBParameterGroupName: aws.String(parameterGroup), ParameterApplyStatus: aws.String("in-sync")}}
+
+	if optionGroup == "" {
+		optionGroup = "default:" + strings.ReplaceAll(aws.ToString(i.Engine)+"-"+majorVersion(aws.ToString(i.EngineVersion)), ".", "-")
+	} else if _, ok := f.optionGroups[optionGroup]; !ok {
+		return &rdstypes.OptionGroupNotFoundFault{Message: aws.String(fmt.Sprintf("option group %v not found", optionGroup))}
+	}
+	i.OptionGroupMemberships = []rdstypes.OptionGroupMembership{{OptionGroupName: aws.String(optionGroup), Status: aws.String("in-sync")}}
+	return nil
+}
+
+// create stores a new instance in the first of the states, the endpoint is known once the instance is available
+func (f *RDS) create(i *instance, port *int32, states ...string) {
+	id := aws.ToString(i.DBInstanceIdentifier)
+	i.DBInstanceArn = f.arn("db", id)
+	i.DbiResourceId = f.resourceID("db")
+	i.InstanceCreateTime = aws.Time(time.Now())
+	i.DBInstanceStatus = aws.String(states[0])
+	i.next = states[1:]
+	if port == nil {
+		port = aws.Int32(defaultPort(aws.ToString(i.Engine)))
+	}
+	i.Endpoint = &rdstypes.Endpoint{Port: *port}
+	f.instances[id] = i
+}
+
+func (f *RDS) DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id := aws.ToString(params.DBInstanceIdentifier); id != "" {
+		i, ok := f.instances[id]
+		if !ok {
+			return nil, &rdstypes.DBInstanceNotFoundFault{Message: aws.String(fmt.Sprintf("DBInstance %v not found.", id))}
+		}
+		out := &rds.DescribeDBInstancesOutput{DBInstances: []rdstypes.DBInstance{*copyInstance(i)}}
+		f.advanceInstance(i)
+		return out, nil
+	}
+
+	out := &rds.DescribeDBInstancesOutput{}
+	for _, id := range f.instanceIDs() {
+		i := f.instances[id]
+		out.DBInstances = append(out.DBInstances, *copyInstance(i))
+		f.advanceInstance(i)
+	}
+	return out, nil
+}
+
+// advanceInstance moves the instance to its next state, pending modifications are applied once it is available
+func (f *RDS) advanceInstance(i *instance) {
+	for n, g := range i.DBParameterGroups {
+		if aws.ToString(g.ParameterApplyStatus) == "applying" {
+			i.DBParameterGroups[n].ParameterApplyStatus = aws.String("in-sync")
+		}
+	}
+	if len(i.next) == 0 {
+		return
+	}
+	state := i.next[0]
+	i.next = i.next[1:]
+	id := aws.ToString(i.DBInstanceIdentifier)
+	if state == "" {
+		delete(f.instances, id)
+		if src, ok := f.instances[aws.ToString(i.ReadReplicaSourceDBInstanceIdentifier)]; ok {
+			src.ReadReplicaDBInstanceIdentifiers = remove(src.ReadReplicaDBInstanceIdentifiers, id)
+		}
+		if c, ok := f.clusters[aws.ToString(i.DBClusterIdentifier)]; ok {
+			for n, m := range c.DBClusterMembers {
+				if aws.ToString(m.DBInstanceIdentifier) == id {
+					c.DBClusterMembers = append(c.DBClusterMembers[:n], c.DBClusterMembers[n+1:]...)
+					break
+				}
+			}
+		}
+		return
+	}
+	i.DBInstanceStatus = aws.String(state)
+	if state != "available" {
+		return
+	}
+	if i.Endpoint.Address == nil {
+		i.Endpoint.Address = aws.String(f.endpoint(id))
+	}
+	if p := i.PendingModifiedValues; p != nil {
+		if p.DBInstanceClass != nil {
+			i.DBInstanceClass = p.DBInstanceClass
+		}
+		if p.AllocatedStorage != nil {
+			i.AllocatedStorage = *p.AllocatedStorage
+		}
+		if p.EngineVersion != nil {
+			i.EngineVersion = p.EngineVersion
+		}
+		if p.BackupRetentionPeriod != nil {
+			i.BackupRetentionPeriod = *p.BackupRetentionPeriod
+		}
+		if p.MultiAZ != nil {
+			i.MultiAZ = *p.MultiAZ
+		}
+		if p.StorageType != nil {
+			i.StorageType = p.StorageType
+		}
+		if p.Iops != nil {
+			i.Iops = p.Iops
+		}
+		if p.Port != nil {
+			i.Endpoint.Port = *p.Port
+		}
+		i.PendingModifiedValues = nil
+	}
+}
+
+func (f *RDS) ModifyDBInstance(ctx context.Context, params *rds.ModifyDBInstanceInput, optFns ...func(*rds.Options)) (*rds.ModifyDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBInstanceIdentifier)
+	i, ok := f.instances[id]
+	if !ok {
+		return nil, &rdstypes.DBInstanceNotFoundFault{Message: aws.String(fmt.Sprintf("DBInstance %v not found.", id))}
+	}
+	if state := aws.ToString(i.DBInstanceStatus); state == "deleting" || state == "creating" {
+		return nil, &rdstypes.InvalidDBInstanceStateFault{Message: aws.String(fmt.Sprintf("DB instance %v is %v", id, state))}
+	}
+
+	// the values that take the instance offline are pending until the modification is done, the others are
+	// applied right away
+	p := i.PendingModifiedValues
+	if p == nil {
+		p = &rdstypes.PendingModifiedValues{}
+	}
+	pending := false
+	if params.DBInstanceClass != nil {
+		p.DBInstanceClass = params.DBInstanceClass
+		pending = true
+	}
+	if params.AllocatedStorage != nil {
+		p.AllocatedStorage = params.AllocatedStorage
+		pending = true
+	}
+	if params.EngineVersion != nil {
+		if !params.AllowMajorVersionUpgrade && majorVersion(aws.ToString(params.EngineVersion)) != majorVersion(aws.ToString(i.EngineVersion)) {
+			return nil, apiError("InvalidParameterCombination", "The AllowMajorVersionUpgrade flag must be present when upgrading to a new major version.")
+		}
+		p.EngineVersion = params.EngineVersion
+		pending = true
+	}
+	if params.BackupRetentionPeriod != nil {
+		p.BackupRetentionPeriod = params.BackupRetentionPeriod
+		pending = true
+	}
+	if params.MultiAZ != nil {
+		p.MultiAZ = params.MultiAZ
+		pending = true
+	}
+	if params.StorageType != nil {
+		p.StorageType = params.StorageType
+		pending = true
+	}
+	if params.Iops != nil {
+		p.Iops = params.Iops
+		pending = true
+	}
+	if params.DBPortNumber != nil {
+		p.Port = params.DBPortNumber
+		pending = true
+	}
+	if params.MasterUserPassword != nil {
+		p.MasterUserPassword = aws.String("****")
+	}
+	if params.MaxAllocatedStorage != nil {
+		i.MaxAllocatedStorage = params.MaxAllocatedStorage
+	}
+	if params.PubliclyAccessible != nil {
+		i.PubliclyAccessible = *params.PubliclyAccessible
+	}
+	if params.DeletionProtection != nil {
+		i.DeletionProtection = *params.DeletionProtection
+	}
+	if params.VpcSecurityGroupIds != nil {
+		i.VpcSecurityGroups = securityGroupMemberships(params.VpcSecurityGroupIds)
+	}
+	if name := aws.ToString(params.DBParameterGroupName); name != "" {
+		if _, ok := f.parameterGroups[name]; !ok {
+			return nil, &rdstypes.DBParameterGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DB parameter group %v not found", name))}
+		}
+		// a new parameter group is applied on the next reboot
+		i.DBParameterGroups = []rdstypes.DBParameterGroupStatus{{DBParameterGroupName: aws.String(name), ParameterApplyStatus: aws.String("pending-reboot")}}
+	}
+	if name := aws.ToString(params.OptionGroupName); name != "" {
+		if _, ok := f.optionGroups[name]; !ok {
+			return nil, &rdstypes.OptionGroupNotFoundFault{Message: aws.String(fmt.Sprintf("option group %v not found", name))}
+		}
+		i.OptionGroupMemberships = []rdstypes.OptionGroupMembership{{OptionGroupName: aws.String(name), Status: aws.String("in-sync")}}
+	}
+
+	switch {
+	case pending:
+		i.PendingModifiedValues = p
+		i.DBInstanceStatus = aws.String("modifying")
+		i.next = []string{"available"}
+	case params.MasterUserPassword != nil:
+		i.PendingModifiedValues = p
+		i.DBInstanceStatus = aws.String("resetting-master-credentials")
+		i.next = []string{"available"}
+	case aws.ToString(i.DBInstanceStatus) != "available" && len(i.next) == 0:
+		// an instance put in a failed state by SetInstanceStatus recovers through a modification
+		i.DBInstanceStatus = aws.String("modifying")
+		i.next = []string{"available"}
+	}
+	return &rds.ModifyDBInstanceOutput{DBInstance: copyInstance(i)}, nil
+}
+
+func (f *RDS) DeleteDBInstance(ctx context.Context, params *rds.DeleteDBInstanceInput, optFns ...func(*rds.Options)) (*rds.DeleteDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBInstanceIdentifier)
+	i, ok := f.instances[id]
+	if !ok {
+		return nil, &rdstypes.DBInstanceNotFoundFault{Message: aws.String(fmt.Sprintf("DBInstance %v not found.", id))}
+	}
+	if aws.ToString(i.DBInstanceStatus) == "deleting" {
+		return nil, &rdstypes.InvalidDBInstanceStateFault{Message: aws.String(fmt.Sprintf("DB instance %v is already being deleted", id))}
+	}
+	if i.DeletionProtection {
+		return nil, apiError("InvalidParameterCombination", "Cannot delete protected DB Instance, please disable deletion protection and try again.")
+	}
+	if !params.SkipFinalSnapshot && i.DBClusterIdentifier == nil {
+		name := aws.ToString(params.FinalDBSnapshotIdentifier)
+		if name == "" {
+			return nil, apiError("InvalidParameterCombination", "FinalDBSnapshotIdentifier is required unless SkipFinalSnapshot is specified.")
+		}
+		if _, ok := f.snapshots[name]; ok {
+			return nil, &rdstypes.DBSnapshotAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB snapshot %v already exists", name))}
+		}
+		f.snapshot(i, name, "manual", "available")
+	}
+	i.DBInstanceStatus = aws.String("deleting")
+	i.next = []string{""}
+	return &rds.DeleteDBInstanceOutput{DBInstance: copyInstance(i)}, nil
+}
+
+func (f *RDS) DescribeDBEngineVersions(ctx context.Context, params *rds.DescribeDBEngineVersionsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBEngineVersionsOutput, error) {
+	engine := aws.ToString(params.Engine)
+	version := aws.ToString(params.EngineVersion)
+	if version == "" {
+		version = defaultVersions[engine]
+	}
+	if version == "" {
+		return &rds.DescribeDBEngineVersionsOutput{}, nil
+	}
+	return &rds.DescribeDBEngineVersionsOutput{DBEngineVersions: []rdstypes.DBEngineVersion{{
+		Engine:                 aws.String(engine),
+		EngineVersion:          aws.String(version),
+		DBParameterGroupFamily: aws.String(family(engine, version)),
+		MajorEngineVersion:     aws.String(majorVersion(version)),
+	}}}, nil
+}
+
+func (f *RDS) CreateDBCluster(ctx context.Context, params *rds.CreateDBClusterInput, optFns ...func(*rds.Options)) (*rds.CreateDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBClusterIdentifier)
+	if _, ok := f.clusters[id]; ok {
+		return nil, &rdstypes.DBClusterAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB cluster %v already exists", id))}
+	}
+	engine := aws.ToString(params.Engine)
+	c := &cluster{DBCluster: rdstypes.DBCluster{
+		DBClusterIdentifier:              aws.String(id),
+		DBClusterArn:                     f.arn("cluster", id),
+		DbClusterResourceId:              f.resourceID("cluster"),
+		Engine:                           aws.String(engine),
+		EngineVersion:                    params.EngineVersion,
+		DatabaseName:                     params.DatabaseName,
+		MasterUsername:                   params.MasterUsername,
+		StorageEncrypted:                 aws.ToBool(params.StorageEncrypted),
+		BackupRetentionPeriod:            params.BackupRetentionPeriod,
+		DeletionProtection:               params.DeletionProtection,
+		Port:                             params.Port,
+		ServerlessV2ScalingConfiguration: serverlessInfo(params.ServerlessV2ScalingConfiguration),
+		VpcSecurityGroups:                securityGroupMemberships(params.VpcSecurityGroupIds),
+		Status:                           aws.String("creating"),
+	}, next: []string{"backing-up", "available"}}
+	if c.EngineVersion == nil {
+		c.EngineVersion = aws.String(defaultVersions[engine])
+	}
+	if c.Port == nil {
+		c.Port = aws.Int32(defaultPort(engine))
+	}
+	if name := aws.ToString(params.DBSubnetGroupName); name != "" {
+		if _, ok := f.subnetGroups[name]; !ok {
+			return nil, &rdstypes.DBSubnetGroupNotFoundFault{Message: aws.String(fmt.Sprintf("DB subnet group %v not found", name))}
+		}
+		c.DBSubnetGroup = aws.String(name)
+	}
+	f.clusters[id] = c
+	f.addTags(aws.ToString(c.DBClusterArn), params.Tags)
+	return &rds.CreateDBClusterOutput{DBCluster: copyCluster(c)}, nil
+}
+
+func (f *RDS) DescribeDBClusters(ctx context.Context, params *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id := aws.ToString(params.DBClusterIdentifier); id != "" {
+		c, ok := f.clusters[id]
+		if !ok {
+			return nil, &rdstypes.DBClusterNotFoundFault{Message: aws.String(fmt.Sprintf("DBCluster %v not found.", id))}
+		}
+		out := &rds.DescribeDBClustersOutput{DBClusters: []rdstypes.DBCluster{*copyCluster(c)}}
+		f.advanceCluster(c)
+		return out, nil
+	}
+
+	out := &rds.DescribeDBClustersOutput{}
+	for _, id := range f.clusterIDs() {
+		c := f.clusters[id]
+		out.DBClusters = append(out.DBClusters, *copyCluster(c))
+		f.advanceCluster(c)
+	}
+	return out, nil
+}
+
+// advanceCluster moves the cluster to its next state, pending modifications are applied once it is available
+func (f *RDS) advanceCluster(c *cluster) {
+	if len(c.next) == 0 {
+		return
+	}
+	state := c.next[0]
+	c.next = c.next[1:]
+	id := aws.ToString(c.DBClusterIdentifier)
+	if state == "" {
+		// a cluster is only gone once its instances are, they were being deleted before the cluster
+		for _, m := range c.DBClusterMembers {
+			delete(f.instances, aws.ToString(m.DBInstanceIdentifier))
+		}
+		delete(f.clusters, id)
+		return
+	}
+	c.Status = aws.String(state)
+	if state != "available" {
+		return
+	}
+	if c.Endpoint == nil {
+		c.Endpoint = aws.String(id + ".cluster-fake." + f.Region + ".rds.amazonaws.com")
+		c.ReaderEndpoint = aws.String(id + ".cluster-ro-fake." + f.Region + ".rds.amazonaws.com")
+	}
+	if p := c.PendingModifiedValues; p != nil {
+		if p.EngineVersion != nil {
+			c.EngineVersion = p.EngineVersion
+		}
+		c.PendingModifiedValues = nil
+	}
+}
+
+func (f *RDS) ModifyDBCluster(ctx context.Context, params *rds.ModifyDBClusterInput, optFns ...func(*rds.Options)) (*rds.ModifyDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBClusterIdentifier)
+	c, ok := f.clusters[id]
+	if !ok {
+		return nil, &rdstypes.DBClusterNotFoundFault{Message: aws.String(fmt.Sprintf("DBCluster %v not found.", id))}
+	}
+	if aws.ToString(c.Status) == "deleting" {
+		return nil, &rdstypes.InvalidDBClusterStateFault{Message: aws.String(fmt.Sprintf("DB cluster %v is being deleted", id))}
+	}
+	if params.EngineVersion != nil {
+		c.PendingModifiedValues = &rdstypes.ClusterPendingModifiedValues{EngineVersion: params.EngineVersion}
+	}
+	if params.BackupRetentionPeriod != nil {
+		c.BackupRetentionPeriod = params.BackupRetentionPeriod
+	}
+	if params.DeletionProtection != nil {
+		c.DeletionProtection = params.DeletionProtection
+	}
+	if params.Port != nil {
+		c.Port = params.Port
+	}
+	if params.ServerlessV2ScalingConfiguration != nil {
+		c.ServerlessV2ScalingConfiguration = serverlessInfo(params.ServerlessV2ScalingConfiguration)
+	}
+	if params.VpcSecurityGroupIds != nil {
+		c.VpcSecurityGroups = securityGroupMemberships(params.VpcSecurityGroupIds)
+	}
+	c.Status = aws.String("modifying")
+	c.next = []string{"available"}
+	return &rds.ModifyDBClusterOutput{DBCluster: copyCluster(c)}, nil
+}
+
+func (f *RDS) DeleteDBCluster(ctx context.Context, params *rds.DeleteDBClusterInput, optFns ...func(*rds.Options)) (*rds.DeleteDBClusterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBClusterIdentifier)
+	c, ok := f.clusters[id]
+	if !ok {
+		return nil, &rdstypes.DBClusterNotFoundFault{Message: aws.String(fmt.Sprintf("DBCluster %v not found.", id))}
+	}
+	if aws.ToBool(c.DeletionProtection) {
+		return nil, apiError("InvalidParameterCombination", "Cannot delete protected Cluster, please disable deletion protection and try again.")
+	}
+	for _, m := range c.DBClusterMembers {
+		if i, ok := f.instances[aws.ToString(m.DBInstanceIdentifier)]; ok && aws.ToString(i.DBInstanceStatus) != "deleting" {
+			return nil, &rdstypes.InvalidDBClusterStateFault{Message: aws.String("Cluster cannot be deleted, it still contains DB instances in non-deleting state.")}
+		}
+	}
+	if !params.SkipFinalSnapshot && aws.ToString(params.FinalDBSnapshotIdentifier) == "" {
+		return nil, apiError("InvalidParameterCombination", "FinalDBSnapshotIdentifier is required unless SkipFinalSnapshot is specified.")
+	}
+	c.Status = aws.String("deleting")
+	c.next = []string{""}
+	return &rds.DeleteDBClusterOutput{DBCluster: copyCluster(c)}, nil
+}
+
+func (f *RDS) CreateDBSnapshot(ctx context.Context, params *rds.CreateDBSnapshotInput, optFns ...func(*rds.Options)) (*rds.CreateDBSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := aws.ToString(params.DBInstanceIdentifier)
+	i, ok := f.instances[id]
+	if !ok {
+		return nil, &rdstypes.DBInstanceNotFoundFault{Message: aws.String(fmt.Sprintf("DBInstance %v not found.", id))}
+	}
+	if aws.ToString(i.DBInstanceStatus) != "available" {
+		return nil, &rdstypes.InvalidDBInstanceStateFault{Message: aws.String(fmt.Sprintf("DB instance %v is not available", id))}
+	}
+	name := aws.ToString(params.DBSnapshotIdentifier)
+	if _, ok := f.snapshots[name]; ok {
+		return nil, &rdstypes.DBSnapshotAlreadyExistsFault{Message: aws.String(fmt.Sprintf("DB snapshot %v already exists", name))}
+	}
+	s := f.snapshot(i, name, "manual", "creating", "available")
+	f.addTags(aws.ToString(s.DBSnapshotArn), params.Tags)
+	return &rds.CreateDBSnapshotOutput{DBSnapshot: copySnapshot(s)}, nil
+}
+
+// snapshot stores a snapshot of the instance in the first of the states
+func (f *RDS) snapshot(i *instance, name string, snapshotType string, states ...string) *snapshot {
+	s := &snapshot{DBSnapshot: rdstypes.DBSnapshot{
+		DBSnapshotIdentifier: aws.String(name),
+		DBSnapshotArn:        f.arn("snapshot", name),
+		DBInstanceIdentifier: i.DBInstanceIdentifier,
+		SnapshotType:         aws.String(snapshotType),
+		Engine:               i.Engine,
+		EngineVersion:        i.EngineVersion,
+		MasterUsername:       i.MasterUsername,
+		AllocatedStorage:     i.AllocatedStorage,
+		StorageType:          i.StorageType,
+		Iops:                 i.Iops,
+		Encrypted:            i.StorageEncrypted,
+		SnapshotCreateTime:   aws.Time(time.Now()),
+		Status:               aws.String(states[0]),
+	}, next: states[1:]}
+	if states[0] == "available" {
+		s.PercentProgress = 100
+	}
+	f.snapshots[name] = s
+	return s
+}
+
+func (f *RDS) DescribeDBSnapshots(ctx context.Context, params *rds.DescribeDBSnapshotsInput, optFns ...func(*rds.Options)) (*rds.DescribeDBSnapshotsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &rds.DescribeDBSnapshotsOutput{}
+	if name := aws.ToString(params.DBSnapshotIdentifier); name != "" {
+		if _, ok := f.snapshots[name]; !ok {
+			return nil, &rdstypes.DBSnapshotNotFoundFault{Message: aws.String(fmt.Sprintf("DBSnapshot %v not found.", name))}
+		}
+	}
+	for _, name := range f.snapshotIDs() {
+		s := f.snapshots[name]
+		if id := aws.ToString(params.DBSnapshotIdentifier); id != "" && id != name {
+			continue
+		}
+		if id := aws.ToString(params.DBInstanceIdentifier); id != "" && id != aws.ToString(s.DBInstanceIdentifier) {
+			continue
+		}
+		out.DBSnapshots = append(out.DBSnapshots, *copySnapshot(s))
+		if len(s.next) > 0 {
+			s.Status = aws.String(s.next[0])
+			s.next = s.next[1:]
+			if aws.ToString(s.Status) == "available" {
+				s.PercentProgress = 100
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *RDS) DeleteDBSnapshot(ctx context.Context, params *rds.DeleteDBSnapshotInput, optFns ...func(*rds.Options)) (*rds.DeleteDBSnapshotOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(params.DBSnapshotIdentifier)
+	s, ok := f.snapshots[name]
+	if !ok {
+		return nil, &rdstypes.DBSnapshotNotFoundFault{Message: aws.String(fmt.Sprintf("DBSnapshot %v not found.", name))}
+	}
+	delete(f.snapshots, name)
+	delete(f.tags, aws.ToString(s.DBSnapshotArn))
+	s.Status = aws.String("deleted")
+	return &rds.DeleteDBSnapshotOutput{DBSnapshot: copySnapshot(s)}, nil
+}
+
+func copyInstance(i *instance) *rdstypes.DBInstance {
+	c := i.DBInstance
+	if i.Endpoint != nil {
+		endpoint := *i.Endpoint
+		c.Endpoint = &endpoint
+		// the address is only known once the instance has been available
+		if endpoint.Address == nil {
+			c.Endpoint = nil
+		}
+	}
+	if i.PendingModifiedValues != nil {
+		pending := *i.PendingModifiedValues
+		c.PendingModifiedValues = &pending
+	}
+	c.DBParameterGroups = append([]rdstypes.DBParameterGroupStatus{}, i.DBParameterGroups...)
+	c.OptionGroupMemberships = append([]rdstypes.OptionGroupMembership{}, i.OptionGroupMemberships...)
+	c.VpcSecurityGroups = append([]rdstypes.VpcSecurityGroupMembership{}, i.VpcSecurityGroups...)
+	c.ReadReplicaDBInstanceIdentifiers = append([]string{}, i.ReadReplicaDBInstanceIdentifiers...)
+	return &c
+}
+
+func copyCluster(c *cluster) *rdstypes.DBCluster {
+	out := c.DBCluster
+	out.DBClusterMembers = append([]rdstypes.DBClusterMember{}, c.DBClusterMembers...)
+	return &out
+}
+
+func copySnapshot(s *snapshot) *rdstypes.DBSnapshot {
+	out := s.DBSnapshot
+	return &out
+}
+
+func securityGroupMemberships(ids []string) []rdstypes.VpcSecurityGroupMembership {
+	var groups []rdstypes.VpcSecurityGroupMembership
+	for _, id := range ids {
+		groups = append(groups, rdstypes.VpcSecurityGroupMembership{VpcSecurityGroupId: aws.String(id), Status: aws.String("active")})
+	}
+	return groups
+}
+
+func serverlessInfo(s *rdstypes.ServerlessV2ScalingConfiguration) *rdstypes.ServerlessV2ScalingConfigurationInfo {
+	if s == nil {
+		return nil
+	}
+	return &rdstypes.ServerlessV2ScalingConfigurationInfo{MinCapacity: s.MinCapacity, MaxCapacity: s.MaxCapacity}
+}
+
+func defaultPort(engine string) int32 {
+	if strings.Contains(engine, "postgres") {
+		return 5432
+	}
+	return 3306
+}
+
+// majorVersion is the major version of an engine version, ex 13 for 13.4 and 5.7 for 5.7.38
+func majorVersion(version string) string {
+	parts := strings.Split(version, ".")
+	if n, err := strconv.Atoi(parts[0]); err == nil && n >= 10 || len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// family is the parameter group family of an engine version, ex postgres13
+func family(engine, version string) string {
+	return engine + majorVersion(version)
+}
+
+// instanceIDs returns the identifiers of the instances in order, the other resources are listed the same way
+func (f *RDS) instanceIDs() []string {
+	var ids []string
+	for id := range f.instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (f *RDS) clusterIDs() []string {
+	var ids []string
+	for id := range f.clusters {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (f *RDS) snapshotIDs() []string {
+	var ids []string
+	for id := range f.snapshots {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func remove(ids []string, id string) []string {
+	var out []string
+	for _, i := range ids {
+		if i != id {
+			out = append(out, i)
+		}
+	}
+	return out
+}
@@ -0,0 +1,436 @@
+package rds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/kube"
+	"github.com/sorenmat/k8s-rds/provider"
+	"github.com/sorenmat/k8s-rds/rds/fake"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	_ RDSAPI = (*fake.RDS)(nil)
+	_ EC2API = (*fake.EC2)(nil)
+)
+
+// newFakeRDS returns a provider backed by the in-memory RDS and EC2 backends, it doesn't wait for instances
+func newFakeRDS(svc *fake.RDS, ec2svc *fake.EC2) *RDS {
+	secret := &v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "password", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	regions := map[string]*fake.RDS{}
+	return &RDS{
+		EC2:             ec2svc,
+		Config:          aws.Config{Region: "eu-west-1"},
+		Subnets:         []string{"subnet-a", "subnet-b"},
+		SecurityGroups:  []string{"sg-nodes"},
+		VpcId:           "vpc-1",
+		ServiceProvider: kube.New(kubefake.NewSimpleClientset(secret)),
+		PollInterval:    time.Millisecond,
+		Client:          svc,
+		RegionClient: func(region string) RDSAPI {
+			if regions[region] == nil {
+				regions[region] = fake.NewRDS(region)
+			}
+			return regions[region]
+		},
+	}
+}
+
+func fakeDatabase() *databasev1.Database {
+	return &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "orders", Namespace: "default"},
+		Spec: databasev1.DatabaseSpec{
+			DBName:                "orders",
+			Engine:                "postgres",
+			Username:              "postgres",
+			Class:                 "db.t3.micro",
+			Size:                  20,
+			BackupRetentionPeriod: 7,
+			Password:              databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "password"}},
+		},
+	}
+}
+
+func pendingState(err error) string {
+	var pending *provider.PendingError
+	if errors.As(err, &pending) {
+		return pending.State
+	}
+	return ""
+}
+
+func TestDatabaseLifecycle(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	ec2svc := fake.NewEC2()
+	r := newFakeRDS(svc, ec2svc)
+	db := fakeDatabase()
+
+	// the instance is still being created when WaitTimeout runs out
+	_, err := r.CreateDatabase(ctx, db)
+	assert.Equal(t, "creating", pendingState(err))
+
+	r.WaitTimeout = time.Second
+	info, err := r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders-default.fake.eu-west-1.rds.amazonaws.com", info.Hostname)
+	assert.Equal(t, int32(5432), info.Port)
+	assert.Equal(t, "13.4", info.EngineVersion)
+	assert.Equal(t, "in-sync", info.ParameterApplyStatus)
+
+	instance, err := describeInstance(ctx, svc, "orders-default")
+	assert.NoError(t, err)
+	assert.Equal(t, "db-subnetgroup-vpc-1", attachedSubnetGroup(instance))
+	groups, err := ec2svc.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(groups.SecurityGroups))
+	assert.Equal(t, []string{aws.ToString(groups.SecurityGroups[0].GroupId)}, instanceSecurityGroups(instance))
+	assert.Equal(t, "sg-nodes", aws.ToString(groups.SecurityGroups[0].IpPermissions[0].UserIdGroupPairs[0].GroupId))
+
+	// nothing to change when the spec matches the instance
+	db.Status.ParameterApplyStatus = info.ParameterApplyStatus
+	assert.NoError(t, r.UpdateDatabase(ctx, db))
+
+	db.Spec.Class = "db.t3.small"
+	db.Spec.Size = 50
+	err = r.UpdateDatabase(ctx, db)
+	assert.Equal(t, "modifying", pendingState(err))
+	instance, err = describeInstance(ctx, svc, "orders-default")
+	assert.NoError(t, err)
+	assert.Equal(t, "db.t3.small", aws.ToString(instance.PendingModifiedValues.DBInstanceClass))
+	_, err = r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+	instance, err = describeInstance(ctx, svc, "orders-default")
+	assert.NoError(t, err)
+	assert.Equal(t, "db.t3.small", aws.ToString(instance.DBInstanceClass))
+	assert.Equal(t, int32(50), instance.AllocatedStorage)
+	assert.Nil(t, convertSpecToModifyInput(db, instance, instanceSecurityGroups(instance), "", ""))
+
+	err = r.DeleteDatabase(ctx, db)
+	assert.NoError(t, err)
+	_, err = describeInstance(ctx, svc, "orders-default")
+	assert.True(t, isInstanceNotFound(err))
+	snapshots, err := svc.DescribeDBSnapshots(ctx, &rds.DescribeDBSnapshotsInput{DBInstanceIdentifier: aws.String("orders-default")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(snapshots.DBSnapshots))
+	_, err = svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String("db-subnetgroup-vpc-1")})
+	assert.True(t, isSubnetGroupNotFound(err))
+	groups, err = ec2svc.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(groups.SecurityGroups))
+}
+
+func TestDatabaseDeletionPending(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	r := newFakeRDS(svc, fake.NewEC2())
+	r.WaitTimeout = time.Second
+	db := fakeDatabase()
+	db.Spec.SkipFinalSnapshot = true
+	_, err := r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+
+	r.WaitTimeout = 0
+	err = r.DeleteDatabase(ctx, db)
+	assert.Equal(t, "deleting", pendingState(err))
+	// the next reconcile finds the instance gone and cleans up
+	assert.NoError(t, r.DeleteDatabase(ctx, db))
+	snapshots, err := svc.DescribeDBSnapshots(ctx, &rds.DescribeDBSnapshotsInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(snapshots.DBSnapshots))
+}
+
+func TestDatabaseFailedState(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	r := newFakeRDS(svc, fake.NewEC2())
+	r.WaitTimeout = time.Second
+	db := fakeDatabase()
+	_, err := r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+
+	svc.SetInstanceStatus("orders-default", "storage-full")
+	_, err = r.CreateDatabase(ctx, db)
+	assert.EqualError(t, err, "db instance orders-default is in state storage-full")
+}
+
+func TestDatabaseParameters(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	svc.Parameters["postgres13"] = []rdstypes.Parameter{
+		{ParameterName: aws.String("log_min_duration_statement"), ParameterValue: aws.String("-1"), ApplyType: aws.String("dynamic"), IsModifiable: true},
+		{ParameterName: aws.String("shared_buffers"), ApplyType: aws.String("static"), IsModifiable: true},
+	}
+	r := newFakeRDS(svc, fake.NewEC2())
+	r.WaitTimeout = time.Second
+	db := fakeDatabase()
+	db.Spec.Parameters = map[string]string{"log_min_duration_statement": "500"}
+
+	info, err := r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, "in-sync", info.ParameterApplyStatus)
+	instance, err := describeInstance(ctx, svc, "orders-default")
+	assert.NoError(t, err)
+	assert.Equal(t, "orders-default", attachedParameterGroup(instance))
+
+	// static parameters wait for a reboot
+	db.Status.ParameterApplyStatus = info.ParameterApplyStatus
+	db.Spec.Parameters = map[string]string{"shared_buffers": "16384"}
+	err = r.UpdateDatabase(ctx, db)
+	assert.Equal(t, "modifying", pendingState(err))
+	info, err = r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending-reboot", info.ParameterApplyStatus)
+
+	params, err := svc.DescribeDBParameters(ctx, &rds.DescribeDBParametersInput{DBParameterGroupName: aws.String("orders-default"), Source: aws.String("user")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(params.Parameters))
+	assert.Equal(t, "shared_buffers", aws.ToString(params.Parameters[0].ParameterName))
+}
+
+func TestReadReplicas(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	r := newFakeRDS(svc, fake.NewEC2())
+	r.WaitTimeout = time.Second
+	db := fakeDatabase()
+	db.Spec.ReadReplicas = &databasev1.ReadReplicas{Count: 2}
+	_, err := r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+
+	replicas, err := r.EnsureReadReplicas(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(replicas))
+	assert.Equal(t, "creating", replicas[0].State)
+	// the replicas are described as creating once and are available the next time
+	_, err = r.EnsureReadReplicas(ctx, db)
+	assert.NoError(t, err)
+	replicas, err = r.EnsureReadReplicas(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, "available", replicas[1].State)
+	assert.Equal(t, "orders-default-ro-1.fake.eu-west-1.rds.amazonaws.com", replicas[1].Hostname)
+
+	db.Spec.ReadReplicas.Count = 1
+	replicas, err = r.EnsureReadReplicas(ctx, db)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(replicas))
+	instance, err := describeInstance(ctx, svc, "orders-default-ro-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "deleting", aws.ToString(instance.DBInstanceStatus))
+}
+
+func TestUpdateReconciledDatabase(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	svc.Parameters["postgres13"] = []rdstypes.Parameter{
+		{ParameterName: aws.String("log_min_duration_statement"), ParameterValue: aws.String("-1"), ApplyType: aws.String("dynamic"), IsModifiable: true},
+	}
+	r := newFakeRDS(svc, fake.NewEC2())
+	r.WaitTimeout = time.Second
+	db := fakeDatabase()
+	db.Spec.Tags = "team=a"
+	info, err := r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+	db.Generation = 2
+	db.Status.ObservedGeneration = 2
+	db.Status.ParameterApplyStatus = info.ParameterApplyStatus
+
+	// the groups of an applied generation aren't looked at again
+	db.Spec.Parameters = map[string]string{"log_min_duration_statement": "500"}
+	db.Spec.Tags = "team=b,env=prod"
+	assert.NoError(t, r.UpdateDatabase(ctx, db))
+	instance, err := describeInstance(ctx, svc, "orders-default")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "orders-default", attachedParameterGroup(instance))
+	tags, err := svc.ListTagsForResource(ctx, &rds.ListTagsForResourceInput{ResourceName: instance.DBInstanceArn})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []rdstypes.Tag{
+		{Key: aws.String("team"), Value: aws.String("b")},
+		{Key: aws.String("env"), Value: aws.String("prod")},
+	}, tags.TagList)
+
+	db.Generation = 3
+	err = r.UpdateDatabase(ctx, db)
+	assert.Equal(t, "modifying", pendingState(err))
+	instance, err = describeInstance(ctx, svc, "orders-default")
+	assert.NoError(t, err)
+	assert.Equal(t, "orders-default", attachedParameterGroup(instance))
+}
+
+func TestDatabaseSubnetTags(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	ec2svc := fake.NewEC2(
+		ec2types.Subnet{SubnetId: aws.String("subnet-db-a"), VpcId: aws.String("vpc-1"), Tags: []ec2types.Tag{{Key: aws.String("tier"), Value: aws.String("db")}}},
+		ec2types.Subnet{SubnetId: aws.String("subnet-db-b"), VpcId: aws.String("vpc-1"), Tags: []ec2types.Tag{{Key: aws.String("tier"), Value: aws.String("db")}}},
+		ec2types.Subnet{SubnetId: aws.String("subnet-app"), VpcId: aws.String("vpc-1"), Tags: []ec2types.Tag{{Key: aws.String("tier"), Value: aws.String("app")}}},
+	)
+	r := newFakeRDS(svc, ec2svc)
+	r.WaitTimeout = time.Second
+	db := fakeDatabase()
+	db.Spec.SkipFinalSnapshot = true
+	db.Spec.SubnetGroup = &databasev1.SubnetGroup{SubnetTags: map[string]string{"tier": "db"}}
+
+	_, err := r.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+	res, err := svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String("orders-subnet-default")})
+	assert.NoError(t, err)
+	assert.True(t, sameSubnets(res.DBSubnetGroups[0].Subnets, []string{"subnet-db-a", "subnet-db-b"}))
+
+	assert.NoError(t, r.DeleteDatabase(ctx, db))
+	res, err = svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(res.DBSubnetGroups))
+}
+
+func TestPublicAndPrivateSubnetGroups(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	ec2svc := fake.NewEC2()
+	private := newFakeRDS(svc, ec2svc)
+	private.WaitTimeout = time.Second
+	public := newFakeRDS(svc, ec2svc)
+	public.WaitTimeout = time.Second
+	public.Public = true
+	public.Subnets = []string{"subnet-public-a", "subnet-public-b"}
+
+	db := fakeDatabase()
+	db.Spec.SkipFinalSnapshot = true
+	_, err := private.CreateDatabase(ctx, db)
+	assert.NoError(t, err)
+	publicDB := fakeDatabase()
+	publicDB.Name = "shop"
+	publicDB.Spec.PubliclyAccessible = true
+	publicDB.Spec.SkipFinalSnapshot = true
+	_, err = public.CreateDatabase(ctx, publicDB)
+	assert.NoError(t, err)
+
+	instance, err := describeInstance(ctx, svc, "shop-default")
+	assert.NoError(t, err)
+	assert.Equal(t, "db-subnetgroup-public-vpc-1", attachedSubnetGroup(instance))
+	res, err := svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String("db-subnetgroup-public-vpc-1")})
+	assert.NoError(t, err)
+	assert.True(t, sameSubnets(res.DBSubnetGroups[0].Subnets, public.Subnets))
+
+	// each group is deleted with the last database using it
+	assert.NoError(t, private.DeleteDatabase(ctx, db))
+	_, err = svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String("db-subnetgroup-vpc-1")})
+	assert.True(t, isSubnetGroupNotFound(err))
+	_, err = svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String("db-subnetgroup-public-vpc-1")})
+	assert.NoError(t, err)
+	assert.NoError(t, public.DeleteDatabase(ctx, publicDB))
+	_, err = svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String("db-subnetgroup-public-vpc-1")})
+	assert.True(t, isSubnetGroupNotFound(err))
+}
+
+func TestSharedSubnetGroupWithoutSubnets(t *testing.T) {
+	r := newFakeRDS(fake.NewRDS("eu-west-1"), fake.NewEC2())
+	r.Subnets = nil
+	_, err := r.CreateDatabase(context.Background(), fakeDatabase())
+	assert.EqualError(t, err, "no subnets found in VPC vpc-1 for the shared subnet group, set them with --aws-subnets or --aws-public-subnets")
+}
+
+func TestClusterLifecycle(t *testing.T) {
+	ctx := context.Background()
+	svc := fake.NewRDS("eu-west-1")
+	ec2svc := fake.NewEC2()
+	r := newFakeRDS(svc, ec2svc)
+	r.WaitTimeout = time.Second
+	c := &databasev1.DatabaseCluster{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "orders", Namespace: "default"},
+		Spec: databasev1.DatabaseClusterSpec{
+			Engine:            "aurora-postgresql",
+			DBName:            "orders",
+			Username:          "postgres",
+			Password:          v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "password"},
+			Class:             "db.r6g.large",
+			Instances:         2,
+			SkipFinalSnapshot: true,
+		},
+	}
+
+	info, err := r.CreateCluster(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders-default.cluster-fake.eu-west-1.rds.amazonaws.com", info.Hostname)
+	assert.Equal(t, []string{"orders-default-0", "orders-default-1"}, info.Members)
+	cluster, err := describeCluster(ctx, svc, "orders-default")
+	assert.NoError(t, err)
+	groups, err := ec2svc.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(groups.SecurityGroups))
+	assert.Equal(t, "k8s-rds-cluster-orders-default", aws.ToString(groups.SecurityGroups[0].GroupName))
+	assert.Equal(t, []string{aws.ToString(groups.SecurityGroups[0].GroupId)}, clusterSecurityGroups(cluster))
+
+	c.Spec.Instances = 1
+	err = r.UpdateCluster(ctx, c)
+	assert.Equal(t, "modifying", pendingState(err))
+	info, err = r.CreateCluster(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders-default-0"}, info.Members)
+
+	assert.NoError(t, r.DeleteCluster(ctx, c))
+	_, err = describeCluster(ctx, svc, "orders-default")
+	assert.True(t, isClusterNotFound(err))
+	res, err := svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(res.DBSubnetGroups))
+	groups, err = ec2svc.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(groups.SecurityGroups))
+}
+
+// tagCounter counts the calls writing tags
+type tagCounter struct {
+	*fake.RDS
+	writes []rds.AddTagsToResourceInput
+}
+
+func (t *tagCounter) AddTagsToResource(ctx context.Context, params *rds.AddTagsToResourceInput, optFns ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error) {
+	t.writes = append(t.writes, *params)
+	return t.RDS.AddTagsToResource(ctx, params, optFns...)
+}
+
+func TestUpdateClusterTags(t *testing.T) {
+	ctx := context.Background()
+	svc := &tagCounter{RDS: fake.NewRDS("eu-west-1")}
+	r := newFakeRDS(svc.RDS, fake.NewEC2())
+	r.Client = svc
+	r.WaitTimeout = time.Second
+	c := &databasev1.DatabaseCluster{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "orders", Namespace: "default"},
+		Spec: databasev1.DatabaseClusterSpec{
+			Engine:   "aurora-postgresql",
+			DBName:   "orders",
+			Username: "postgres",
+			Password: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "password"},
+			Class:    "db.r6g.large",
+			Tags:     "team=a",
+		},
+	}
+	_, err := r.CreateCluster(ctx, c)
+	assert.NoError(t, err)
+
+	// unchanged tags aren't written again on every resync
+	assert.NoError(t, r.UpdateCluster(ctx, c))
+	assert.Equal(t, 0, len(svc.writes))
+
+	c.Spec.Tags = "team=a,env=prod"
+	assert.NoError(t, r.UpdateCluster(ctx, c))
+	assert.Equal(t, 1, len(svc.writes))
+	assert.Equal(t, []rdstypes.Tag{{Key: aws.String("env"), Value: aws.String("prod")}}, svc.writes[0].Tags)
+}
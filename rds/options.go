@@ -0,0 +1,176 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+)
+
+// ensureOptionGroup creates the option group of the database when it has options and brings the group in line with
+// spec.options, options removed from the spec are removed from the group. It returns the name of the group, or an
+// empty name when the database uses the default group. Options listening on a port get the security groups
+func (r *RDS) ensureOptionGroup(ctx context.Context, svc RDSAPI, db *databasev1.Database, securityGroups []string) (string, error) {
+	name := optiongroupname(db)
+	var current []rdstypes.Option
+	res, err := svc.DescribeOptionGroups(ctx, &rds.DescribeOptionGroupsInput{OptionGroupName: aws.String(name)})
+	if isOptionGroupNotFound(err) {
+		if len(db.Spec.Options) == 0 {
+			return "", nil
+		}
+		version, err := engineVersion(ctx, svc, db)
+		if err != nil {
+			return "", err
+		}
+		log.Printf("Creating option group %v for %v %v\n", name, db.Spec.Engine, aws.ToString(version.MajorEngineVersion))
+		_, err = svc.CreateOptionGroup(ctx, &rds.CreateOptionGroupInput{
+			OptionGroupName:        aws.String(name),
+			EngineName:             aws.String(db.Spec.Engine),
+			MajorEngineVersion:     version.MajorEngineVersion,
+			OptionGroupDescription: aws.String(fmt.Sprintf("Options of database %v/%v", db.Namespace, db.Name)),
+			Tags:                   []rdstypes.Tag{{Key: aws.String("Warning"), Value: aws.String("Managed by k8s-rds.")}},
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "CreateOptionGroup")
+		}
+	} else if err != nil {
+		return "", errors.Wrap(err, "DescribeOptionGroups")
+	} else if len(res.OptionGroupsList) > 0 {
+		current = res.OptionGroupsList[0].Options
+	}
+
+	include, remove := optionChanges(db.Spec.Options, current, securityGroups)
+	if len(include) == 0 && len(remove) == 0 {
+		return name, nil
+	}
+	log.Printf("Modifying option group %v, adding or changing %d options and removing %d\n", name, len(include), len(remove))
+	_, err = svc.ModifyOptionGroup(ctx, &rds.ModifyOptionGroupInput{
+		OptionGroupName:  aws.String(name),
+		OptionsToInclude: include,
+		OptionsToRemove:  remove,
+		ApplyImmediately: true,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "ModifyOptionGroup")
+	}
+	return name, nil
+}
+
+// optionChanges compares the wanted options with the options of the group. Options are included again when their
+// version, port or one of the wanted settings differ, settings that aren't in the spec keep their value. Permanent
+// options can't be removed from a group and are left alone
+func optionChanges(wanted []databasev1.DatabaseOption, current []rdstypes.Option, securityGroups []string) (include []rdstypes.OptionConfiguration, remove []string) {
+	byName := map[string]rdstypes.Option{}
+	for _, o := range current {
+		byName[aws.ToString(o.OptionName)] = o
+	}
+
+	names := map[string]bool{}
+	for _, o := range wanted {
+		names[o.Name] = true
+		if c, ok := byName[o.Name]; ok && sameOption(o, c) {
+			continue
+		}
+		include = append(include, toOptionConfiguration(o, securityGroups))
+	}
+
+	for _, o := range current {
+		name := aws.ToString(o.OptionName)
+		if names[name] {
+			continue
+		}
+		if o.Permanent {
+			log.Printf("option %v is permanent and can't be removed\n", name)
+			continue
+		}
+		remove = append(remove, name)
+	}
+	return include, remove
+}
+
+// sameOption reports whether the option of the group matches the option of the spec
+func sameOption(wanted databasev1.DatabaseOption, current rdstypes.Option) bool {
+	if wanted.Version != "" && wanted.Version != aws.ToString(current.OptionVersion) {
+		return false
+	}
+	if wanted.Port > 0 && wanted.Port != aws.ToInt32(current.Port) {
+		return false
+	}
+	settings := map[string]string{}
+	for _, s := range current.OptionSettings {
+		settings[aws.ToString(s.Name)] = aws.ToString(s.Value)
+	}
+	for name, value := range wanted.Settings {
+		if settings[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// toOptionConfiguration converts an option of the spec, options listening on a port get the security groups of
+// the instance
+func toOptionConfiguration(o databasev1.DatabaseOption, securityGroups []string) rdstypes.OptionConfiguration {
+	c := rdstypes.OptionConfiguration{OptionName: aws.String(o.Name)}
+	if o.Version != "" {
+		c.OptionVersion = aws.String(o.Version)
+	}
+	if o.Port > 0 {
+		c.Port = aws.Int32(o.Port)
+		c.VpcSecurityGroupMemberships = securityGroups
+	}
+
+	names := make([]string, 0, len(o.Settings))
+	for name := range o.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.OptionSettings = append(c.OptionSettings, rdstypes.OptionSetting{Name: aws.String(name), Value: aws.String(o.Settings[name])})
+	}
+	return c
+}
+
+// deleteOptionGroup deletes the option group of the database, the instance has to be deleted first. A group that is
+// still used by the final snapshot of the database can't be deleted and is kept
+func deleteOptionGroup(ctx context.Context, svc RDSAPI, db *databasev1.Database) error {
+	name := optiongroupname(db)
+	_, err := svc.DeleteOptionGroup(ctx, &rds.DeleteOptionGroupInput{OptionGroupName: aws.String(name)})
+	if isOptionGroupNotFound(err) {
+		return nil
+	}
+	var invalidState *rdstypes.InvalidOptionGroupStateFault
+	if errors.As(err, &invalidState) {
+		log.Printf("Keeping option group %v, it is still in use: %v\n", name, err)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to delete option group %v", name))
+	}
+	log.Println("Deleted option group: ", name)
+	return nil
+}
+
+// attachedOptionGroup returns the name of the option group of the instance
+func attachedOptionGroup(instance *rdstypes.DBInstance) string {
+	if len(instance.OptionGroupMemberships) == 0 {
+		return ""
+	}
+	return aws.ToString(instance.OptionGroupMemberships[0].OptionGroupName)
+}
+
+func isOptionGroupNotFound(err error) bool {
+	var notFound *rdstypes.OptionGroupNotFoundFault
+	return errors.As(err, &notFound)
+}
+
+// optiongroupname is the name of the option group owned by the database
+func optiongroupname(db *databasev1.Database) string {
+	return dbidentifier(db)
+}
@@ -0,0 +1,60 @@
+package rds
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionChanges(t *testing.T) {
+	current := []rdstypes.Option{
+		{
+			OptionName:    aws.String("MARIADB_AUDIT_PLUGIN"),
+			OptionVersion: aws.String("1.1"),
+			OptionSettings: []rdstypes.OptionSetting{
+				{Name: aws.String("SERVER_AUDIT_EVENTS"), Value: aws.String("CONNECT")},
+				{Name: aws.String("SERVER_AUDIT_FILE_ROTATIONS"), Value: aws.String("9")},
+			},
+		},
+		{OptionName: aws.String("MEMCACHED"), Port: aws.Int32(11211)},
+		{OptionName: aws.String("TDE"), Permanent: true},
+	}
+
+	wanted := []databasev1.DatabaseOption{
+		{Name: "MARIADB_AUDIT_PLUGIN", Settings: map[string]string{"SERVER_AUDIT_EVENTS": "CONNECT"}},
+		{Name: "MEMCACHED", Port: 11211},
+	}
+	include, remove := optionChanges(wanted, current, []string{"sg-1"})
+	assert.Empty(t, include)
+	assert.Empty(t, remove)
+
+	wanted = []databasev1.DatabaseOption{
+		{Name: "MARIADB_AUDIT_PLUGIN", Settings: map[string]string{"SERVER_AUDIT_EVENTS": "CONNECT,QUERY"}},
+		{Name: "SQLSERVER_BACKUP_RESTORE", Settings: map[string]string{"IAM_ROLE_ARN": "arn:aws:iam::123456789012:role/backup"}},
+	}
+	include, remove = optionChanges(wanted, current, []string{"sg-1"})
+	assert.Len(t, include, 2)
+	assert.Equal(t, "MARIADB_AUDIT_PLUGIN", *include[0].OptionName)
+	assert.Equal(t, "CONNECT,QUERY", *include[0].OptionSettings[0].Value)
+	assert.Equal(t, "SQLSERVER_BACKUP_RESTORE", *include[1].OptionName)
+	// the permanent TDE option stays in the group
+	assert.Equal(t, []string{"MEMCACHED"}, remove)
+}
+
+func TestToOptionConfiguration(t *testing.T) {
+	c := toOptionConfiguration(databasev1.DatabaseOption{Name: "MEMCACHED", Version: "1.0", Port: 11211, Settings: map[string]string{"MAX_SIMULTANEOUS_CONNECTIONS": "2048", "BINDING_PROTOCOL": "auto"}}, []string{"sg-1"})
+	assert.Equal(t, "MEMCACHED", *c.OptionName)
+	assert.Equal(t, "1.0", *c.OptionVersion)
+	assert.Equal(t, int32(11211), *c.Port)
+	assert.Equal(t, []string{"sg-1"}, c.VpcSecurityGroupMemberships)
+	assert.Len(t, c.OptionSettings, 2)
+	assert.Equal(t, "BINDING_PROTOCOL", *c.OptionSettings[0].Name)
+
+	c = toOptionConfiguration(databasev1.DatabaseOption{Name: "SQLSERVER_BACKUP_RESTORE"}, []string{"sg-1"})
+	assert.Nil(t, c.Port)
+	assert.Nil(t, c.VpcSecurityGroupMemberships)
+	assert.Nil(t, c.OptionVersion)
+}
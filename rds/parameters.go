@@ -0,0 +1,204 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+)
+
+// maxParametersPerCall is the number of parameters ModifyDBParameterGroup and ResetDBParameterGroup accept at once
+const maxParametersPerCall = 20
+
+// ensureParameterGroup creates the parameter group of the database when it has parameters and brings the group in
+// line with spec.parameters, parameters removed from the spec are reset to the default of the engine. It returns the
+// name of the group, or an empty name when the database uses the default group, and whether parameters were changed
+func (r *RDS) ensureParameterGroup(ctx context.Context, svc RDSAPI, db *databasev1.Database) (string, bool, error) {
+	name := parametergroupname(db)
+	_, err := svc.DescribeDBParameterGroups(ctx, &rds.DescribeDBParameterGroupsInput{DBParameterGroupName: aws.String(name)})
+	if isParameterGroupNotFound(err) {
+		if len(db.Spec.Parameters) == 0 {
+			return "", false, nil
+		}
+		family, err := parameterGroupFamily(ctx, svc, db)
+		if err != nil {
+			return "", false, err
+		}
+		log.Printf("Creating parameter group %v for %v\n", name, family)
+		_, err = svc.CreateDBParameterGroup(ctx, &rds.CreateDBParameterGroupInput{
+			DBParameterGroupName:   aws.String(name),
+			DBParameterGroupFamily: aws.String(family),
+			Description:            aws.String(fmt.Sprintf("Parameters of database %v/%v", db.Namespace, db.Name)),
+			Tags:                   []rdstypes.Tag{{Key: aws.String("Warning"), Value: aws.String("Managed by k8s-rds.")}},
+		})
+		if err != nil {
+			return "", false, errors.Wrap(err, "CreateDBParameterGroup")
+		}
+	} else if err != nil {
+		return "", false, errors.Wrap(err, "DescribeDBParameterGroups")
+	}
+
+	var current []rdstypes.Parameter
+	pages := rds.NewDescribeDBParametersPaginator(svc, &rds.DescribeDBParametersInput{DBParameterGroupName: aws.String(name)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return "", false, errors.Wrap(err, "DescribeDBParameters")
+		}
+		current = append(current, page.Parameters...)
+	}
+
+	modify, reset, err := parameterChanges(db.Spec.Parameters, current)
+	if err != nil {
+		return "", false, err
+	}
+	for _, batch := range batches(modify) {
+		log.Printf("Modifying %d parameters of parameter group %v\n", len(batch), name)
+		_, err = svc.ModifyDBParameterGroup(ctx, &rds.ModifyDBParameterGroupInput{DBParameterGroupName: aws.String(name), Parameters: batch})
+		if err != nil {
+			return "", false, errors.Wrap(err, "ModifyDBParameterGroup")
+		}
+	}
+	for _, batch := range batches(reset) {
+		log.Printf("Resetting %d parameters of parameter group %v\n", len(batch), name)
+		_, err = svc.ResetDBParameterGroup(ctx, &rds.ResetDBParameterGroupInput{DBParameterGroupName: aws.String(name), Parameters: batch})
+		if err != nil {
+			return "", false, errors.Wrap(err, "ResetDBParameterGroup")
+		}
+	}
+	return name, len(modify) > 0 || len(reset) > 0, nil
+}
+
+// parameterChanges compares the wanted parameters with the parameters of the group. Dynamic parameters are applied
+// immediately, static ones on the next reboot. Parameters set on the group that aren't wanted anymore are reset
+func parameterChanges(wanted map[string]string, current []rdstypes.Parameter) (modify []rdstypes.Parameter, reset []rdstypes.Parameter, err error) {
+	byName := map[string]rdstypes.Parameter{}
+	for _, p := range current {
+		byName[aws.ToString(p.ParameterName)] = p
+	}
+
+	names := make([]string, 0, len(wanted))
+	for name := range wanted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, ok := byName[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown parameter %v", name)
+		}
+		if !p.IsModifiable {
+			return nil, nil, fmt.Errorf("parameter %v can't be modified", name)
+		}
+		if aws.ToString(p.ParameterValue) == wanted[name] {
+			continue
+		}
+		modify = append(modify, rdstypes.Parameter{
+			ParameterName:  aws.String(name),
+			ParameterValue: aws.String(wanted[name]),
+			ApplyMethod:    applyMethod(p),
+		})
+	}
+
+	for _, p := range current {
+		name := aws.ToString(p.ParameterName)
+		if _, ok := wanted[name]; ok || aws.ToString(p.Source) != "user" {
+			continue
+		}
+		reset = append(reset, rdstypes.Parameter{ParameterName: aws.String(name), ApplyMethod: applyMethod(p)})
+	}
+	return modify, reset, nil
+}
+
+func applyMethod(p rdstypes.Parameter) rdstypes.ApplyMethod {
+	if aws.ToString(p.ApplyType) == "dynamic" {
+		return rdstypes.ApplyMethodImmediate
+	}
+	return rdstypes.ApplyMethodPendingReboot
+}
+
+// batches splits the parameters in batches of maxParametersPerCall
+func batches(parameters []rdstypes.Parameter) [][]rdstypes.Parameter {
+	var b [][]rdstypes.Parameter
+	for len(parameters) > maxParametersPerCall {
+		b = append(b, parameters[:maxParametersPerCall])
+		parameters = parameters[maxParametersPerCall:]
+	}
+	if len(parameters) > 0 {
+		b = append(b, parameters)
+	}
+	return b
+}
+
+// parameterGroupFamily looks up the parameter group family of the engine version of the database, ex postgres13
+func parameterGroupFamily(ctx context.Context, svc RDSAPI, db *databasev1.Database) (string, error) {
+	version, err := engineVersion(ctx, svc, db)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(version.DBParameterGroupFamily), nil
+}
+
+// engineVersion describes the engine version of the database, the default version if spec.version isn't set
+func engineVersion(ctx context.Context, svc RDSAPI, db *databasev1.Database) (*rdstypes.DBEngineVersion, error) {
+	input := &rds.DescribeDBEngineVersionsInput{Engine: aws.String(db.Spec.Engine)}
+	if db.Spec.Version != "" {
+		input.EngineVersion = aws.String(db.Spec.Version)
+	} else {
+		input.DefaultOnly = true
+	}
+	res, err := svc.DescribeDBEngineVersions(ctx, input)
+	if err != nil {
+		return nil, errors.Wrap(err, "DescribeDBEngineVersions")
+	}
+	if len(res.DBEngineVersions) == 0 {
+		return nil, fmt.Errorf("unable to find engine %v version %v", db.Spec.Engine, db.Spec.Version)
+	}
+	return &res.DBEngineVersions[0], nil
+}
+
+// deleteParameterGroup deletes the parameter group of the database, the instance has to be deleted first
+func deleteParameterGroup(ctx context.Context, svc RDSAPI, db *databasev1.Database) error {
+	name := parametergroupname(db)
+	_, err := svc.DeleteDBParameterGroup(ctx, &rds.DeleteDBParameterGroupInput{DBParameterGroupName: aws.String(name)})
+	if isParameterGroupNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to delete parameter group %v", name))
+	}
+	log.Println("Deleted parameter group: ", name)
+	return nil
+}
+
+// parameterApplyStatus returns the apply status of the parameter group of the instance, ex pending-reboot
+func parameterApplyStatus(instance *rdstypes.DBInstance) string {
+	if len(instance.DBParameterGroups) == 0 {
+		return ""
+	}
+	return aws.ToString(instance.DBParameterGroups[0].ParameterApplyStatus)
+}
+
+// attachedParameterGroup returns the name of the parameter group of the instance
+func attachedParameterGroup(instance *rdstypes.DBInstance) string {
+	if len(instance.DBParameterGroups) == 0 {
+		return ""
+	}
+	return aws.ToString(instance.DBParameterGroups[0].DBParameterGroupName)
+}
+
+func isParameterGroupNotFound(err error) bool {
+	var notFound *rdstypes.DBParameterGroupNotFoundFault
+	return errors.As(err, &notFound)
+}
+
+// parametergroupname is the name of the parameter group owned by the database
+func parametergroupname(db *databasev1.Database) string {
+	return dbidentifier(db)
+}
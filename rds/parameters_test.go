@@ -0,0 +1,54 @@
+package rds
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParameterChanges(t *testing.T) {
+	current := []rdstypes.Parameter{
+		{ParameterName: aws.String("work_mem"), ParameterValue: aws.String("4096"), ApplyType: aws.String("dynamic"), IsModifiable: true, Source: aws.String("user")},
+		{ParameterName: aws.String("max_connections"), ApplyType: aws.String("static"), IsModifiable: true, Source: aws.String("system")},
+		{ParameterName: aws.String("log_min_duration_statement"), ParameterValue: aws.String("500"), ApplyType: aws.String("dynamic"), IsModifiable: true, Source: aws.String("user")},
+		{ParameterName: aws.String("shared_buffers"), ApplyType: aws.String("static"), IsModifiable: true, Source: aws.String("engine-default")},
+		{ParameterName: aws.String("data_directory"), ApplyType: aws.String("static"), IsModifiable: false, Source: aws.String("system")},
+	}
+
+	modify, reset, err := parameterChanges(map[string]string{"work_mem": "65536", "max_connections": "200", "log_min_duration_statement": "500"}, current)
+	assert.NoError(t, err)
+	assert.Len(t, modify, 2)
+	assert.Equal(t, "max_connections", *modify[0].ParameterName)
+	assert.Equal(t, rdstypes.ApplyMethodPendingReboot, modify[0].ApplyMethod)
+	assert.Equal(t, "work_mem", *modify[1].ParameterName)
+	assert.Equal(t, "65536", *modify[1].ParameterValue)
+	assert.Equal(t, rdstypes.ApplyMethodImmediate, modify[1].ApplyMethod)
+	assert.Empty(t, reset)
+
+	// parameters removed from the spec are reset
+	modify, reset, err = parameterChanges(map[string]string{"work_mem": "4096"}, current)
+	assert.NoError(t, err)
+	assert.Empty(t, modify)
+	assert.Len(t, reset, 1)
+	assert.Equal(t, "log_min_duration_statement", *reset[0].ParameterName)
+
+	_, _, err = parameterChanges(map[string]string{"work_memory": "1"}, current)
+	assert.Error(t, err)
+	_, _, err = parameterChanges(map[string]string{"data_directory": "/tmp"}, current)
+	assert.Error(t, err)
+}
+
+func TestBatches(t *testing.T) {
+	assert.Empty(t, batches(nil))
+	var parameters []rdstypes.Parameter
+	for i := 0; i < 45; i++ {
+		parameters = append(parameters, rdstypes.Parameter{ParameterName: aws.String(fmt.Sprint(i))})
+	}
+	b := batches(parameters)
+	assert.Len(t, b, 3)
+	assert.Len(t, b[0], 20)
+	assert.Len(t, b[2], 5)
+}
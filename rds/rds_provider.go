@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,117 +14,276 @@ import (
 	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/pkg/errors"
-	"github.com/sorenmat/k8s-rds/crd"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/kube"
 	"github.com/sorenmat/k8s-rds/provider"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 type RDS struct {
-	EC2             *ec2.Client
+	EC2             EC2API
 	Config          aws.Config
 	Subnets         []string
 	SecurityGroups  []string
 	VpcId           string
-	ServiceProvider provider.ServiceProvider
+	// Tags are added to the instances and clusters next to the tags of their spec
+	Tags map[string]string
+	// ServiceProvider creates the ExternalName service pointing at the instance and reads the password secret
+	provider.ServiceProvider
+	// WaitTimeout is how long we wait on an instance before returning a provider.PendingError
+	WaitTimeout time.Duration
+	// PollInterval is how often the instance is described while waiting
+	PollInterval time.Duration
+	// Client is used for the RDS calls in the region of the operator
+	Client RDSAPI
+	// RegionClient returns a client for another region, it is used for read replicas in other regions
+	RegionClient func(region string) RDSAPI
+	// Public is set for publicly accessible databases, Subnets are public subnets then and the databases share
+	// another subnet group than the private ones
+	Public bool
 }
 
-func New(ctx context.Context, db *crd.Database, kc *kubernetes.Clientset) (*RDS, error) {
-	cfg, err := ec2config(ctx, kc)
-	if err != nil {
-		log.Fatal("unable to create a client for EC2 ", err)
-	}
+const (
+	defaultWaitTimeout  = time.Minute
+	defaultPollInterval = 10 * time.Second
+)
 
-	ec2client := ec2.NewFromConfig(*cfg)
+func New(ctx context.Context, db *databasev1.Database, kc kubernetes.Interface, opts Options) (*RDS, error) {
+	return newRDS(ctx, kc, opts, db.Spec.PubliclyAccessible)
+}
+
+// NewCluster returns a provider for the Aurora cluster c
+func NewCluster(ctx context.Context, c *databasev1.DatabaseCluster, kc kubernetes.Interface, opts Options) (*RDS, error) {
+	return newRDS(ctx, kc, opts, c.Spec.PubliclyAccessible)
+}
 
-	nodeInfo, err := describeNodeEC2Instance(ctx, kc, ec2client)
+// newRDS resolves the region, VPC, subnets and security groups, the options win over what is discovered from the
+// cluster nodes. public selects the public subnets
+func newRDS(ctx context.Context, kc kubernetes.Interface, opts Options, public bool) (*RDS, error) {
+	nodes := listNodes(ctx, kc)
+	cfg, err := awsConfig(ctx, nodes, opts)
 	if err != nil {
-		log.Println(err)
-		return nil, errors.Wrap(err, "unable AWS metadata")
+		return nil, err
 	}
-	vpcId := *nodeInfo.Reservations[0].Instances[0].VpcId
 
-	log.Println("trying to get subnets")
-	subnets, err := getSubnets(ctx, nodeInfo, ec2client, db.Spec.PubliclyAccessible)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get subnets from instance: %v", err)
+	ec2client := ec2.NewFromConfig(cfg)
 
+	vpcId, sgs := opts.VpcID, opts.SecurityGroups
+	if vpcId == "" || len(sgs) == 0 {
+		nodeVpc, nodeSgs, err := nodeNetwork(ctx, nodes, ec2client, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if vpcId == "" {
+			vpcId = nodeVpc
+		}
+		if len(sgs) == 0 {
+			sgs = nodeSgs
+		}
 	}
 
-	log.Println("trying to get security groups")
-	sgs, err := getSGS(ctx, kc, ec2client)
-	if err != nil {
-		return nil, fmt.Errorf("unable to get security groups from instance: %v", err)
-
+	subnets := opts.Subnets
+	if public {
+		subnets = opts.PublicSubnets
+	}
+	if len(subnets) == 0 {
+		log.Println("trying to get subnets")
+		subnets, err = getSubnets(ctx, vpcId, ec2client, public)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get subnets of VPC %v: %v", vpcId, err)
+		}
 	}
 
 	r := RDS{
-		EC2:            ec2client,
-		Config:         *cfg,
-		Subnets:        subnets,
-		SecurityGroups: sgs,
-		VpcId:          vpcId,
+		EC2:             ec2client,
+		Config:          cfg,
+		Subnets:         subnets,
+		SecurityGroups:  sgs,
+		VpcId:           vpcId,
+		Tags:            opts.Tags,
+		ServiceProvider: kube.New(kc, kube.ExternalName()),
+		WaitTimeout:     defaultWaitTimeout,
+		PollInterval:    defaultPollInterval,
+		Client:          rds.NewFromConfig(cfg),
+		RegionClient: func(region string) RDSAPI {
+			return rds.NewFromConfig(cfg, func(o *rds.Options) {
+				o.Region = region
+			})
+		},
+		Public: public,
 	}
 	return &r, nil
 }
 
 // CreateDatabase creates a database from the CRD database object, is also ensures that the correct
 // subnets are created for the database so we can access it
-func (r *RDS) CreateDatabase(ctx context.Context, db *crd.Database) (string, error) {
+func (r *RDS) CreateDatabase(ctx context.Context, db *databasev1.Database) (*provider.DatabaseInfo, error) {
+	svc := r.Client
 	// Ensure that the subnets for the DB is create or updated
 	log.Println("Trying to find the correct subnets")
-	subnetName, err := r.ensureSubnets(ctx, db)
+	subnetName, err := r.ensureSubnetGroup(ctx, svc, db)
+	if err != nil {
+		return nil, err
+	}
+	securityGroups, err := r.ensureSecurityGroups(ctx, db)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	log.Printf("getting secret: Name: %v Key: %v \n", db.Spec.Password.Name, db.Spec.Password.Key)
 	pw, err := r.GetSecret(ctx, db.Namespace, db.Spec.Password.Name, db.Spec.Password.Key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	input := convertSpecToInput(db, subnetName, r.SecurityGroups, pw)
 
 	// search for the instance
+	id := dbidentifier(db)
 	log.Printf("Trying to find db instance %v\n", db.Spec.DBName)
-	k := &rds.DescribeDBInstancesInput{DBInstanceIdentifier: input.DBInstanceIdentifier}
-
-	_, err = r.rdsclient().DescribeDBInstances(ctx, k)
-	if err != nil && err.Error() != new(rdstypes.DBInstanceNotFoundFault).Error() {
-		log.Printf("DB instance %v not found trying to create it\n", db.Spec.DBName)
-		// seems like we didn't find a database with this name, let's create on
-		_, err := r.rdsclient().CreateDBInstance(ctx, input)
+	_, err = describeInstance(ctx, svc, id)
+	if isInstanceNotFound(err) {
+		parameterGroup, _, err := r.ensureParameterGroup(ctx, svc, db)
+		if err != nil {
+			return nil, err
+		}
+		optionGroup, err := r.ensureOptionGroup(ctx, svc, db, securityGroups)
+		if err != nil {
+			return nil, err
+		}
+		if provider.RestoreSource(db) != "" {
+			err = r.restoreInstance(ctx, svc, db, subnetName, securityGroups, parameterGroup, optionGroup)
+		} else {
+			log.Printf("DB instance %v not found trying to create it\n", db.Spec.DBName)
+			input := convertSpecToInput(db, subnetName, securityGroups, pw, parameterGroup, optionGroup)
+			input.Tags = withDefaultTags(input.Tags, r.Tags)
+			_, err = svc.CreateDBInstance(ctx, input)
+			err = errors.Wrap(err, "CreateDBInstance")
+		}
 		if err != nil {
-			return "", errors.Wrap(err, "CreateDBInstance")
+			return nil, err
 		}
 	} else if err != nil {
-		return "", errors.Wrap(err, fmt.Sprintf("wasn't able to describe the db instance with id %v", input.DBInstanceIdentifier))
+		return nil, err
 	}
-	log.Printf("Waiting for db instance %v to become available\n", *input.DBInstanceIdentifier)
+	log.Printf("Waiting for db instance %v to become available\n", id)
 
-	time.Sleep(5 * time.Second)
+	instance, err := r.waitForInstance(ctx, svc, id)
+	if err != nil {
+		return nil, err
+	}
+	return toDatabaseInfo(instance), nil
+}
+
+func toDatabaseInfo(instance *rdstypes.DBInstance) *provider.DatabaseInfo {
+	return &provider.DatabaseInfo{
+		Hostname:      aws.ToString(instance.Endpoint.Address),
+		Port:          instance.Endpoint.Port,
+		ARN:           aws.ToString(instance.DBInstanceArn),
+		ResourceID:    aws.ToString(instance.DbiResourceId),
+		EngineVersion: aws.ToString(instance.EngineVersion),
+
+		ParameterApplyStatus: parameterApplyStatus(instance),
+	}
+}
+
+// instanceFailedStates are the states an instance won't get out of without someone intervening
+var instanceFailedStates = []string{
+	"failed",
+	"incompatible-network",
+	"incompatible-option-group",
+	"incompatible-parameters",
+	"incompatible-restore",
+	"inaccessible-encryption-credentials",
+	"restore-error",
+	"storage-full",
+}
+
+// waitForInstance polls the instance until it is available. If that takes longer than WaitTimeout a
+// provider.PendingError with the current state is returned, so the controller can record it and check back later
+func (r *RDS) waitForInstance(ctx context.Context, svc RDSAPI, id string) (*rdstypes.DBInstance, error) {
+	deadline := time.Now().Add(r.WaitTimeout)
+	state := ""
+	for {
+		instance, err := describeInstance(ctx, svc, id)
+		if err != nil {
+			return nil, err
+		}
+		if aws.ToString(instance.DBInstanceStatus) != state {
+			state = aws.ToString(instance.DBInstanceStatus)
+			log.Printf("db instance %v is %v\n", id, state)
+		}
+		// changed parameters are reported as applying while the instance is available
+		if state == "available" && instance.Endpoint != nil && instance.Endpoint.Address != nil && parameterApplyStatus(instance) != "applying" {
+			return instance, nil
+		}
+		for _, s := range instanceFailedStates {
+			if s == state {
+				return nil, fmt.Errorf("db instance %v is in state %v", id, state)
+			}
+		}
+		if !time.Now().Add(r.PollInterval).Before(deadline) {
+			return nil, &provider.PendingError{State: state, Message: fmt.Sprintf("waiting for db instance %v to become available", id)}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// waitForInstanceDeleted polls the instance until it is gone, returning a provider.PendingError after WaitTimeout
+func (r *RDS) waitForInstanceDeleted(ctx context.Context, svc RDSAPI, id string) error {
+	deadline := time.Now().Add(r.WaitTimeout)
+	for {
+		instance, err := describeInstance(ctx, svc, id)
+		if isInstanceNotFound(err) {
+			log.Printf("db instance %v is deleted\n", id)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !time.Now().Add(r.PollInterval).Before(deadline) {
+			return &provider.PendingError{State: aws.ToString(instance.DBInstanceStatus), Message: fmt.Sprintf("waiting for db instance %v to be deleted", id)}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
 
-	// Get the newly created database so we can get the endpoint
-	dbHostname, err := getEndpoint(ctx, input.DBInstanceIdentifier, r.rdsclient())
+// describeInstance returns the instance with the given identifier, the error wraps a
+// DBInstanceNotFoundFault if it doesn't exist
+func describeInstance(ctx context.Context, svc RDSAPI, id string) (*rdstypes.DBInstance, error) {
+	res, err := svc.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String(id)})
 	if err != nil {
-		return "", err
+		return nil, errors.Wrap(err, fmt.Sprintf("wasn't able to describe the db instance with id %v", id))
 	}
-	return dbHostname, nil
+	if len(res.DBInstances) == 0 {
+		return nil, errors.Wrap(&rdstypes.DBInstanceNotFoundFault{}, fmt.Sprintf("unable to find db instance %v", id))
+	}
+	return &res.DBInstances[0], nil
+}
+
+func isInstanceNotFound(err error) bool {
+	var notFound *rdstypes.DBInstanceNotFoundFault
+	return errors.As(err, &notFound)
 }
 
 // ensureSubnets is ensuring that we have created or updated the subnet according to the data from the CRD object
-func (r *RDS) ensureSubnets(ctx context.Context, db *crd.Database) (string, error) {
+func (r *RDS) ensureSubnets(ctx context.Context) (string, error) {
 	if len(r.Subnets) == 0 {
-		log.Println("Error: unable to continue due to lack of subnets, perhaps we couldn't lookup the subnets")
+		return "", fmt.Errorf("no subnets found in VPC %v for the shared subnet group, set them with --aws-subnets or --aws-public-subnets", r.VpcId)
 	}
 	subnetDescription := "RDS Subnet Group for VPC: " + r.VpcId
-	subnetName := "db-subnetgroup-" + r.VpcId
+	subnetName := sharedsubnetgroupname(r.VpcId, r.Public)
 
-	svc := r.rdsclient()
+	svc := r.Client
 
 	sf := &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String(subnetName)}
 	_, err := svc.DescribeDBSubnetGroups(ctx, sf)
@@ -133,7 +294,7 @@ func (r *RDS) ensureSubnets(ctx context.Context, db *crd.Database) (string, erro
 			DBSubnetGroupDescription: aws.String(subnetDescription),
 			DBSubnetGroupName:        aws.String(subnetName),
 			SubnetIds:                r.Subnets,
-			Tags:                     []rdstypes.Tag{{Key: aws.String("Warning"), Value: aws.String("Managed by k8s-rds.")}},
+			Tags:                     []rdstypes.Tag{managedTag},
 		}
 		_, err := svc.CreateDBSubnetGroup(ctx, subnet)
 		if err != nil {
@@ -145,24 +306,244 @@ func (r *RDS) ensureSubnets(ctx context.Context, db *crd.Database) (string, erro
 	return subnetName, nil
 }
 
-func getEndpoint(ctx context.Context, dbName *string, svc *rds.Client) (string, error) {
-	k := &rds.DescribeDBInstancesInput{DBInstanceIdentifier: dbName}
+// UpdateDatabase compares the spec with the running instance and issues a ModifyDBInstance
+// with the values that differ. The changes are applied immediately.
+func (r *RDS) UpdateDatabase(ctx context.Context, db *databasev1.Database) error {
+	svc := r.Client
+	id := dbidentifier(db)
+
+	instance, err := describeInstance(ctx, svc, id)
+	if err != nil {
+		return err
+	}
+
+	// the groups owned by the database only change with the spec, once a generation has been applied they are
+	// left alone and only the instance is compared with the spec
+	var securityGroups []string
+	var parameterGroup, optionGroup string
+	parametersChanged := false
+	if !reconciled(db) {
+		// the subnets of a group owned by the database follow the spec, the instance stays in the group it was created in
+		_, err = r.ensureSubnetGroup(ctx, svc, db)
+		if err != nil {
+			return err
+		}
+
+		securityGroups, err = r.ensureSecurityGroups(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		parameterGroup, parametersChanged, err = r.ensureParameterGroup(ctx, svc, db)
+		if err != nil {
+			return err
+		}
+
+		optionGroup, err = r.ensureOptionGroup(ctx, svc, db, securityGroups)
+		if err != nil {
+			return err
+		}
+	}
+
+	input := convertSpecToModifyInput(db, instance, securityGroups, parameterGroup, optionGroup)
+	if input != nil {
+		log.Printf("Modifying db instance %v\n", id)
+		_, err = svc.ModifyDBInstance(ctx, input)
+		if err != nil {
+			return errors.Wrap(err, "ModifyDBInstance")
+		}
+	} else {
+		log.Printf("No changes to apply on db instance %v\n", id)
+	}
+
+	// tags are only added or updated, tags set outside of k8s-rds are left alone
+	if instance.DBInstanceArn != nil {
+		tags := withDefaultTags(append(toTags(db.Annotations, db.Labels), gettags(db)...), r.Tags)
+		err = updateTags(ctx, svc, instance.DBInstanceArn, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	if input != nil {
+		return &provider.PendingError{State: "modifying", Message: fmt.Sprintf("waiting for the changes to db instance %v to be applied", id)}
+	}
+	if parametersChanged || parameterApplyStatus(instance) != db.Status.ParameterApplyStatus {
+		// going through CreateDatabase again records the apply status, ex pending-reboot, once it is known
+		return &provider.PendingError{State: "modifying", Message: fmt.Sprintf("waiting for the parameters of db instance %v to be applied", id)}
+	}
+	return nil
+}
+
+// reconciled reports whether the current generation of the spec has been applied successfully
+func reconciled(db *databasev1.Database) bool {
+	return db.Status.ObservedGeneration > 0 && db.Status.ObservedGeneration == db.Generation
+}
+
+// updateTags adds the tags that are missing on the resource or have another value, tags set outside of k8s-rds
+// are left alone
+func updateTags(ctx context.Context, svc RDSAPI, arn *string, tags []rdstypes.Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	current, err := svc.ListTagsForResource(ctx, &rds.ListTagsForResourceInput{ResourceName: arn})
+	if err != nil {
+		return errors.Wrap(err, "ListTagsForResource")
+	}
+	tags = changedTags(tags, current.TagList)
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err = svc.AddTagsToResource(ctx, &rds.AddTagsToResourceInput{ResourceName: arn, Tags: tags})
+	return errors.Wrap(err, "AddTagsToResource")
+}
+
+// changedTags returns the tags that are missing in current or have another value there
+func changedTags(tags, current []rdstypes.Tag) []rdstypes.Tag {
+	values := map[string]string{}
+	for _, t := range current {
+		values[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	var changed []rdstypes.Tag
+	for _, t := range tags {
+		if v, ok := values[aws.ToString(t.Key)]; !ok || v != aws.ToString(t.Value) {
+			changed = append(changed, t)
+		}
+	}
+	return changed
+}
+
+// RotatePassword sets the master password of the instance, RDS applies it in the background
+func (r *RDS) RotatePassword(ctx context.Context, db *databasev1.Database, password string) error {
+	id := dbidentifier(db)
+	log.Printf("Setting new master password on db instance %v\n", id)
+	_, err := r.Client.ModifyDBInstance(ctx, &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier: aws.String(id),
+		MasterUserPassword:   aws.String(password),
+		ApplyImmediately:     true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "ModifyDBInstance")
+	}
+	return &provider.PendingError{State: "resetting-master-credentials", Message: fmt.Sprintf("waiting for the new master password of db instance %v to be applied", id)}
+}
+
+// convertSpecToModifyInput returns the modifications needed to bring the instance in line with the spec,
+// values already pending on the instance are taken into account. It returns nil if nothing has changed.
+func convertSpecToModifyInput(v *databasev1.Database, instance *rdstypes.DBInstance, securityGroups []string, parameterGroup string, optionGroup string) *rds.ModifyDBInstanceInput {
+	pending := instance.PendingModifiedValues
+	if pending == nil {
+		pending = &rdstypes.PendingModifiedValues{}
+	}
+	changed := false
+	input := &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier: aws.String(dbidentifier(v)),
+		ApplyImmediately:     true,
+	}
+
+	if v.Spec.Class != "" && v.Spec.Class != pendingString(pending.DBInstanceClass, instance.DBInstanceClass) {
+		input.DBInstanceClass = aws.String(v.Spec.Class)
+		changed = true
+	}
+	if v.Spec.Size > 0 && int32(v.Spec.Size) != pendingInt32(pending.AllocatedStorage, instance.AllocatedStorage) {
+		input.AllocatedStorage = aws.Int32(int32(v.Spec.Size))
+		changed = true
+	}
+	if v.Spec.MaxAllocatedSize > 0 && int32(v.Spec.MaxAllocatedSize) != aws.ToInt32(instance.MaxAllocatedStorage) {
+		input.MaxAllocatedStorage = aws.Int32(int32(v.Spec.MaxAllocatedSize))
+		changed = true
+	}
+	if version := pendingString(pending.EngineVersion, instance.EngineVersion); v.Spec.Version != "" && !sameVersion(v.Spec.Version, version) {
+		input.EngineVersion = aws.String(v.Spec.Version)
+		// without the flag RDS refuses a major upgrade, that way a typo in the version can't upgrade the database
+		input.AllowMajorVersionUpgrade = v.Spec.AllowMajorUpgrade && majorVersion(v.Spec.Engine, v.Spec.Version) != majorVersion(v.Spec.Engine, version)
+		changed = true
+	}
+	if v.Spec.BackupRetentionPeriod > 0 && int32(v.Spec.BackupRetentionPeriod) != pendingInt32(pending.BackupRetentionPeriod, instance.BackupRetentionPeriod) {
+		input.BackupRetentionPeriod = aws.Int32(int32(v.Spec.BackupRetentionPeriod))
+		changed = true
+	}
+	multiAZ := instance.MultiAZ
+	if pending.MultiAZ != nil {
+		multiAZ = *pending.MultiAZ
+	}
+	if v.Spec.MultiAZ != multiAZ {
+		input.MultiAZ = aws.Bool(v.Spec.MultiAZ)
+		changed = true
+	}
+	if v.Spec.PubliclyAccessible != instance.PubliclyAccessible {
+		input.PubliclyAccessible = aws.Bool(v.Spec.PubliclyAccessible)
+		changed = true
+	}
+	if v.Spec.DeleteProtection != instance.DeletionProtection {
+		input.DeletionProtection = aws.Bool(v.Spec.DeleteProtection)
+		changed = true
+	}
+	if v.Spec.StorageType != "" && v.Spec.StorageType != pendingString(pending.StorageType, instance.StorageType) {
+		input.StorageType = aws.String(v.Spec.StorageType)
+		changed = true
+	}
+	if v.Spec.Iops > 0 && int32(v.Spec.Iops) != pendingInt32(pending.Iops, aws.ToInt32(instance.Iops)) {
+		input.Iops = aws.Int32(int32(v.Spec.Iops))
+		changed = true
+	}
+	if v.Spec.Port > 0 && instance.Endpoint != nil && v.Spec.Port != pendingInt32(pending.Port, instance.Endpoint.Port) {
+		input.DBPortNumber = aws.Int32(v.Spec.Port)
+		changed = true
+	}
+	if len(securityGroups) > 0 && !sameIDs(instanceSecurityGroups(instance), securityGroups) {
+		input.VpcSecurityGroupIds = securityGroups
+		changed = true
+	}
+	if parameterGroup != "" && parameterGroup != attachedParameterGroup(instance) {
+		input.DBParameterGroupName = aws.String(parameterGroup)
+		changed = true
+	}
+	if optionGroup != "" && optionGroup != attachedOptionGroup(instance) {
+		input.OptionGroupName = aws.String(optionGroup)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return input
+}
+
+func pendingString(pending *string, current *string) string {
+	if pending != nil {
+		return *pending
+	}
+	return aws.ToString(current)
+}
 
-	instance, err := svc.DescribeDBInstances(ctx, k)
-	if err != nil || len(instance.DBInstances) == 0 {
-		return "", fmt.Errorf("wasn't able to describe the db instance with id %v", dbName)
+func pendingInt32(pending *int32, current int32) int32 {
+	if pending != nil {
+		return *pending
 	}
-	rdsdb := instance.DBInstances[0]
+	return current
+}
+
+// sameVersion reports whether the running version matches the requested one, "9.6" matches "9.6.20"
+func sameVersion(wanted, running string) bool {
+	return wanted == running || strings.HasPrefix(running, wanted+".")
+}
 
-	dbHostname := *rdsdb.Endpoint.Address
-	return dbHostname, nil
+// majorVersion is the part of the version that changes on a major upgrade, ex 13 for postgres 13.4 and 5.7 for
+// mysql 5.7.38
+func majorVersion(engine, version string) string {
+	parts := strings.Split(version, ".")
+	if n, err := strconv.Atoi(parts[0]); strings.Contains(engine, "postgres") && err == nil && n >= 10 || len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[0] + "." + parts[1]
 }
 
-func dbSnapshotIdentifier(v *crd.Database, timestamp int64) string {
+func dbSnapshotIdentifier(v *databasev1.Database, timestamp int64) string {
 	return fmt.Sprintf("%s-%s-%d", v.Name, v.Namespace, timestamp)
 }
 
-func convertSpecToDeleteInput(db *crd.Database, timestamp int64) *rds.DeleteDBInstanceInput {
+func convertSpecToDeleteInput(db *databasev1.Database, timestamp int64) *rds.DeleteDBInstanceInput {
 	input := rds.DeleteDBInstanceInput{
 		DBInstanceIdentifier: aws.String(dbidentifier(db)),
 		SkipFinalSnapshot:    db.Spec.SkipFinalSnapshot,
@@ -173,47 +554,63 @@ func convertSpecToDeleteInput(db *crd.Database, timestamp int64) *rds.DeleteDBIn
 	return &input
 }
 
-func (r *RDS) DeleteDatabase(ctx context.Context, db *crd.Database) error {
+func (r *RDS) DeleteDatabase(ctx context.Context, db *databasev1.Database) error {
 	if db.Spec.DeleteProtection {
 		log.Printf("Trying to delete a %v in %v which is a deleted protected database", db.Name, db.Namespace)
 		return nil
 	}
 	// delete the database instance
-	svc := r.rdsclient()
+	svc := r.Client
 
 	input := convertSpecToDeleteInput(db, time.Now().UnixNano())
-	_, err := svc.DeleteDBInstance(ctx, input)
+	instance, err := describeInstance(ctx, svc, *input.DBInstanceIdentifier)
+	if isInstanceNotFound(err) {
+		log.Printf("db instance %v is already deleted\n", *input.DBInstanceIdentifier)
+	} else if err != nil {
+		return err
+	} else {
+		err = r.deleteReplicas(ctx, db, instance)
+		if err != nil {
+			return err
+		}
+		// the deletion might have been started in an earlier reconcile
+		if aws.ToString(instance.DBInstanceStatus) != "deleting" {
+			_, err = svc.DeleteDBInstance(ctx, input)
+			if err != nil && !isInstanceNotFound(err) {
+				err := errors.Wrap(err, fmt.Sprintf("unable to delete database %v", db.Spec.DBName))
+				log.Println(err)
+				return err
+			}
+			if !input.SkipFinalSnapshot && input.FinalDBSnapshotIdentifier != nil {
+				log.Printf("Will create DB final snapshot: %v\n", *input.FinalDBSnapshotIdentifier)
+			}
+		}
+
+		log.Printf("Waiting for db instance %v to be deleted\n", db.Spec.DBName)
+		err = r.waitForInstanceDeleted(ctx, svc, *input.DBInstanceIdentifier)
+		if err != nil {
+			return err
+		}
+	}
 
+	// delete the subnet group attached to the instance
+	err = r.releaseSubnetGroup(ctx, svc, db, attachedSubnetGroup(instance))
 	if err != nil {
-		err := errors.Wrap(err, fmt.Sprintf("unable to delete database %v", db.Spec.DBName))
 		log.Println(err)
 		return err
 	}
-
-	if !input.SkipFinalSnapshot && input.FinalDBSnapshotIdentifier != nil {
-		log.Printf("Will create DB final snapshot: %v\n", *input.FinalDBSnapshotIdentifier)
+	err = deleteParameterGroup(ctx, svc, db)
+	if err != nil {
+		return err
 	}
-
-	log.Printf("Waiting for db instance %v to be deleted\n", db.Spec.DBName)
-	time.Sleep(5 * time.Second)
-
-	// delete the subnet group attached to the instance
-	subnetName := db.Name + "-subnet-" + db.Namespace
-	_, err = svc.DeleteDBSubnetGroup(ctx, &rds.DeleteDBSubnetGroupInput{DBSubnetGroupName: aws.String(subnetName)})
+	err = deleteOptionGroup(ctx, svc, db)
 	if err != nil {
-		e := errors.Wrap(err, fmt.Sprintf("unable to delete subnet %v", subnetName))
-		log.Println(e)
-		return e
-	} else {
-		log.Println("Deleted DBSubnet group: ", subnetName)
+		return err
 	}
-	return nil
+	return r.deleteSecurityGroup(ctx, securitygroupname(db))
 }
 
-func (r *RDS) rdsclient() *rds.Client {
-	return rds.NewFromConfig(r.Config)
-}
-func dbidentifier(v *crd.Database) string {
+func dbidentifier(v *databasev1.Database) string {
 	return v.Name + "-" + v.Namespace
 }
 
@@ -247,12 +644,36 @@ func toTags(annotations, labels map[string]string) []rdstypes.Tag {
 	return tags
 }
 
-func gettags(db *crd.Database) []rdstypes.Tag {
+func gettags(db *databasev1.Database) []rdstypes.Tag {
+	return splitTags(db.Spec.Tags)
+}
+
+// withDefaultTags adds the default tags of the provider that aren't set already
+func withDefaultTags(tags []rdstypes.Tag, defaults map[string]string) []rdstypes.Tag {
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		set := false
+		for _, t := range tags {
+			set = set || aws.ToString(t.Key) == key
+		}
+		if !set {
+			tags = append(tags, rdstypes.Tag{Key: aws.String(key), Value: aws.String(defaults[key])})
+		}
+	}
+	return tags
+}
+
+// splitTags parses tags in the key=value,key1=value1 format
+func splitTags(spec string) []rdstypes.Tag {
 	var tags []rdstypes.Tag
-	if db.Spec.Tags == "" {
+	if spec == "" {
 		return tags
 	}
-	for _, v := range strings.Split(db.Spec.Tags, ",") {
+	for _, v := range strings.Split(spec, ",") {
 		kv := strings.Split(v, "=")
 
 		tags = append(tags, rdstypes.Tag{Key: aws.String(strings.TrimSpace(kv[0])), Value: aws.String(strings.TrimSpace(kv[1]))})
@@ -260,14 +681,13 @@ func gettags(db *crd.Database) []rdstypes.Tag {
 	return tags
 }
 
-func convertSpecToInput(v *crd.Database, subnetName string, securityGroups []string, password string) *rds.CreateDBInstanceInput {
+func convertSpecToInput(v *databasev1.Database, subnetName string, securityGroups []string, password string, parameterGroup string, optionGroup string) *rds.CreateDBInstanceInput {
 	tags := toTags(v.Annotations, v.Labels)
 	tags = append(tags, gettags(v)...)
 
 	input := &rds.CreateDBInstanceInput{
 		DBName:                aws.String(v.Spec.DBName),
 		AllocatedStorage:      aws.Int32(int32(v.Spec.Size)),
-		MaxAllocatedStorage:   aws.Int32(int32(v.Spec.MaxAllocatedSize)),
 		DBInstanceClass:       aws.String(v.Spec.Class),
 		DBInstanceIdentifier:  aws.String(dbidentifier(v)),
 		VpcSecurityGroupIds:   securityGroups,
@@ -285,76 +705,42 @@ func convertSpecToInput(v *crd.Database, subnetName string, securityGroups []str
 	if v.Spec.Version != "" {
 		input.EngineVersion = aws.String(v.Spec.Version)
 	}
+	if v.Spec.MaxAllocatedSize > 0 {
+		input.MaxAllocatedStorage = aws.Int32(int32(v.Spec.MaxAllocatedSize))
+	}
 	if v.Spec.StorageType != "" {
 		input.StorageType = aws.String(v.Spec.StorageType)
 	}
 	if v.Spec.Iops > 0 {
 		input.Iops = aws.Int32(int32(v.Spec.Iops))
 	}
-	return input
-}
-
-//DescribeInstancesResponse
-// describeNodeEC2Instance returns the AWS Metadata for the firt Node from the cluster
-func describeNodeEC2Instance(ctx context.Context, kubectl *kubernetes.Clientset, svc *ec2.Client) (*ec2.DescribeInstancesOutput, error) {
-	nodes, err := kubectl.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to get nodes")
-	}
-	name := ""
-
-	if len(nodes.Items) == 0 {
-		return nil, fmt.Errorf("unable to find any nodes in the cluster")
-	}
-
-	// take the first one, we assume that all nodes are created in the same VPC
-	name = getIDFromProvider(nodes.Items[0].Spec.ProviderID)
-
-	log.Printf("Taking subnets from node %v", name)
-
-	params := &ec2.DescribeInstancesInput{
-		Filters: []ec2types.Filter{
-			{
-				Name: aws.String("instance-id"),
-				Values: []string{
-					name,
-				},
-			},
-		},
+	if v.Spec.Port > 0 {
+		input.Port = aws.Int32(v.Spec.Port)
 	}
-	log.Println("trying to describe instance")
-	nodeInfo, err := svc.DescribeInstances(ctx, params)
-
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to describe AWS instance")
+	if parameterGroup != "" {
+		input.DBParameterGroupName = aws.String(parameterGroup)
 	}
-	if len(nodeInfo.Reservations) == 0 {
-		log.Println(err)
-		return nil, fmt.Errorf("unable to describe AWS instance")
+	if optionGroup != "" {
+		input.OptionGroupName = aws.String(optionGroup)
 	}
-
-	return nodeInfo, nil
+	return input
 }
 
-// getSubnets returns a list of subnets within the VPC from the Kubernetes Node.
-func getSubnets(ctx context.Context, nodeInfo *ec2.DescribeInstancesOutput, svc *ec2.Client, public bool) ([]string, error) {
+// getSubnets returns the private or public subnets of the VPC, public subnets map a public IP on launch
+func getSubnets(ctx context.Context, vpcID string, svc EC2API, public bool) ([]string, error) {
 	var result []string
-	vpcID := nodeInfo.Reservations[0].Instances[0].VpcId
-	for _, v := range nodeInfo.Reservations[0].Instances[0].SecurityGroups {
-		log.Println("Security groupid: ", *v.GroupId)
-	}
-	log.Printf("Found VPC %v will search for subnet in that VPC\n", *vpcID)
+	log.Printf("Searching for subnets in VPC %v\n", vpcID)
 
 	//DescribeSubnetsRequest
-	subnets, err := svc.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{*vpcID}}}})
+	subnets, err := svc.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}})
 	if err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("unable to describe subnet in VPC %v", *vpcID))
+		return nil, errors.Wrap(err, fmt.Sprintf("unable to describe subnet in VPC %v", vpcID))
 	}
 	for _, sn := range subnets.Subnets {
-		if *sn.MapPublicIpOnLaunch == public {
+		if aws.ToBool(sn.MapPublicIpOnLaunch) == public {
 			result = append(result, *sn.SubnetId)
 		} else {
-			log.Printf("Skipping subnet %v since it's public state was %v and we were looking for %v\n", *sn.SubnetId, sn.MapPublicIpOnLaunch, public)
+			log.Printf("Skipping subnet %v since it's public state was %v and we were looking for %v\n", *sn.SubnetId, aws.ToBool(sn.MapPublicIpOnLaunch), public)
 		}
 	}
 
@@ -370,78 +756,3 @@ func getIDFromProvider(x string) string {
 	name := x[pos:]
 	return name
 }
-func getSGS(ctx context.Context, kubectl *kubernetes.Clientset, svc *ec2.Client) ([]string, error) {
-
-	nodes, err := kubectl.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to get nodes")
-	}
-	name := ""
-
-	if len(nodes.Items) > 0 {
-		// take the first one, we assume that all nodes are created in the same VPC
-		name = getIDFromProvider(nodes.Items[0].Spec.ProviderID)
-	} else {
-		return nil, fmt.Errorf("unable to find any nodes in the cluster")
-	}
-	log.Printf("Taking security groups from node %v", name)
-
-	params := &ec2.DescribeInstancesInput{
-		Filters: []ec2types.Filter{
-			{
-				Name: aws.String("instance-id"),
-				Values: []string{
-					name,
-				},
-			},
-		},
-	}
-	log.Println("trying to describe instance")
-	res, err := svc.DescribeInstances(ctx, params)
-	if err != nil {
-		log.Println(err)
-		return nil, errors.Wrap(err, "unable to describe AWS instance")
-	}
-	log.Println("got instance response")
-
-	var result []string
-	if len(res.Reservations) >= 1 {
-		for _, v := range res.Reservations[0].Instances[0].SecurityGroups {
-			log.Println("Security groupid: ", *v.GroupId)
-			result = append(result, *v.GroupId)
-		}
-	}
-
-	log.Printf("Found the follwing security groups: ")
-	for _, v := range result {
-		log.Printf(v + " ")
-	}
-	return result, nil
-}
-
-func ec2config(ctx context.Context, kubectl *kubernetes.Clientset) (*aws.Config, error) {
-	nodes, err := kubectl.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to get nodes")
-	}
-	name := ""
-	region := ""
-
-	if len(nodes.Items) > 0 {
-		// take the first one, we assume that all nodes are created in the same VPC
-		name = getIDFromProvider(nodes.Items[0].Spec.ProviderID)
-		region = nodes.Items[0].Labels["failure-domain.beta.kubernetes.io/region"]
-	} else {
-		return nil, fmt.Errorf("unable to find any nodes in the cluster")
-	}
-	log.Printf("Found node with ID: %v in region %v", name, region)
-
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		panic("unable to load SDK config, " + err.Error())
-	}
-
-	// Set the AWS Region that the service clients should use
-	cfg.Region = region
-	return &cfg, nil
-}
@@ -3,7 +3,10 @@ package rds
 import (
 	"testing"
 
-	"github.com/sorenmat/k8s-rds/crd"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
 
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -11,8 +14,8 @@ import (
 )
 
 func TestConvertSpecToInput(t *testing.T) {
-	db := &crd.Database{
-		Spec: crd.DatabaseSpec{
+	db := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
 			DBName:             "mydb",
 			Engine:             "postgres",
 			Username:           "myuser",
@@ -25,10 +28,10 @@ func TestConvertSpecToInput(t *testing.T) {
 			StorageType:        "bad",
 			Version:            "9.6",
 			Iops:               1000,
-			Password:           v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"},
+			Password:           databasev1.PasswordSecret{SecretKeySelector: v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "password"}, Key: "mypassword"}},
 		},
 	}
-	i := convertSpecToInput(db, "mysubnet", []string{"sg-1234", "sg-4321"}, "mypassword")
+	i := convertSpecToInput(db, "mysubnet", []string{"sg-1234", "sg-4321"}, "mypassword", "", "")
 	assert.Equal(t, "mydb", *i.DBName)
 	assert.Equal(t, "postgres", *i.Engine)
 	assert.Equal(t, "mypassword", *i.MasterUserPassword)
@@ -43,6 +46,14 @@ func TestConvertSpecToInput(t *testing.T) {
 	assert.Equal(t, "bad", *i.StorageType)
 	assert.Equal(t, int32(1000), *i.Iops)
 	assert.Equal(t, "9.6", *i.EngineVersion)
+	assert.Nil(t, i.DBParameterGroupName)
+
+	i = convertSpecToInput(db, "mysubnet", []string{"sg-1234", "sg-4321"}, "mypassword", "mydb-default", "")
+	assert.Equal(t, "mydb-default", *i.DBParameterGroupName)
+	assert.Nil(t, i.OptionGroupName)
+
+	i = convertSpecToInput(db, "mysubnet", []string{"sg-1234", "sg-4321"}, "mypassword", "", "mydb-default")
+	assert.Equal(t, "mydb-default", *i.OptionGroupName)
 }
 
 func TestGetIDFromProvider(t *testing.T) {
@@ -53,6 +64,21 @@ func TestGetIDFromProvider(t *testing.T) {
 // 270 characters length
 const testRandString = "banjmdvgeezuadqvehvqaxxmzwykirejkwvktkxmvjdevcfhqootqyfdfvqatjiebglktdswnvzxcpnstvrurpfjfuxhsjvgogrnhazjizakttdncmjnbofvwcsccigfcyxzlunfndcjteuqmjpslqvefvobfnejjxtwbyrkcvsvqokkrskrryzbhhayegyuwhugyorkltmsipvznxkonqzzwihjdejqgzfjivjdqmieidkowryfjnnyrxszsyhnpfeepxyoliskexxpjtxn"
 
+func TestChangedTags(t *testing.T) {
+	current := []rdstypes.Tag{
+		{Key: aws.String("team"), Value: aws.String("a")},
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("owner"), Value: aws.String("ops")},
+	}
+	tags := []rdstypes.Tag{
+		{Key: aws.String("team"), Value: aws.String("b")},
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("app"), Value: aws.String("orders")},
+	}
+	assert.Equal(t, []rdstypes.Tag{tags[0], tags[2]}, changedTags(tags, current))
+	assert.Nil(t, changedTags(current, current))
+}
+
 func TestToTags(t *testing.T) {
 	tests := []struct {
 		Annotations map[string]string
@@ -131,8 +157,8 @@ func TestToTags(t *testing.T) {
 }
 
 func TestTags(t *testing.T) {
-	db := &crd.Database{
-		Spec: crd.DatabaseSpec{
+	db := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
 			Tags: "key=value,key1=value1",
 		},
 	}
@@ -146,8 +172,8 @@ func TestTags(t *testing.T) {
 
 }
 func TestTagsWithSpaces(t *testing.T) {
-	db := &crd.Database{
-		Spec: crd.DatabaseSpec{
+	db := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
 			Tags: "key= value,   key1=value1",
 		},
 	}
@@ -161,10 +187,19 @@ func TestTagsWithSpaces(t *testing.T) {
 
 }
 
+func TestWithDefaultTags(t *testing.T) {
+	tags := withDefaultTags(splitTags("team=b"), map[string]string{"team": "a", "cost-center": "42"})
+	assert.Equal(t, 2, len(tags))
+	assert.Equal(t, "team", *tags[0].Key)
+	assert.Equal(t, "b", *tags[0].Value)
+	assert.Equal(t, "cost-center", *tags[1].Key)
+	assert.Equal(t, "42", *tags[1].Value)
+}
+
 func TestConvertSpecToDeleteInput_enabled(t *testing.T) {
 	timestamp := int64(10202020202)
-	input := convertSpecToDeleteInput(&crd.Database{
-		Spec: crd.DatabaseSpec{
+	input := convertSpecToDeleteInput(&databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
 			SkipFinalSnapshot: true,
 		},
 		ObjectMeta: meta_v1.ObjectMeta{
@@ -178,8 +213,8 @@ func TestConvertSpecToDeleteInput_enabled(t *testing.T) {
 
 func TestConvertSpecToDeleteInput_disabled(t *testing.T) {
 	timestamp := int64(10202020202)
-	input := convertSpecToDeleteInput(&crd.Database{
-		Spec: crd.DatabaseSpec{
+	input := convertSpecToDeleteInput(&databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
 			SkipFinalSnapshot: false,
 		},
 		ObjectMeta: meta_v1.ObjectMeta{
@@ -191,3 +226,143 @@ func TestConvertSpecToDeleteInput_disabled(t *testing.T) {
 	assert.Equal(t, "mydb-myns-10202020202", *input.FinalDBSnapshotIdentifier)
 	assert.Equal(t, false, input.SkipFinalSnapshot)
 }
+
+func TestConvertSpecToModifyInput(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"},
+		Spec: databasev1.DatabaseSpec{
+			Class:                 "db.t3.large",
+			Size:                  100,
+			Version:               "12",
+			BackupRetentionPeriod: 7,
+			MultiAZ:               true,
+		},
+	}
+	instance := &rdstypes.DBInstance{
+		DBInstanceClass:       aws.String("db.t3.micro"),
+		AllocatedStorage:      100,
+		EngineVersion:         aws.String("12.8"),
+		BackupRetentionPeriod: 7,
+		MultiAZ:               true,
+	}
+	i := convertSpecToModifyInput(db, instance, nil, "", "")
+	assert.NotNil(t, i)
+	assert.Equal(t, "mydb-myns", *i.DBInstanceIdentifier)
+	assert.Equal(t, "db.t3.large", *i.DBInstanceClass)
+	assert.True(t, i.ApplyImmediately)
+	assert.Nil(t, i.AllocatedStorage)
+	assert.Nil(t, i.EngineVersion)
+	assert.Nil(t, i.BackupRetentionPeriod)
+	assert.Nil(t, i.MultiAZ)
+}
+
+func TestConvertSpecToModifyInputNoChanges(t *testing.T) {
+	db := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{
+			Class:   "db.t3.large",
+			Size:    200,
+			Version: "12",
+		},
+	}
+	instance := &rdstypes.DBInstance{
+		DBInstanceClass:  aws.String("db.t3.micro"),
+		AllocatedStorage: 100,
+		EngineVersion:    aws.String("12.8"),
+		PendingModifiedValues: &rdstypes.PendingModifiedValues{
+			DBInstanceClass:  aws.String("db.t3.large"),
+			AllocatedStorage: aws.Int32(200),
+		},
+	}
+	assert.Nil(t, convertSpecToModifyInput(db, instance, nil, "", ""))
+}
+
+func TestConvertSpecToModifyInputPort(t *testing.T) {
+	db := &databasev1.Database{Spec: databasev1.DatabaseSpec{Port: 3307}}
+	instance := &rdstypes.DBInstance{Endpoint: &rdstypes.Endpoint{Port: 3306}}
+	input := convertSpecToModifyInput(db, instance, nil, "", "")
+	assert.NotNil(t, input)
+	assert.Equal(t, int32(3307), aws.ToInt32(input.DBPortNumber))
+
+	instance.PendingModifiedValues = &rdstypes.PendingModifiedValues{Port: aws.Int32(3307)}
+	assert.Nil(t, convertSpecToModifyInput(db, instance, nil, "", ""))
+}
+
+func TestConvertSpecToModifyInputParameterGroup(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"}}
+	instance := &rdstypes.DBInstance{
+		DBParameterGroups: []rdstypes.DBParameterGroupStatus{{DBParameterGroupName: aws.String("default.postgres13"), ParameterApplyStatus: aws.String("in-sync")}},
+	}
+	input := convertSpecToModifyInput(db, instance, nil, "mydb-myns", "")
+	assert.NotNil(t, input)
+	assert.Equal(t, "mydb-myns", aws.ToString(input.DBParameterGroupName))
+
+	instance.DBParameterGroups[0].DBParameterGroupName = aws.String("mydb-myns")
+	assert.Nil(t, convertSpecToModifyInput(db, instance, nil, "mydb-myns", ""))
+	// without parameters the attached group is left alone
+	assert.Nil(t, convertSpecToModifyInput(db, instance, nil, "", ""))
+}
+
+func TestConvertSpecToModifyInputOptionGroup(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"}}
+	instance := &rdstypes.DBInstance{
+		OptionGroupMemberships: []rdstypes.OptionGroupMembership{{OptionGroupName: aws.String("default:mysql-8-0"), Status: aws.String("in-sync")}},
+	}
+	input := convertSpecToModifyInput(db, instance, nil, "", "mydb-myns")
+	assert.NotNil(t, input)
+	assert.Equal(t, "mydb-myns", aws.ToString(input.OptionGroupName))
+	assert.Nil(t, input.DBParameterGroupName)
+
+	instance.OptionGroupMemberships[0].OptionGroupName = aws.String("mydb-myns")
+	assert.Nil(t, convertSpecToModifyInput(db, instance, nil, "", "mydb-myns"))
+}
+
+func TestConvertSpecToModifyInputUnsetValues(t *testing.T) {
+	db := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{Engine: "postgres", Version: "13.7"},
+	}
+	instance := &rdstypes.DBInstance{
+		EngineVersion:         aws.String("13.4"),
+		BackupRetentionPeriod: 7,
+		MaxAllocatedStorage:   aws.Int32(200),
+	}
+	i := convertSpecToModifyInput(db, instance, nil, "", "")
+	assert.Equal(t, "13.7", aws.ToString(i.EngineVersion))
+	assert.False(t, i.AllowMajorVersionUpgrade)
+	assert.Nil(t, i.BackupRetentionPeriod)
+	assert.Nil(t, i.MaxAllocatedStorage)
+}
+
+func TestConvertSpecToModifyInputMajorVersion(t *testing.T) {
+	db := &databasev1.Database{
+		Spec: databasev1.DatabaseSpec{Engine: "postgres", Version: "14"},
+	}
+	instance := &rdstypes.DBInstance{EngineVersion: aws.String("13.4")}
+	i := convertSpecToModifyInput(db, instance, nil, "", "")
+	assert.Equal(t, "14", aws.ToString(i.EngineVersion))
+	assert.False(t, i.AllowMajorVersionUpgrade)
+
+	db.Spec.AllowMajorUpgrade = true
+	i = convertSpecToModifyInput(db, instance, nil, "", "")
+	assert.True(t, i.AllowMajorVersionUpgrade)
+}
+
+func TestMajorVersion(t *testing.T) {
+	assert.Equal(t, "13", majorVersion("postgres", "13.4"))
+	assert.Equal(t, "9.6", majorVersion("postgres", "9.6.20"))
+	assert.Equal(t, "5.7", majorVersion("mysql", "5.7.38"))
+	assert.Equal(t, "10.6", majorVersion("mariadb", "10.6.7"))
+	assert.Equal(t, "14", majorVersion("aurora-postgresql", "14"))
+}
+
+func TestSameVersion(t *testing.T) {
+	assert.True(t, sameVersion("9.6", "9.6.20"))
+	assert.True(t, sameVersion("9.6.20", "9.6.20"))
+	assert.False(t, sameVersion("9.6", "9.5.20"))
+	assert.False(t, sameVersion("1", "13.4"))
+}
+
+func TestIsInstanceNotFound(t *testing.T) {
+	assert.True(t, isInstanceNotFound(errors.Wrap(&rdstypes.DBInstanceNotFoundFault{}, "describe")))
+	assert.False(t, isInstanceNotFound(errors.New("throttled")))
+	assert.False(t, isInstanceNotFound(nil))
+}
@@ -0,0 +1,190 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+)
+
+// EnsureReadReplicas creates the missing read replicas with CreateDBInstanceReadReplica and deletes the replicas
+// above spec.readReplicas.count. The replicas of the database are found through the source instance
+func (r *RDS) EnsureReadReplicas(ctx context.Context, db *databasev1.Database) ([]provider.ReplicaInfo, error) {
+	svc := r.Client
+	primary, err := describeInstance(ctx, svc, dbidentifier(db))
+	if err != nil {
+		return nil, err
+	}
+
+	count := provider.ReplicaCount(db)
+	replicas := r.regionclient(replicaRegion(db))
+	var infos []provider.ReplicaInfo
+	for i := 0; i < count; i++ {
+		id := replicaidentifier(db, i)
+		instance, err := describeInstance(ctx, replicas, id)
+		if isInstanceNotFound(err) {
+			log.Printf("Creating read replica %v of db instance %v\n", id, dbidentifier(db))
+			input := convertSpecToReplicaInput(db, primary, i, r.Config.Region, instanceSecurityGroups(primary))
+			input.Tags = withDefaultTags(input.Tags, r.Tags)
+			_, err = replicas.CreateDBInstanceReadReplica(ctx, input)
+			if err != nil {
+				return nil, errors.Wrap(err, "CreateDBInstanceReadReplica")
+			}
+			infos = append(infos, provider.ReplicaInfo{Name: provider.ReplicaName(db, i), State: "creating"})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, toReplicaInfo(db, i, instance))
+	}
+
+	for _, ref := range primary.ReadReplicaDBInstanceIdentifiers {
+		id, region := parseReplicaReference(ref)
+		if index, ok := replicaIndex(db, id); !ok || index < count {
+			continue
+		}
+		err = deleteReplica(ctx, r.regionclient(region), id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+func toReplicaInfo(db *databasev1.Database, i int, instance *rdstypes.DBInstance) provider.ReplicaInfo {
+	info := provider.ReplicaInfo{
+		Name:  provider.ReplicaName(db, i),
+		State: aws.ToString(instance.DBInstanceStatus),
+	}
+	if instance.Endpoint != nil {
+		info.DatabaseInfo = *toDatabaseInfo(instance)
+	}
+	return info
+}
+
+// deleteReplicas deletes all read replicas created for the database, it doesn't wait for them to be gone
+func (r *RDS) deleteReplicas(ctx context.Context, db *databasev1.Database, primary *rdstypes.DBInstance) error {
+	for _, ref := range primary.ReadReplicaDBInstanceIdentifiers {
+		id, region := parseReplicaReference(ref)
+		if _, ok := replicaIndex(db, id); !ok {
+			continue
+		}
+		err := deleteReplica(ctx, r.regionclient(region), id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteReplica(ctx context.Context, svc RDSAPI, id string) error {
+	instance, err := describeInstance(ctx, svc, id)
+	if isInstanceNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if aws.ToString(instance.DBInstanceStatus) == "deleting" {
+		return nil
+	}
+	log.Printf("Deleting read replica %v\n", id)
+	_, err = svc.DeleteDBInstance(ctx, &rds.DeleteDBInstanceInput{DBInstanceIdentifier: aws.String(id), SkipFinalSnapshot: true})
+	if err != nil && !isInstanceNotFound(err) {
+		return errors.Wrap(err, fmt.Sprintf("unable to delete read replica %v", id))
+	}
+	return nil
+}
+
+// convertSpecToReplicaInput returns the input to create replica i of the database. A replica in another region is
+// created from the ARN of the source instance and ends up in the default VPC of that region
+func convertSpecToReplicaInput(db *databasev1.Database, primary *rdstypes.DBInstance, i int, sourceRegion string, securityGroups []string) *rds.CreateDBInstanceReadReplicaInput {
+	tags := toTags(db.Annotations, db.Labels)
+	tags = append(tags, gettags(db)...)
+
+	input := &rds.CreateDBInstanceReadReplicaInput{
+		DBInstanceIdentifier:       aws.String(replicaidentifier(db, i)),
+		SourceDBInstanceIdentifier: aws.String(dbidentifier(db)),
+		PubliclyAccessible:         aws.Bool(db.Spec.PubliclyAccessible),
+		Tags:                       tags,
+	}
+	class := db.Spec.ReadReplicas.Class
+	if class == "" {
+		class = db.Spec.Class
+	}
+	if class != "" {
+		input.DBInstanceClass = aws.String(class)
+	}
+	if db.Spec.ReadReplicas.AvailabilityZone != "" {
+		input.AvailabilityZone = aws.String(db.Spec.ReadReplicas.AvailabilityZone)
+	}
+	if region := db.Spec.ReadReplicas.Region; region != "" && region != sourceRegion {
+		input.SourceDBInstanceIdentifier = primary.DBInstanceArn
+		input.SourceRegion = aws.String(sourceRegion)
+	} else {
+		// parameter and option groups are regional, a replica in another region gets the default groups
+		input.VpcSecurityGroupIds = securityGroups
+		if len(db.Spec.Parameters) > 0 {
+			input.DBParameterGroupName = aws.String(parametergroupname(db))
+		}
+		if len(db.Spec.Options) > 0 {
+			input.OptionGroupName = aws.String(optiongroupname(db))
+		}
+	}
+	return input
+}
+
+// regionclient returns a client for region, the region of the operator if it's empty
+func (r *RDS) regionclient(region string) RDSAPI {
+	if region == "" || region == r.Config.Region {
+		return r.Client
+	}
+	return r.RegionClient(region)
+}
+
+func replicaRegion(db *databasev1.Database) string {
+	if db.Spec.ReadReplicas == nil {
+		return ""
+	}
+	return db.Spec.ReadReplicas.Region
+}
+
+// replicaidentifier is the identifier of read replica i of the database
+func replicaidentifier(db *databasev1.Database, i int) string {
+	return fmt.Sprintf("%s-ro-%d", dbidentifier(db), i)
+}
+
+// replicaIndex returns the index of a replica created by the operator, replicas created outside of k8s-rds are ignored
+func replicaIndex(db *databasev1.Database, id string) (int, bool) {
+	prefix := dbidentifier(db) + "-ro-"
+	if !strings.HasPrefix(id, prefix) {
+		return 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(id, prefix))
+	if err != nil || i < 0 {
+		return 0, false
+	}
+	return i, true
+}
+
+// parseReplicaReference splits the replica references of a source instance, replicas in the same region are listed
+// by identifier and replicas in other regions by ARN (arn:aws:rds:<region>:<account>:db:<identifier>)
+func parseReplicaReference(ref string) (id string, region string) {
+	if !strings.HasPrefix(ref, "arn:") {
+		return ref, ""
+	}
+	parts := strings.Split(ref, ":")
+	if len(parts) < 7 {
+		return ref, ""
+	}
+	return parts[len(parts)-1], parts[3]
+}
@@ -0,0 +1,62 @@
+package rds
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertSpecToReplicaInput(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"},
+		Spec: databasev1.DatabaseSpec{
+			Class:        "db.t3.medium",
+			ReadReplicas: &databasev1.ReadReplicas{Count: 2, AvailabilityZone: "eu-west-1b"},
+		},
+	}
+	primary := &rdstypes.DBInstance{DBInstanceArn: aws.String("arn:aws:rds:eu-west-1:123456789012:db:mydb-myns")}
+
+	i := convertSpecToReplicaInput(db, primary, 1, "eu-west-1", []string{"sg-1"})
+	assert.Equal(t, "mydb-myns-ro-1", *i.DBInstanceIdentifier)
+	assert.Equal(t, "mydb-myns", *i.SourceDBInstanceIdentifier)
+	assert.Equal(t, "db.t3.medium", *i.DBInstanceClass)
+	assert.Equal(t, "eu-west-1b", *i.AvailabilityZone)
+	assert.Equal(t, []string{"sg-1"}, i.VpcSecurityGroupIds)
+	assert.Nil(t, i.SourceRegion)
+	assert.Nil(t, i.OptionGroupName)
+
+	db.Spec.Options = []databasev1.DatabaseOption{{Name: "MEMCACHED"}}
+	i = convertSpecToReplicaInput(db, primary, 1, "eu-west-1", []string{"sg-1"})
+	assert.Equal(t, "mydb-myns", *i.OptionGroupName)
+
+	db.Spec.ReadReplicas = &databasev1.ReadReplicas{Count: 1, Class: "db.t3.small", Region: "us-east-1"}
+	i = convertSpecToReplicaInput(db, primary, 0, "eu-west-1", []string{"sg-1"})
+	assert.Equal(t, "db.t3.small", *i.DBInstanceClass)
+	assert.Equal(t, *primary.DBInstanceArn, *i.SourceDBInstanceIdentifier)
+	assert.Equal(t, "eu-west-1", *i.SourceRegion)
+	assert.Nil(t, i.VpcSecurityGroupIds)
+	assert.Nil(t, i.OptionGroupName)
+}
+
+func TestReplicaReferences(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"}}
+
+	id, region := parseReplicaReference("mydb-myns-ro-0")
+	assert.Equal(t, "mydb-myns-ro-0", id)
+	assert.Equal(t, "", region)
+	id, region = parseReplicaReference("arn:aws:rds:us-east-1:123456789012:db:mydb-myns-ro-3")
+	assert.Equal(t, "mydb-myns-ro-3", id)
+	assert.Equal(t, "us-east-1", region)
+
+	index, ok := replicaIndex(db, "mydb-myns-ro-3")
+	assert.True(t, ok)
+	assert.Equal(t, 3, index)
+	_, ok = replicaIndex(db, "mydb-myns-reporting")
+	assert.False(t, ok)
+	_, ok = replicaIndex(db, "other-replica")
+	assert.False(t, ok)
+}
@@ -0,0 +1,115 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// restoreInstance creates the instance of the database from spec.restoreFrom, settings that can't be given on
+// restore like the storage size and backup retention are applied by UpdateDatabase once the instance is available
+func (r *RDS) restoreInstance(ctx context.Context, svc RDSAPI, db *databasev1.Database, subnetName string, securityGroups []string, parameterGroup string, optionGroup string) error {
+	from := db.Spec.RestoreFrom
+	if from.SnapshotIdentifier != "" && from.PointInTime != nil {
+		return fmt.Errorf("only one of snapshotIdentifier and pointInTime can be set in restoreFrom")
+	}
+	if from.SnapshotIdentifier != "" {
+		log.Printf("Restoring db instance %v from snapshot %v\n", dbidentifier(db), from.SnapshotIdentifier)
+		input := convertSpecToSnapshotRestoreInput(db, subnetName, securityGroups, parameterGroup, optionGroup)
+		input.Tags = withDefaultTags(input.Tags, r.Tags)
+		_, err := svc.RestoreDBInstanceFromDBSnapshot(ctx, input)
+		return errors.Wrap(err, "RestoreDBInstanceFromDBSnapshot")
+	}
+	log.Printf("Restoring db instance %v from db instance %v\n", dbidentifier(db), pointInTimeSource(db))
+	input := convertSpecToPointInTimeInput(db, subnetName, securityGroups, parameterGroup, optionGroup)
+	input.Tags = withDefaultTags(input.Tags, r.Tags)
+	_, err := svc.RestoreDBInstanceToPointInTime(ctx, input)
+	return errors.Wrap(err, "RestoreDBInstanceToPointInTime")
+}
+
+// pointInTimeSource is the identifier of the instance of the database referenced by spec.restoreFrom.pointInTime
+func pointInTimeSource(db *databasev1.Database) string {
+	return dbidentifier(&databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: db.Spec.RestoreFrom.PointInTime.Database, Namespace: db.Namespace}})
+}
+
+func convertSpecToSnapshotRestoreInput(v *databasev1.Database, subnetName string, securityGroups []string, parameterGroup string, optionGroup string) *rds.RestoreDBInstanceFromDBSnapshotInput {
+	tags := toTags(v.Annotations, v.Labels)
+	tags = append(tags, gettags(v)...)
+
+	input := &rds.RestoreDBInstanceFromDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(dbidentifier(v)),
+		DBSnapshotIdentifier: aws.String(v.Spec.RestoreFrom.SnapshotIdentifier),
+		DBInstanceClass:      aws.String(v.Spec.Class),
+		VpcSecurityGroupIds:  securityGroups,
+		Engine:               aws.String(v.Spec.Engine),
+		DBSubnetGroupName:    aws.String(subnetName),
+		PubliclyAccessible:   aws.Bool(v.Spec.PubliclyAccessible),
+		MultiAZ:              aws.Bool(v.Spec.MultiAZ),
+		DeletionProtection:   aws.Bool(v.Spec.DeleteProtection),
+		Tags:                 tags,
+	}
+	if v.Spec.StorageType != "" {
+		input.StorageType = aws.String(v.Spec.StorageType)
+	}
+	if v.Spec.Iops > 0 {
+		input.Iops = aws.Int32(int32(v.Spec.Iops))
+	}
+	if v.Spec.Port > 0 {
+		input.Port = aws.Int32(v.Spec.Port)
+	}
+	if parameterGroup != "" {
+		input.DBParameterGroupName = aws.String(parameterGroup)
+	}
+	if optionGroup != "" {
+		input.OptionGroupName = aws.String(optionGroup)
+	}
+	return input
+}
+
+func convertSpecToPointInTimeInput(v *databasev1.Database, subnetName string, securityGroups []string, parameterGroup string, optionGroup string) *rds.RestoreDBInstanceToPointInTimeInput {
+	tags := toTags(v.Annotations, v.Labels)
+	tags = append(tags, gettags(v)...)
+
+	input := &rds.RestoreDBInstanceToPointInTimeInput{
+		TargetDBInstanceIdentifier: aws.String(dbidentifier(v)),
+		SourceDBInstanceIdentifier: aws.String(pointInTimeSource(v)),
+		DBInstanceClass:            aws.String(v.Spec.Class),
+		VpcSecurityGroupIds:        securityGroups,
+		Engine:                     aws.String(v.Spec.Engine),
+		DBSubnetGroupName:          aws.String(subnetName),
+		PubliclyAccessible:         aws.Bool(v.Spec.PubliclyAccessible),
+		MultiAZ:                    aws.Bool(v.Spec.MultiAZ),
+		DeletionProtection:         aws.Bool(v.Spec.DeleteProtection),
+		Tags:                       tags,
+	}
+	if t := v.Spec.RestoreFrom.PointInTime.RestoreTime; t != nil {
+		input.RestoreTime = aws.Time(t.Time)
+	} else {
+		input.UseLatestRestorableTime = true
+	}
+	if v.Spec.MaxAllocatedSize > 0 {
+		input.MaxAllocatedStorage = aws.Int32(int32(v.Spec.MaxAllocatedSize))
+	}
+	if v.Spec.StorageType != "" {
+		input.StorageType = aws.String(v.Spec.StorageType)
+	}
+	if v.Spec.Iops > 0 {
+		input.Iops = aws.Int32(int32(v.Spec.Iops))
+	}
+	if v.Spec.Port > 0 {
+		input.Port = aws.Int32(v.Spec.Port)
+	}
+	if parameterGroup != "" {
+		input.DBParameterGroupName = aws.String(parameterGroup)
+	}
+	if optionGroup != "" {
+		input.OptionGroupName = aws.String(optionGroup)
+	}
+	return input
+}
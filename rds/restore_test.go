@@ -0,0 +1,55 @@
+package rds
+
+import (
+	"testing"
+	"time"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertSpecToSnapshotRestoreInput(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"},
+		Spec: databasev1.DatabaseSpec{
+			Engine:      "postgres",
+			Class:       "db.t3.medium",
+			Port:        5433,
+			Tags:        "team=orders",
+			RestoreFrom: &databasev1.RestoreFrom{SnapshotIdentifier: "mydb-myns-1650000000"},
+		},
+	}
+	i := convertSpecToSnapshotRestoreInput(db, "subnets", []string{"sg-1"}, "", "")
+	assert.Equal(t, "mydb-myns", *i.DBInstanceIdentifier)
+	assert.Equal(t, "mydb-myns-1650000000", *i.DBSnapshotIdentifier)
+	assert.Equal(t, "db.t3.medium", *i.DBInstanceClass)
+	assert.Equal(t, "subnets", *i.DBSubnetGroupName)
+	assert.Equal(t, []string{"sg-1"}, i.VpcSecurityGroupIds)
+	assert.Equal(t, int32(5433), *i.Port)
+	assert.Len(t, i.Tags, 1)
+	assert.Nil(t, i.DBParameterGroupName)
+}
+
+func TestConvertSpecToPointInTimeInput(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"},
+		Spec: databasev1.DatabaseSpec{
+			Engine:      "postgres",
+			Class:       "db.t3.medium",
+			RestoreFrom: &databasev1.RestoreFrom{PointInTime: &databasev1.PointInTime{Database: "orders"}},
+		},
+	}
+	i := convertSpecToPointInTimeInput(db, "subnets", []string{"sg-1"}, "mydb-myns", "")
+	assert.Equal(t, "mydb-myns", *i.TargetDBInstanceIdentifier)
+	assert.Equal(t, "orders-myns", *i.SourceDBInstanceIdentifier)
+	assert.True(t, i.UseLatestRestorableTime)
+	assert.Nil(t, i.RestoreTime)
+	assert.Equal(t, "mydb-myns", *i.DBParameterGroupName)
+
+	at := meta_v1.NewTime(time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC))
+	db.Spec.RestoreFrom.PointInTime.RestoreTime = &at
+	i = convertSpecToPointInTimeInput(db, "subnets", []string{"sg-1"}, "mydb-myns", "")
+	assert.False(t, i.UseLatestRestorableTime)
+	assert.Equal(t, at.Time, *i.RestoreTime)
+}
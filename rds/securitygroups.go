@@ -0,0 +1,252 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+)
+
+// ensureSecurityGroups returns the security groups of the database. Without spec.securityGroupIds the database gets
+// a security group of its own, allowing the port of the database from the security groups of the nodes, the groups
+// in spec.allowedSecurityGroupIds and the blocks in spec.allowedCIDRs
+func (r *RDS) ensureSecurityGroups(ctx context.Context, db *databasev1.Database) ([]string, error) {
+	if len(db.Spec.SecurityGroupIDs) > 0 {
+		return db.Spec.SecurityGroupIDs, nil
+	}
+	description := fmt.Sprintf("Access to database %v/%v", db.Namespace, db.Name)
+	return r.ensureSecurityGroup(ctx, securitygroupname(db), description, provider.DatabasePort(db, 0), db.Spec.AllowedSecurityGroups, db.Spec.AllowedCIDRs)
+}
+
+// ensureClusterSecurityGroups returns the security groups of the cluster, they follow the same rules as the groups
+// of a database
+func (r *RDS) ensureClusterSecurityGroups(ctx context.Context, c *databasev1.DatabaseCluster) ([]string, error) {
+	if len(c.Spec.SecurityGroupIDs) > 0 {
+		return c.Spec.SecurityGroupIDs, nil
+	}
+	description := fmt.Sprintf("Access to database cluster %v/%v", c.Namespace, c.Name)
+	return r.ensureSecurityGroup(ctx, clustersecuritygroupname(c), description, provider.ClusterPort(c, 0), c.Spec.AllowedSecurityGroups, c.Spec.AllowedCIDRs)
+}
+
+// ensureSecurityGroup creates the security group name if it doesn't exist yet and makes its ingress rules allow port
+// from the security groups of the nodes, allowedGroups and cidrs only
+func (r *RDS) ensureSecurityGroup(ctx context.Context, name, description string, port int32, allowedGroups, cidrs []string) ([]string, error) {
+	group, err := r.describeSecurityGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		log.Printf("Creating security group %v in VPC %v\n", name, r.VpcId)
+		res, err := r.EC2.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+			GroupName:   aws.String(name),
+			Description: aws.String(description),
+			VpcId:       aws.String(r.VpcId),
+			TagSpecifications: []ec2types.TagSpecification{{
+				ResourceType: ec2types.ResourceTypeSecurityGroup,
+				Tags: []ec2types.Tag{
+					{Key: aws.String("Name"), Value: aws.String(name)},
+					{Key: managedTag.Key, Value: managedTag.Value},
+				},
+			}},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "CreateSecurityGroup")
+		}
+		group = &ec2types.SecurityGroup{GroupId: res.GroupId}
+	}
+
+	sources := append(append([]string{}, r.SecurityGroups...), allowedGroups...)
+	authorize, revoke := ingressChanges(group.IpPermissions, port, sources, cidrs)
+	if len(revoke) > 0 {
+		log.Printf("Revoking %d ingress rules of security group %v\n", len(revoke), name)
+		_, err = r.EC2.RevokeSecurityGroupIngress(ctx, &ec2.RevokeSecurityGroupIngressInput{GroupId: group.GroupId, IpPermissions: revoke})
+		if err != nil {
+			return nil, errors.Wrap(err, "RevokeSecurityGroupIngress")
+		}
+	}
+	if len(authorize) > 0 {
+		log.Printf("Authorizing ingress to security group %v\n", name)
+		_, err = r.EC2.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{GroupId: group.GroupId, IpPermissions: authorize})
+		if err != nil {
+			return nil, errors.Wrap(err, "AuthorizeSecurityGroupIngress")
+		}
+	}
+	return []string{aws.ToString(group.GroupId)}, nil
+}
+
+// ingressChanges compares the ingress rules of a group with the groups and CIDR blocks that should reach port over
+// tcp, rules for other ports or protocols are revoked
+func ingressChanges(current []ec2types.IpPermission, port int32, groups []string, cidrs []string) (authorize []ec2types.IpPermission, revoke []ec2types.IpPermission) {
+	wanted := map[string]bool{}
+	for _, g := range groups {
+		wanted[g] = true
+	}
+	for _, c := range cidrs {
+		wanted[c] = true
+	}
+
+	present := map[string]bool{}
+	for _, p := range current {
+		if aws.ToString(p.IpProtocol) != "tcp" || aws.ToInt32(p.FromPort) != port || aws.ToInt32(p.ToPort) != port {
+			revoke = append(revoke, p)
+			continue
+		}
+		stale := tcpPermission(port)
+		for _, pair := range p.UserIdGroupPairs {
+			if id := aws.ToString(pair.GroupId); wanted[id] {
+				present[id] = true
+			} else {
+				stale.UserIdGroupPairs = append(stale.UserIdGroupPairs, ec2types.UserIdGroupPair{GroupId: pair.GroupId})
+			}
+		}
+		for _, r := range p.IpRanges {
+			if cidr := aws.ToString(r.CidrIp); wanted[cidr] {
+				present[cidr] = true
+			} else {
+				stale.IpRanges = append(stale.IpRanges, ec2types.IpRange{CidrIp: r.CidrIp})
+			}
+		}
+		for _, r := range p.Ipv6Ranges {
+			if cidr := aws.ToString(r.CidrIpv6); wanted[cidr] {
+				present[cidr] = true
+			} else {
+				stale.Ipv6Ranges = append(stale.Ipv6Ranges, ec2types.Ipv6Range{CidrIpv6: r.CidrIpv6})
+			}
+		}
+		if len(stale.UserIdGroupPairs)+len(stale.IpRanges)+len(stale.Ipv6Ranges) > 0 {
+			revoke = append(revoke, stale)
+		}
+	}
+
+	missing := tcpPermission(port)
+	for _, g := range groups {
+		if !present[g] {
+			present[g] = true
+			missing.UserIdGroupPairs = append(missing.UserIdGroupPairs, ec2types.UserIdGroupPair{GroupId: aws.String(g)})
+		}
+	}
+	for _, c := range cidrs {
+		if present[c] {
+			continue
+		}
+		present[c] = true
+		if strings.Contains(c, ":") {
+			missing.Ipv6Ranges = append(missing.Ipv6Ranges, ec2types.Ipv6Range{CidrIpv6: aws.String(c)})
+		} else {
+			missing.IpRanges = append(missing.IpRanges, ec2types.IpRange{CidrIp: aws.String(c)})
+		}
+	}
+	if len(missing.UserIdGroupPairs)+len(missing.IpRanges)+len(missing.Ipv6Ranges) > 0 {
+		authorize = append(authorize, missing)
+	}
+	return authorize, revoke
+}
+
+func tcpPermission(port int32) ec2types.IpPermission {
+	return ec2types.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(port), ToPort: aws.Int32(port)}
+}
+
+// describeSecurityGroup finds a security group by name in the VPC of the cluster, it returns nil if it doesn't exist
+func (r *RDS) describeSecurityGroup(ctx context.Context, name string) (*ec2types.SecurityGroup, error) {
+	res, err := r.EC2.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{Filters: []ec2types.Filter{
+		{Name: aws.String("group-name"), Values: []string{name}},
+		{Name: aws.String("vpc-id"), Values: []string{r.VpcId}},
+	}})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("unable to describe security group %v", name))
+	}
+	if len(res.SecurityGroups) == 0 {
+		return nil, nil
+	}
+	return &res.SecurityGroups[0], nil
+}
+
+// deleteSecurityGroup deletes the security group name owned by a database or cluster. The network interfaces of a
+// deleted instance are released a while after the instance is gone, until then a provider.PendingError is returned
+func (r *RDS) deleteSecurityGroup(ctx context.Context, name string) error {
+	group, err := r.describeSecurityGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return nil
+	}
+	if !hasEC2Tag(group.Tags, managedTag) {
+		log.Printf("Security group %v wasn't created by the operator, keeping it\n", name)
+		return nil
+	}
+
+	_, err = r.EC2.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: group.GroupId})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "DependencyViolation" {
+		return &provider.PendingError{State: "deleting", Message: fmt.Sprintf("waiting for security group %v to be released", name)}
+	}
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to delete security group %v", name))
+	}
+	log.Println("Deleted security group: ", name)
+	return nil
+}
+
+// instanceSecurityGroups returns the ids of the security groups of the instance
+func instanceSecurityGroups(instance *rdstypes.DBInstance) []string {
+	return membershipIDs(instance.VpcSecurityGroups)
+}
+
+// clusterSecurityGroups returns the ids of the security groups of the cluster
+func clusterSecurityGroups(cluster *rdstypes.DBCluster) []string {
+	return membershipIDs(cluster.VpcSecurityGroups)
+}
+
+func membershipIDs(groups []rdstypes.VpcSecurityGroupMembership) []string {
+	var ids []string
+	for _, g := range groups {
+		ids = append(ids, aws.ToString(g.VpcSecurityGroupId))
+	}
+	return ids
+}
+
+// sameIDs reports whether current and wanted hold the same ids, the order doesn't matter
+func sameIDs(current []string, wanted []string) bool {
+	ids := map[string]bool{}
+	for _, id := range current {
+		ids[id] = true
+	}
+	if len(ids) != len(wanted) {
+		return false
+	}
+	for _, id := range wanted {
+		if !ids[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func hasEC2Tag(tags []ec2types.Tag, tag rdstypes.Tag) bool {
+	for _, t := range tags {
+		if aws.ToString(t.Key) == aws.ToString(tag.Key) && aws.ToString(t.Value) == aws.ToString(tag.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// securitygroupname is the name of the security group owned by the database
+func securitygroupname(db *databasev1.Database) string {
+	return "k8s-rds-" + dbidentifier(db)
+}
+
+// clustersecuritygroupname is the name of the security group owned by the cluster
+func clustersecuritygroupname(c *databasev1.DatabaseCluster) string {
+	return "k8s-rds-cluster-" + clusteridentifier(c)
+}
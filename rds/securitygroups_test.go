@@ -0,0 +1,85 @@
+package rds
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIngressChanges(t *testing.T) {
+	authorize, revoke := ingressChanges(nil, 5432, []string{"sg-nodes"}, []string{"10.0.0.0/16", "2001:db8::/32"})
+	assert.Empty(t, revoke)
+	assert.Len(t, authorize, 1)
+	assert.Equal(t, "tcp", *authorize[0].IpProtocol)
+	assert.Equal(t, int32(5432), *authorize[0].FromPort)
+	assert.Equal(t, int32(5432), *authorize[0].ToPort)
+	assert.Equal(t, "sg-nodes", *authorize[0].UserIdGroupPairs[0].GroupId)
+	assert.Equal(t, "10.0.0.0/16", *authorize[0].IpRanges[0].CidrIp)
+	assert.Equal(t, "2001:db8::/32", *authorize[0].Ipv6Ranges[0].CidrIpv6)
+
+	current := []ec2types.IpPermission{
+		{
+			IpProtocol:       aws.String("tcp"),
+			FromPort:         aws.Int32(5432),
+			ToPort:           aws.Int32(5432),
+			UserIdGroupPairs: []ec2types.UserIdGroupPair{{GroupId: aws.String("sg-nodes")}, {GroupId: aws.String("sg-old")}},
+			IpRanges:         []ec2types.IpRange{{CidrIp: aws.String("10.0.0.0/16")}},
+		},
+		{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(3306), ToPort: aws.Int32(3306), IpRanges: []ec2types.IpRange{{CidrIp: aws.String("10.0.0.0/16")}}},
+	}
+	authorize, revoke = ingressChanges(current, 5432, []string{"sg-nodes", "sg-pods"}, []string{"10.0.0.0/16"})
+	assert.Len(t, authorize, 1)
+	assert.Len(t, authorize[0].UserIdGroupPairs, 1)
+	assert.Equal(t, "sg-pods", *authorize[0].UserIdGroupPairs[0].GroupId)
+	assert.Empty(t, authorize[0].IpRanges)
+	assert.Len(t, revoke, 2)
+	assert.Equal(t, "sg-old", *revoke[0].UserIdGroupPairs[0].GroupId)
+	assert.Empty(t, revoke[0].IpRanges)
+	assert.Equal(t, int32(3306), *revoke[1].FromPort)
+
+	authorize, revoke = ingressChanges(current[:1], 5432, []string{"sg-nodes", "sg-old"}, []string{"10.0.0.0/16"})
+	assert.Empty(t, authorize)
+	assert.Empty(t, revoke)
+}
+
+func TestSameIDs(t *testing.T) {
+	assert.True(t, sameIDs([]string{"sg-1", "sg-2"}, []string{"sg-2", "sg-1"}))
+	assert.False(t, sameIDs([]string{"sg-1", "sg-2"}, []string{"sg-1"}))
+	assert.False(t, sameIDs(nil, []string{"sg-1"}))
+}
+
+func TestConvertSpecToModifyInputSecurityGroups(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"}}
+	instance := &rdstypes.DBInstance{
+		VpcSecurityGroups: []rdstypes.VpcSecurityGroupMembership{{VpcSecurityGroupId: aws.String("sg-nodes"), Status: aws.String("active")}},
+	}
+	assert.Equal(t, []string{"sg-nodes"}, instanceSecurityGroups(instance))
+	assert.Equal(t, "k8s-rds-mydb-myns", securitygroupname(db))
+
+	input := convertSpecToModifyInput(db, instance, []string{"sg-db"}, "", "")
+	assert.NotNil(t, input)
+	assert.Equal(t, []string{"sg-db"}, input.VpcSecurityGroupIds)
+
+	assert.Nil(t, convertSpecToModifyInput(db, instance, []string{"sg-nodes"}, "", ""))
+}
+
+func TestConvertClusterSpecToModifyInputSecurityGroups(t *testing.T) {
+	c := &databasev1.DatabaseCluster{ObjectMeta: meta_v1.ObjectMeta{Name: "mycluster", Namespace: "myns"}}
+	cluster := &rdstypes.DBCluster{
+		BackupRetentionPeriod: aws.Int32(1),
+		VpcSecurityGroups:     []rdstypes.VpcSecurityGroupMembership{{VpcSecurityGroupId: aws.String("sg-nodes"), Status: aws.String("active")}},
+	}
+	assert.Equal(t, []string{"sg-nodes"}, clusterSecurityGroups(cluster))
+	assert.Equal(t, "k8s-rds-cluster-mycluster-myns", clustersecuritygroupname(c))
+
+	input := convertClusterSpecToModifyInput(c, cluster, []string{"sg-cluster"})
+	assert.NotNil(t, input)
+	assert.Equal(t, []string{"sg-cluster"}, input.VpcSecurityGroupIds)
+
+	assert.Nil(t, convertClusterSpecToModifyInput(c, cluster, []string{"sg-nodes"}))
+}
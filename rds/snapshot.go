@@ -0,0 +1,90 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+)
+
+// CreateSnapshot calls CreateDBSnapshot for the instance of the database unless the snapshot already exists, the
+// progress is taken from the snapshot
+func (r *RDS) CreateSnapshot(ctx context.Context, db *databasev1.Database, s *databasev1.DatabaseSnapshot) (*provider.SnapshotInfo, error) {
+	svc := r.Client
+	id := snapshotidentifier(s)
+	snapshot, err := describeSnapshot(ctx, svc, id)
+	if isSnapshotNotFound(err) {
+		log.Printf("Creating snapshot %v of db instance %v\n", id, dbidentifier(db))
+		_, err = svc.CreateDBSnapshot(ctx, convertSpecToSnapshotInput(db, s))
+		if err != nil {
+			return nil, errors.Wrap(err, "CreateDBSnapshot")
+		}
+		return &provider.SnapshotInfo{ID: id, State: "creating"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if aws.ToString(snapshot.Status) == "failed" {
+		return nil, fmt.Errorf("snapshot %v failed", id)
+	}
+	return &provider.SnapshotInfo{
+		ID:           id,
+		State:        aws.ToString(snapshot.Status),
+		Progress:     snapshot.PercentProgress,
+		Size:         int64(snapshot.AllocatedStorage),
+		CreationTime: snapshot.SnapshotCreateTime,
+	}, nil
+}
+
+// DeleteSnapshot deletes the DB snapshot recorded in the status
+func (r *RDS) DeleteSnapshot(ctx context.Context, s *databasev1.DatabaseSnapshot) error {
+	id := s.Status.SnapshotID
+	if id == "" {
+		return nil
+	}
+	log.Printf("Deleting snapshot %v\n", id)
+	_, err := r.Client.DeleteDBSnapshot(ctx, &rds.DeleteDBSnapshotInput{DBSnapshotIdentifier: aws.String(id)})
+	if err != nil && !isSnapshotNotFound(err) {
+		return errors.Wrap(err, fmt.Sprintf("unable to delete snapshot %v", id))
+	}
+	return nil
+}
+
+func convertSpecToSnapshotInput(db *databasev1.Database, s *databasev1.DatabaseSnapshot) *rds.CreateDBSnapshotInput {
+	tags := toTags(s.Annotations, s.Labels)
+	tags = append(tags, gettags(db)...)
+	return &rds.CreateDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(dbidentifier(db)),
+		DBSnapshotIdentifier: aws.String(snapshotidentifier(s)),
+		Tags:                 tags,
+	}
+}
+
+// describeSnapshot returns the DB snapshot with the given identifier, the error wraps a DBSnapshotNotFoundFault if
+// it doesn't exist
+func describeSnapshot(ctx context.Context, svc RDSAPI, id string) (*rdstypes.DBSnapshot, error) {
+	res, err := svc.DescribeDBSnapshots(ctx, &rds.DescribeDBSnapshotsInput{DBSnapshotIdentifier: aws.String(id)})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("wasn't able to describe the snapshot with id %v", id))
+	}
+	if len(res.DBSnapshots) == 0 {
+		return nil, errors.Wrap(&rdstypes.DBSnapshotNotFoundFault{}, fmt.Sprintf("unable to find snapshot %v", id))
+	}
+	return &res.DBSnapshots[0], nil
+}
+
+func isSnapshotNotFound(err error) bool {
+	var notFound *rdstypes.DBSnapshotNotFoundFault
+	return errors.As(err, &notFound)
+}
+
+// snapshotidentifier is the identifier of the DB snapshot taken for the DatabaseSnapshot
+func snapshotidentifier(s *databasev1.DatabaseSnapshot) string {
+	return fmt.Sprintf("%s-%s", s.Name, s.Namespace)
+}
@@ -0,0 +1,32 @@
+package rds
+
+import (
+	"testing"
+
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertSpecToSnapshotInput(t *testing.T) {
+	db := &databasev1.Database{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"},
+		Spec:       databasev1.DatabaseSpec{Tags: "team=orders"},
+	}
+	s := &databasev1.DatabaseSnapshot{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "nightly-1648782000", Namespace: "myns", Labels: map[string]string{"release": "1.2"}},
+		Spec:       databasev1.DatabaseSnapshotSpec{Database: "mydb"},
+	}
+	i := convertSpecToSnapshotInput(db, s)
+	assert.Equal(t, "mydb-myns", *i.DBInstanceIdentifier)
+	assert.Equal(t, "nightly-1648782000-myns", *i.DBSnapshotIdentifier)
+	assert.Len(t, i.Tags, 2)
+}
+
+func TestIsSnapshotNotFound(t *testing.T) {
+	assert.True(t, isSnapshotNotFound(errors.Wrap(&rdstypes.DBSnapshotNotFoundFault{}, "describe")))
+	assert.False(t, isSnapshotNotFound(errors.Wrap(&rdstypes.DBInstanceNotFoundFault{}, "describe")))
+	assert.False(t, isSnapshotNotFound(nil))
+}
@@ -0,0 +1,256 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/pkg/errors"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+)
+
+// managedTag marks the groups created by the operator, only those are ever deleted
+var managedTag = rdstypes.Tag{Key: aws.String("Warning"), Value: aws.String("Managed by k8s-rds.")}
+
+// ensureSubnetGroup returns the subnet group of the database. A referenced group is used as is, subnetIds or
+// subnetTags create or update the group owned by the database and without spec.subnetGroup the group shared by the
+// databases in the VPC is used
+func (r *RDS) ensureSubnetGroup(ctx context.Context, svc RDSAPI, db *databasev1.Database) (string, error) {
+	sg := db.Spec.SubnetGroup
+	if sg == nil {
+		return r.ensureSubnets(ctx)
+	}
+	if err := validateSubnetGroup(sg); err != nil {
+		return "", err
+	}
+	if sg.Name != "" {
+		return sg.Name, nil
+	}
+
+	subnets := sg.SubnetIDs
+	if len(sg.SubnetTags) > 0 {
+		var err error
+		subnets, err = r.subnetsByTags(ctx, sg.SubnetTags)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(subnets) == 0 {
+		return "", fmt.Errorf("no subnets found for the subnet group of %v", db.Name)
+	}
+
+	name := subnetgroupname(db)
+	res, err := svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String(name)})
+	if isSubnetGroupNotFound(err) {
+		log.Printf("Creating DBSubnet group %v with subnets %v\n", name, subnets)
+		_, err = svc.CreateDBSubnetGroup(ctx, &rds.CreateDBSubnetGroupInput{
+			DBSubnetGroupName:        aws.String(name),
+			DBSubnetGroupDescription: aws.String(fmt.Sprintf("Subnets of database %v/%v", db.Namespace, db.Name)),
+			SubnetIds:                subnets,
+			Tags:                     []rdstypes.Tag{managedTag},
+		})
+		return name, errors.Wrap(err, "CreateDBSubnetGroup")
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "DescribeDBSubnetGroups")
+	}
+	if len(res.DBSubnetGroups) > 0 && !sameSubnets(res.DBSubnetGroups[0].Subnets, subnets) {
+		log.Printf("Changing the subnets of DBSubnet group %v to %v\n", name, subnets)
+		_, err = svc.ModifyDBSubnetGroup(ctx, &rds.ModifyDBSubnetGroupInput{DBSubnetGroupName: aws.String(name), SubnetIds: subnets})
+		if err != nil {
+			return "", errors.Wrap(err, "ModifyDBSubnetGroup")
+		}
+	}
+	return name, nil
+}
+
+func validateSubnetGroup(sg *databasev1.SubnetGroup) error {
+	set := 0
+	if sg.Name != "" {
+		set++
+	}
+	if len(sg.SubnetIDs) > 0 {
+		set++
+	}
+	if len(sg.SubnetTags) > 0 {
+		set++
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of name, subnetIds and subnetTags has to be set in subnetGroup")
+	}
+	return nil
+}
+
+// subnetsByTags returns the subnets in the VPC of the cluster with all the tags
+func (r *RDS) subnetsByTags(ctx context.Context, tags map[string]string) ([]string, error) {
+	res, err := r.EC2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{Filters: subnetFilters(r.VpcId, tags)})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("unable to describe subnets in VPC %v", r.VpcId))
+	}
+	var subnets []string
+	for _, sn := range res.Subnets {
+		subnets = append(subnets, aws.ToString(sn.SubnetId))
+	}
+	return subnets, nil
+}
+
+func subnetFilters(vpcID string, tags map[string]string) []ec2types.Filter {
+	filters := []ec2types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		filters = append(filters, ec2types.Filter{Name: aws.String("tag:" + key), Values: []string{tags[key]}})
+	}
+	return filters
+}
+
+// sameSubnets reports whether the subnets of a group are the wanted subnets, the order doesn't matter
+func sameSubnets(current []rdstypes.Subnet, wanted []string) bool {
+	var ids []string
+	for _, s := range current {
+		ids = append(ids, aws.ToString(s.SubnetIdentifier))
+	}
+	return sameIDs(ids, wanted)
+}
+
+// releaseSubnetGroup deletes the subnet group a deleted database used, the group owned by the database is always
+// deleted and a shared group once no instance or cluster uses it anymore. Referenced groups are never deleted
+func (r *RDS) releaseSubnetGroup(ctx context.Context, svc RDSAPI, db *databasev1.Database, attached string) error {
+	err := deleteSubnetGroup(ctx, svc, subnetgroupname(db))
+	if err != nil {
+		return err
+	}
+	if attached == "" && db.Spec.SubnetGroup != nil {
+		return nil
+	}
+	return r.releaseSharedSubnetGroup(ctx, svc, attached)
+}
+
+// releaseSharedSubnetGroup deletes the shared subnet group attached when no instance or cluster uses it anymore, each
+// shared group is counted on its own. attached is empty when the database is already gone, the group it was created
+// in is used then. Groups that aren't shared are left alone
+func (r *RDS) releaseSharedSubnetGroup(ctx context.Context, svc RDSAPI, attached string) error {
+	name := attached
+	if name == "" {
+		name = sharedsubnetgroupname(r.VpcId, r.Public)
+	}
+	if name != sharedsubnetgroupname(r.VpcId, false) && name != sharedsubnetgroupname(r.VpcId, true) {
+		return nil
+	}
+	users, err := subnetGroupUsers(ctx, svc, name)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		log.Printf("DBSubnet group %v is still used by %d instances and clusters\n", name, users)
+		return nil
+	}
+	return deleteSubnetGroup(ctx, svc, name)
+}
+
+// subnetGroupUsers counts the instances and clusters in the subnet group
+func subnetGroupUsers(ctx context.Context, svc RDSAPI, name string) (int, error) {
+	users := 0
+	instances := rds.NewDescribeDBInstancesPaginator(svc, &rds.DescribeDBInstancesInput{})
+	for instances.HasMorePages() {
+		page, err := instances.NextPage(ctx)
+		if err != nil {
+			return 0, errors.Wrap(err, "DescribeDBInstances")
+		}
+		for _, i := range page.DBInstances {
+			if i.DBSubnetGroup != nil && aws.ToString(i.DBSubnetGroup.DBSubnetGroupName) == name {
+				users++
+			}
+		}
+	}
+	clusters := rds.NewDescribeDBClustersPaginator(svc, &rds.DescribeDBClustersInput{})
+	for clusters.HasMorePages() {
+		page, err := clusters.NextPage(ctx)
+		if err != nil {
+			return 0, errors.Wrap(err, "DescribeDBClusters")
+		}
+		for _, c := range page.DBClusters {
+			if aws.ToString(c.DBSubnetGroup) == name {
+				users++
+			}
+		}
+	}
+	return users, nil
+}
+
+// deleteSubnetGroup deletes a subnet group created by the operator, groups without the managed tag are kept
+func deleteSubnetGroup(ctx context.Context, svc RDSAPI, name string) error {
+	res, err := svc.DescribeDBSubnetGroups(ctx, &rds.DescribeDBSubnetGroupsInput{DBSubnetGroupName: aws.String(name)})
+	if isSubnetGroupNotFound(err) {
+		log.Printf("DBSubnet group %v doesn't exist, nothing to delete\n", name)
+		return nil
+	}
+	if err != nil || len(res.DBSubnetGroups) == 0 {
+		return errors.Wrap(err, fmt.Sprintf("unable to describe subnet group %v", name))
+	}
+	tags, err := svc.ListTagsForResource(ctx, &rds.ListTagsForResourceInput{ResourceName: res.DBSubnetGroups[0].DBSubnetGroupArn})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("unable to list the tags of subnet group %v", name))
+	}
+	if !hasTag(tags.TagList, managedTag) {
+		log.Printf("DBSubnet group %v wasn't created by the operator, keeping it\n", name)
+		return nil
+	}
+
+	_, err = svc.DeleteDBSubnetGroup(ctx, &rds.DeleteDBSubnetGroupInput{DBSubnetGroupName: aws.String(name)})
+	var invalidState *rdstypes.InvalidDBSubnetGroupStateFault
+	if errors.As(err, &invalidState) {
+		log.Printf("Keeping DBSubnet group %v, it is still in use: %v\n", name, err)
+		return nil
+	}
+	if err != nil && !isSubnetGroupNotFound(err) {
+		return errors.Wrap(err, fmt.Sprintf("unable to delete subnet group %v", name))
+	}
+	log.Println("Deleted DBSubnet group: ", name)
+	return nil
+}
+
+// attachedSubnetGroup returns the name of the subnet group of the instance, instance is nil if it doesn't exist
+func attachedSubnetGroup(instance *rdstypes.DBInstance) string {
+	if instance == nil || instance.DBSubnetGroup == nil {
+		return ""
+	}
+	return aws.ToString(instance.DBSubnetGroup.DBSubnetGroupName)
+}
+
+func hasTag(tags []rdstypes.Tag, tag rdstypes.Tag) bool {
+	for _, t := range tags {
+		if aws.ToString(t.Key) == aws.ToString(tag.Key) && aws.ToString(t.Value) == aws.ToString(tag.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSubnetGroupNotFound(err error) bool {
+	var notFound *rdstypes.DBSubnetGroupNotFoundFault
+	return errors.As(err, &notFound)
+}
+
+// subnetgroupname is the name of the subnet group owned by the database
+func subnetgroupname(db *databasev1.Database) string {
+	return db.Name + "-subnet-" + db.Namespace
+}
+
+// sharedsubnetgroupname is the name of the subnet group shared by the databases without spec.subnetGroup, publicly
+// accessible databases share a group of public subnets
+func sharedsubnetgroupname(vpcID string, public bool) string {
+	if public {
+		return "db-subnetgroup-public-" + vpcID
+	}
+	return "db-subnetgroup-" + vpcID
+}
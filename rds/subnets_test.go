@@ -0,0 +1,53 @@
+package rds
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/stretchr/testify/assert"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateSubnetGroup(t *testing.T) {
+	assert.NoError(t, validateSubnetGroup(&databasev1.SubnetGroup{Name: "shared"}))
+	assert.NoError(t, validateSubnetGroup(&databasev1.SubnetGroup{SubnetIDs: []string{"subnet-1"}}))
+	assert.NoError(t, validateSubnetGroup(&databasev1.SubnetGroup{SubnetTags: map[string]string{"tier": "db"}}))
+	assert.Error(t, validateSubnetGroup(&databasev1.SubnetGroup{}))
+	assert.Error(t, validateSubnetGroup(&databasev1.SubnetGroup{Name: "shared", SubnetIDs: []string{"subnet-1"}}))
+}
+
+func TestSubnetFilters(t *testing.T) {
+	filters := subnetFilters("vpc-1", map[string]string{"tier": "db", "kubernetes.io/role/internal-elb": "1"})
+	assert.Len(t, filters, 3)
+	assert.Equal(t, "vpc-id", *filters[0].Name)
+	assert.Equal(t, []string{"vpc-1"}, filters[0].Values)
+	assert.Equal(t, "tag:kubernetes.io/role/internal-elb", *filters[1].Name)
+	assert.Equal(t, "tag:tier", *filters[2].Name)
+	assert.Equal(t, []string{"db"}, filters[2].Values)
+}
+
+func TestSameSubnets(t *testing.T) {
+	current := []rdstypes.Subnet{{SubnetIdentifier: aws.String("subnet-1")}, {SubnetIdentifier: aws.String("subnet-2")}}
+	assert.True(t, sameSubnets(current, []string{"subnet-2", "subnet-1"}))
+	assert.False(t, sameSubnets(current, []string{"subnet-1"}))
+	assert.False(t, sameSubnets(current, []string{"subnet-1", "subnet-3"}))
+}
+
+func TestSubnetGroupNames(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: meta_v1.ObjectMeta{Name: "mydb", Namespace: "myns"}}
+	assert.Equal(t, "mydb-subnet-myns", subnetgroupname(db))
+	assert.Equal(t, "db-subnetgroup-vpc-1", sharedsubnetgroupname("vpc-1", false))
+	assert.Equal(t, "db-subnetgroup-public-vpc-1", sharedsubnetgroupname("vpc-1", true))
+
+	assert.Equal(t, "", attachedSubnetGroup(nil))
+	instance := &rdstypes.DBInstance{DBSubnetGroup: &rdstypes.DBSubnetGroup{DBSubnetGroupName: aws.String("mydb-subnet-myns")}}
+	assert.Equal(t, "mydb-subnet-myns", attachedSubnetGroup(instance))
+}
+
+func TestHasTag(t *testing.T) {
+	assert.True(t, hasTag([]rdstypes.Tag{{Key: aws.String("team"), Value: aws.String("a")}, managedTag}, managedTag))
+	assert.False(t, hasTag([]rdstypes.Tag{{Key: aws.String("Warning"), Value: aws.String("other")}}, managedTag))
+	assert.False(t, hasTag(nil, managedTag))
+}
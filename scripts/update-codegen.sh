@@ -0,0 +1,43 @@
+#!/usr/bin/env bash
+# Regenerates the deepcopy functions, clientset, listers and informers of the database API.
+# Run it from the root of the repository after changing apis/database/v1/types.go
+set -o errexit
+set -o nounset
+set -o pipefail
+
+MODULE=github.com/sorenmat/k8s-rds
+APIS=${MODULE}/apis/database/v1
+HEADER=scripts/boilerplate.go.txt
+OUT=$(mktemp -d)
+trap 'rm -rf "${OUT}"' EXIT
+
+go run k8s.io/code-generator/cmd/deepcopy-gen \
+  --input-dirs "${APIS}" \
+  --output-file-base zz_generated.deepcopy \
+  --go-header-file "${HEADER}" \
+  --output-base "${OUT}"
+
+go run k8s.io/code-generator/cmd/client-gen \
+  --clientset-name versioned \
+  --input-base "${MODULE}/apis" \
+  --input database/v1 \
+  --output-package "${MODULE}/client/clientset" \
+  --go-header-file "${HEADER}" \
+  --output-base "${OUT}"
+
+go run k8s.io/code-generator/cmd/lister-gen \
+  --input-dirs "${APIS}" \
+  --output-package "${MODULE}/client/listers" \
+  --go-header-file "${HEADER}" \
+  --output-base "${OUT}"
+
+go run k8s.io/code-generator/cmd/informer-gen \
+  --input-dirs "${APIS}" \
+  --versioned-clientset-package "${MODULE}/client/clientset/versioned" \
+  --listers-package "${MODULE}/client/listers" \
+  --output-package "${MODULE}/client/informers" \
+  --go-header-file "${HEADER}" \
+  --output-base "${OUT}"
+
+rm -rf client/clientset client/listers client/informers
+cp -r "${OUT}/${MODULE}/." .
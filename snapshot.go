@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	databaseclient "github.com/sorenmat/k8s-rds/client/clientset/versioned/typed/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// Scheduled is the state of a database snapshot with a schedule
+const Scheduled = "Scheduled"
+
+// snapshotScheduleLabel is set on the snapshots created by a schedule, the value is the name of the scheduling snapshot
+const snapshotScheduleLabel = "databases.k8s.io/schedule"
+
+// defaultSnapshotRetention is the number of scheduled snapshots kept when spec.schedule.retention isn't set
+const defaultSnapshotRetention = 7
+
+// reconcileSnapshot takes the snapshot, or the scheduled snapshots, of a database. Snapshots are never changed once
+// they are available
+func (c *Controller) reconcileSnapshot(ctx context.Context, s *databasev1.DatabaseSnapshot, snapshotclient databaseclient.DatabaseSnapshotInterface) error {
+	if s.DeletionTimestamp != nil {
+		return c.handleDeleteSnapshot(ctx, s, snapshotclient)
+	}
+
+	var err error
+	if s.Spec.Schedule != nil {
+		err = c.handleSchedule(ctx, s, snapshotclient, time.Now())
+	} else if s.Status.State != Available {
+		err = c.handleCreateSnapshot(ctx, s, snapshotclient)
+	}
+	if pending, ok := provider.IsPending(err); ok {
+		serr := updateSnapshotStatus(ctx, s, snapshotclient, setSnapshotPending(pending))
+		if serr != nil {
+			log.Printf("database snapshot status update failed: %v", serr)
+		}
+		return err
+	}
+	if err != nil {
+		log.Printf("database snapshot %v failed: %v", s.Name, err)
+		serr := updateSnapshotStatus(ctx, s, snapshotclient, setSnapshotFailed(err))
+		if serr != nil {
+			log.Printf("database snapshot status update failed: %v", serr)
+		}
+	}
+	return err
+}
+
+// handleCreateSnapshot starts the snapshot once the database is available and records its progress, a
+// provider.PendingError is returned until the snapshot is done
+func (c *Controller) handleCreateSnapshot(ctx context.Context, s *databasev1.DatabaseSnapshot, snapshotclient databaseclient.DatabaseSnapshotInterface) error {
+	if !hasFinalizer(s) {
+		err := addFinalizer(ctx, s, snapshotFinalizers(snapshotclient))
+		if err != nil {
+			return fmt.Errorf("unable to add finalizer: %v", err)
+		}
+	}
+
+	db, err := c.lister.Databases(s.Namespace).Get(s.Spec.Database)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("database %v not found", s.Spec.Database)
+	}
+	if err != nil {
+		return err
+	}
+	if db.Status.State != Available {
+		return &provider.PendingError{State: "waiting", Message: fmt.Sprintf("waiting for database %v to become available", db.Name)}
+	}
+
+	r, err := c.getProvider(db)
+	if err != nil {
+		return err
+	}
+	info, err := r.CreateSnapshot(ctx, db, s)
+	if err != nil {
+		return err
+	}
+	err = updateSnapshotStatus(ctx, s, snapshotclient, setSnapshotInfo(c.providerName(db), info))
+	if err != nil {
+		return err
+	}
+	if info.State != provider.SnapshotAvailable {
+		return &provider.PendingError{State: info.State, Message: fmt.Sprintf("snapshot %v is %d%% done", info.ID, info.Progress)}
+	}
+	log.Printf("Snapshot %v of database %v done\n", s.Name, db.Name)
+	return nil
+}
+
+// handleSchedule creates a snapshot named after the run when the schedule is due and deletes the scheduled snapshots
+// above the retention. It returns a provider.PendingError to be called again at the next run
+func (c *Controller) handleSchedule(ctx context.Context, s *databasev1.DatabaseSnapshot, snapshotclient databaseclient.DatabaseSnapshotInterface, now time.Time) error {
+	schedule, err := cron.ParseStandard(s.Spec.Schedule.Cron)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %v", s.Spec.Schedule.Cron, err)
+	}
+
+	last := s.CreationTimestamp.Time
+	if s.Status.LastScheduleTime != nil {
+		last = s.Status.LastScheduleTime.Time
+	}
+	run := lastRun(schedule, last.UTC(), now.UTC())
+	if !run.IsZero() {
+		err = createScheduledSnapshot(ctx, s, snapshotclient, run)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.pruneScheduledSnapshots(ctx, s, snapshotclient)
+	if err != nil {
+		return err
+	}
+
+	next := schedule.Next(now.UTC())
+	err = updateSnapshotStatus(ctx, s, snapshotclient, setScheduled(run, next))
+	if err != nil {
+		return err
+	}
+	return &provider.PendingError{State: Scheduled, Message: fmt.Sprintf("next snapshot at %v", next.Format(time.RFC3339)), RequeueAfter: next.Sub(now)}
+}
+
+// lastRun returns the latest run of the schedule after last that isn't in the future, older missed runs are skipped.
+// It returns the zero time if no run is due
+func lastRun(schedule cron.Schedule, last, now time.Time) time.Time {
+	var run time.Time
+	for t := schedule.Next(last); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		run = t
+	}
+	return run
+}
+
+// createScheduledSnapshot creates the snapshot for a run of the schedule, it is owned by the scheduling snapshot
+func createScheduledSnapshot(ctx context.Context, s *databasev1.DatabaseSnapshot, snapshotclient databaseclient.DatabaseSnapshotInterface, run time.Time) error {
+	snapshot := &databasev1.DatabaseSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("%s-%d", s.Name, run.Unix()),
+			Namespace:       s.Namespace,
+			Labels:          map[string]string{snapshotScheduleLabel: s.Name},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(s, databasev1.SchemeGroupVersion.WithKind("DatabaseSnapshot"))},
+		},
+		Spec: databasev1.DatabaseSnapshotSpec{
+			Database:       s.Spec.Database,
+			DeletionPolicy: s.Spec.DeletionPolicy,
+		},
+	}
+	log.Printf("Creating scheduled snapshot %v of database %v\n", snapshot.Name, s.Spec.Database)
+	_, err := snapshotclient.Create(ctx, snapshot, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// pruneScheduledSnapshots deletes the oldest scheduled snapshots above spec.schedule.retention, their finalizer
+// takes care of the snapshot at the provider
+func (c *Controller) pruneScheduledSnapshots(ctx context.Context, s *databasev1.DatabaseSnapshot, snapshotclient databaseclient.DatabaseSnapshotInterface) error {
+	snapshots, err := c.snapshotLister.DatabaseSnapshots(s.Namespace).List(labels.SelectorFromSet(labels.Set{snapshotScheduleLabel: s.Name}))
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range expiredSnapshots(snapshots, snapshotRetention(s)) {
+		log.Printf("Deleting expired snapshot %v\n", snapshot.Name)
+		err = snapshotclient.Delete(ctx, snapshot.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredSnapshots returns the snapshots that are not the newest retention ones, snapshots that are already being
+// deleted don't count
+func expiredSnapshots(snapshots []*databasev1.DatabaseSnapshot, retention int) []*databasev1.DatabaseSnapshot {
+	var live []*databasev1.DatabaseSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.DeletionTimestamp == nil {
+			live = append(live, snapshot)
+		}
+	}
+	if len(live) <= retention {
+		return nil
+	}
+	sort.Slice(live, func(i, j int) bool {
+		if live[i].CreationTimestamp.Equal(&live[j].CreationTimestamp) {
+			return live[i].Name < live[j].Name
+		}
+		return live[i].CreationTimestamp.Before(&live[j].CreationTimestamp)
+	})
+	return live[:len(live)-retention]
+}
+
+func snapshotRetention(s *databasev1.DatabaseSnapshot) int {
+	if s.Spec.Schedule.Retention > 0 {
+		return int(s.Spec.Schedule.Retention)
+	}
+	return defaultSnapshotRetention
+}
+
+// handleDeleteSnapshot deletes the snapshot at the provider unless it is retained, the finalizer is only removed
+// once that succeeded
+func (c *Controller) handleDeleteSnapshot(ctx context.Context, s *databasev1.DatabaseSnapshot, snapshotclient databaseclient.DatabaseSnapshotInterface) error {
+	if !hasFinalizer(s) {
+		return nil
+	}
+
+	if s.Spec.DeletionPolicy != databasev1.SnapshotRetain && s.Status.SnapshotID != "" {
+		// the database might be gone already, the provider is the one that took the snapshot
+		db := &databasev1.Database{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace},
+			Spec:       databasev1.DatabaseSpec{Provider: s.Status.Provider},
+		}
+		r, err := c.getProvider(db)
+		if err == nil {
+			err = r.DeleteSnapshot(ctx, s)
+		}
+		if err != nil {
+			serr := updateSnapshotStatus(ctx, s, snapshotclient, setSnapshotDeleting(err))
+			if serr != nil {
+				log.Printf("database snapshot status update failed: %v", serr)
+			}
+			return err
+		}
+	}
+
+	err := removeFinalizer(ctx, s, snapshotFinalizers(snapshotclient))
+	if err != nil {
+		return fmt.Errorf("unable to remove finalizer: %v", err)
+	}
+	log.Printf("Deletion of database snapshot %v done\n", s.Name)
+	return nil
+}
+
+// updateSnapshotStatus is updateStatus for database snapshots
+func updateSnapshotStatus(ctx context.Context, s *databasev1.DatabaseSnapshot, snapshotclient databaseclient.DatabaseSnapshotInterface, mutate func(*databasev1.DatabaseSnapshotStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := snapshotclient.Get(ctx, s.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		mutate(&latest.Status)
+		now := metav1.Now()
+		latest.Status.LastReconcileTime = &now
+		_, err = snapshotclient.UpdateStatus(ctx, latest, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// setSnapshotInfo records the snapshot as reported by the provider
+func setSnapshotInfo(providerName string, info *provider.SnapshotInfo) func(*databasev1.DatabaseSnapshotStatus) {
+	return func(s *databasev1.DatabaseSnapshotStatus) {
+		s.Provider = providerName
+		s.SnapshotID = info.ID
+		s.Progress = info.Progress
+		s.Size = info.Size
+		s.LastError = ""
+		if info.CreationTime != nil {
+			t := metav1.NewTime(*info.CreationTime)
+			s.CreationTime = &t
+		}
+		if info.State == provider.SnapshotAvailable {
+			s.State = Available
+			s.Message = "Snapshot is available"
+		}
+	}
+}
+
+func setSnapshotPending(pending *provider.PendingError) func(*databasev1.DatabaseSnapshotStatus) {
+	return func(s *databasev1.DatabaseSnapshotStatus) {
+		s.State = pending.State
+		s.Message = pending.Message
+	}
+}
+
+// setScheduled records the last and next run of the schedule, run is zero if no snapshot was created
+func setScheduled(run, next time.Time) func(*databasev1.DatabaseSnapshotStatus) {
+	return func(s *databasev1.DatabaseSnapshotStatus) {
+		if !run.IsZero() {
+			t := metav1.NewTime(run)
+			s.LastScheduleTime = &t
+		}
+		n := metav1.NewTime(next)
+		s.NextScheduleTime = &n
+		s.LastError = ""
+	}
+}
+
+func setSnapshotFailed(err error) func(*databasev1.DatabaseSnapshotStatus) {
+	return func(s *databasev1.DatabaseSnapshotStatus) {
+		s.State = Failed
+		s.Message = err.Error()
+		s.LastError = err.Error()
+	}
+}
+
+func setSnapshotDeleting(err error) func(*databasev1.DatabaseSnapshotStatus) {
+	return func(s *databasev1.DatabaseSnapshotStatus) {
+		s.State = Deleting
+		s.Message = "Deletion failed, will retry"
+		s.LastError = err.Error()
+	}
+}
+
+func snapshotFinalizers(snapshotclient databaseclient.DatabaseSnapshotInterface) finalizerClient {
+	return finalizerClient{
+		get: func(ctx context.Context, name string) (metav1.Object, error) {
+			return snapshotclient.Get(ctx, name, metav1.GetOptions{})
+		},
+		patch: func(ctx context.Context, name string, patch []byte) error {
+			_, err := snapshotclient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+			return err
+		},
+	}
+}
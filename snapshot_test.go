@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/client/clientset/versioned/fake"
+	databaselisters "github.com/sorenmat/k8s-rds/client/listers/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestLastRun(t *testing.T) {
+	schedule, err := cron.ParseStandard("0 3 * * *")
+	assert.NoError(t, err)
+	last := time.Date(2022, 4, 1, 3, 0, 0, 0, time.UTC)
+
+	assert.True(t, lastRun(schedule, last, last.Add(23*time.Hour)).IsZero())
+	assert.Equal(t, last.Add(24*time.Hour), lastRun(schedule, last, last.Add(25*time.Hour)))
+	// missed runs are skipped, only the latest one is taken
+	assert.Equal(t, last.Add(72*time.Hour), lastRun(schedule, last, last.Add(73*time.Hour)))
+
+	never, err := cron.ParseStandard("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, lastRun(never, last, last.Add(24*time.Hour)).IsZero())
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	now := time.Now()
+	snapshot := func(name string, age time.Duration) *databasev1.DatabaseSnapshot {
+		return &databasev1.DatabaseSnapshot{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))}}
+	}
+	deleting := snapshot("deleting", 96*time.Hour)
+	deleting.DeletionTimestamp = &metav1.Time{Time: now}
+	snapshots := []*databasev1.DatabaseSnapshot{
+		snapshot("newest", time.Hour),
+		snapshot("oldest", 72*time.Hour),
+		deleting,
+		snapshot("older", 48*time.Hour),
+	}
+
+	assert.Empty(t, expiredSnapshots(snapshots, 3))
+	expired := expiredSnapshots(snapshots, 1)
+	assert.Len(t, expired, 2)
+	assert.Equal(t, "oldest", expired[0].Name)
+	assert.Equal(t, "older", expired[1].Name)
+}
+
+func TestHandleSchedule(t *testing.T) {
+	created := time.Date(2022, 4, 1, 2, 0, 0, 0, time.UTC)
+	schedule := &databasev1.DatabaseSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default", UID: "1234", CreationTimestamp: metav1.NewTime(created)},
+		Spec: databasev1.DatabaseSnapshotSpec{
+			Database:       "pgsql",
+			DeletionPolicy: databasev1.SnapshotRetain,
+			Schedule:       &databasev1.SnapshotSchedule{Cron: "0 3 * * *", Retention: 1},
+		},
+	}
+	old := &databasev1.DatabaseSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "nightly-1648695600",
+			Namespace:         "default",
+			Labels:            map[string]string{snapshotScheduleLabel: "nightly"},
+			CreationTimestamp: metav1.NewTime(created.Add(-23 * time.Hour)),
+		},
+	}
+	clientset := fake.NewSimpleClientset(schedule, old)
+	snapshotclient := clientset.DatabaseV1().DatabaseSnapshots("default")
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	assert.NoError(t, indexer.Add(old))
+	c := &Controller{snapshotLister: databaselisters.NewDatabaseSnapshotLister(indexer)}
+
+	now := created.Add(90 * time.Minute)
+	err := c.handleSchedule(context.Background(), schedule, snapshotclient, now)
+	pending, ok := provider.IsPending(err)
+	assert.True(t, ok)
+	assert.Equal(t, Scheduled, pending.State)
+	assert.Equal(t, 23*time.Hour+30*time.Minute, pending.RequeueAfter)
+
+	snapshot, err := snapshotclient.Get(context.Background(), "nightly-1648782000", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "pgsql", snapshot.Spec.Database)
+	assert.Equal(t, databasev1.SnapshotRetain, snapshot.Spec.DeletionPolicy)
+	assert.Nil(t, snapshot.Spec.Schedule)
+	assert.Equal(t, "nightly", snapshot.OwnerReferences[0].Name)
+
+	// the new snapshot isn't in the lister yet, so only the old one counts against the retention
+	_, err = snapshotclient.Get(context.Background(), "nightly-1648695600", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	updated, err := snapshotclient.Get(context.Background(), "nightly", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Add(time.Hour), updated.Status.LastScheduleTime.Time.UTC())
+	assert.Equal(t, created.Add(25*time.Hour), updated.Status.NextScheduleTime.Time.UTC())
+
+	// the run is recorded, calling again doesn't create another snapshot
+	snapshot.CreationTimestamp = metav1.NewTime(now)
+	assert.NoError(t, indexer.Add(snapshot))
+	err = c.handleSchedule(context.Background(), updated, snapshotclient, now)
+	_, ok = provider.IsPending(err)
+	assert.True(t, ok)
+	list, err := snapshotclient.List(context.Background(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 2)
+	_, err = snapshotclient.Get(context.Background(), "nightly-1648695600", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestSnapshotStatus(t *testing.T) {
+	s := &databasev1.DatabaseSnapshotStatus{}
+	setSnapshotInfo("aws", &provider.SnapshotInfo{ID: "snap-default", State: "creating", Progress: 40, Size: 20})(s)
+	setSnapshotPending(&provider.PendingError{State: "creating", Message: "snapshot snap-default is 40% done"})(s)
+	assert.Equal(t, "creating", s.State)
+	assert.Equal(t, "snap-default", s.SnapshotID)
+	assert.Equal(t, int32(40), s.Progress)
+	assert.Nil(t, s.CreationTime)
+
+	done := time.Now()
+	setSnapshotInfo("aws", &provider.SnapshotInfo{ID: "snap-default", State: provider.SnapshotAvailable, Progress: 100, Size: 20, CreationTime: &done})(s)
+	assert.Equal(t, Available, s.State)
+	assert.Equal(t, "aws", s.Provider)
+	assert.NotNil(t, s.CreationTime)
+}
+
+func TestSnapshotFinalizer(t *testing.T) {
+	s := &databasev1.DatabaseSnapshot{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default", Finalizers: []string{"other"}}}
+	clientset := fake.NewSimpleClientset(s)
+	snapshotclient := clientset.DatabaseV1().DatabaseSnapshots("default")
+
+	assert.NoError(t, addFinalizer(context.Background(), s, snapshotFinalizers(snapshotclient)))
+	updated, err := snapshotclient.Get(context.Background(), "nightly", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, hasFinalizer(updated))
+
+	assert.NoError(t, removeFinalizer(context.Background(), updated, snapshotFinalizers(snapshotclient)))
+	updated, err = snapshotclient.Get(context.Background(), "nightly", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other"}, updated.Finalizers)
+	for _, action := range clientset.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb())
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	databaseclient "github.com/sorenmat/k8s-rds/client/clientset/versioned/typed/database/v1"
+	"github.com/sorenmat/k8s-rds/provider"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// updateStatus fetches the latest version of the database, applies mutate to the status and
+// writes it through the status subresource so spec changes made in the meantime aren't lost
+func updateStatus(ctx context.Context, db *databasev1.Database, crdclient databaseclient.DatabaseInterface, mutate func(*databasev1.DatabaseStatus, int64)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := crdclient.Get(ctx, db.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		mutate(&latest.Status, latest.Generation)
+		now := metav1.Now()
+		latest.Status.LastReconcileTime = &now
+		_, err = crdclient.UpdateStatus(ctx, latest, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// setCreating marks the start of the provisioning of a database
+func setCreating(providerName string) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.State = Creating
+		s.Message = "Creating"
+		s.Provider = providerName
+		setCondition(s, generation, databasev1.ConditionReady, false, Creating, "Creating")
+		setCondition(s, generation, databasev1.ConditionProvisioning, true, Creating, "Creating")
+	}
+}
+
+// setPending records the intermediate state reported by the provider, a database that has been
+// available before stays ready while changes are applied
+func setPending(pending *provider.PendingError) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.State = pending.State
+		s.Message = pending.Message
+		if s.Endpoint == "" {
+			setCondition(s, generation, databasev1.ConditionReady, false, conditionReason(pending.State), pending.Message)
+		}
+		setCondition(s, generation, databasev1.ConditionProvisioning, true, conditionReason(pending.State), pending.Message)
+	}
+}
+
+// setAvailable records the database as reported by the provider, info is nil if nothing new was reported
+func setAvailable(info *provider.DatabaseInfo) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.State = Available
+		s.Message = "Database is available"
+		s.LastError = ""
+		s.ObservedGeneration = generation
+		if info != nil {
+			s.Endpoint = info.Hostname
+			s.Port = info.Port
+			s.ARN = info.ARN
+			s.ResourceID = info.ResourceID
+			s.EngineVersion = info.EngineVersion
+			s.ParameterApplyStatus = info.ParameterApplyStatus
+		}
+		setCondition(s, generation, databasev1.ConditionReady, true, Available, s.Message)
+		setCondition(s, generation, databasev1.ConditionProvisioning, false, Available, s.Message)
+		setCondition(s, generation, databasev1.ConditionDegraded, false, Available, s.Message)
+	}
+}
+
+// passwordHash identifies the master password of a database in its status without revealing it
+func passwordHash(db *databasev1.Database, password string) string {
+	sum := sha256.Sum256([]byte(string(db.UID) + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// setPasswordHash records the password the database was created with, it starts the rotation interval
+func setPasswordHash(hash string) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.PasswordHash = hash
+		if s.PasswordRotationTime == nil {
+			now := metav1.Now()
+			s.PasswordRotationTime = &now
+		}
+	}
+}
+
+// setPasswordRotated records a new master password sent to the provider
+func setPasswordRotated(hash string) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		now := metav1.Now()
+		s.PasswordHash = hash
+		s.PasswordRotationTime = &now
+		setCondition(s, generation, databasev1.ConditionPasswordRotated, true, "PasswordChanged", "The master password was changed to the value of the secret")
+	}
+}
+
+// setRestoredFrom records the snapshot or database a new database is restored from
+func setRestoredFrom(source string) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.RestoredFrom = source
+	}
+}
+
+// setReadReplicas records the read replicas of the database
+func setReadReplicas(replicas []databasev1.ReplicaStatus) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.ReadReplicas = replicas
+	}
+}
+
+// setFailed records a failed creation
+func setFailed(err error) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.State = Failed
+		s.Message = err.Error()
+		s.LastError = err.Error()
+		setCondition(s, generation, databasev1.ConditionReady, false, "ProvisioningFailed", err.Error())
+		setCondition(s, generation, databasev1.ConditionProvisioning, false, "ProvisioningFailed", err.Error())
+		setCondition(s, generation, databasev1.ConditionDegraded, true, "ProvisioningFailed", err.Error())
+	}
+}
+
+// setUpdateFailed records a failed update, the database is still running with the previous spec
+func setUpdateFailed(err error) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.State = Available
+		s.Message = "Update failed"
+		s.LastError = err.Error()
+		setCondition(s, generation, databasev1.ConditionProvisioning, false, "UpdateFailed", err.Error())
+		setCondition(s, generation, databasev1.ConditionDegraded, true, "UpdateFailed", err.Error())
+	}
+}
+
+// setDeleting records the progress of a deletion, err is the reason the last attempt failed
+func setDeleting(message string, err error) func(*databasev1.DatabaseStatus, int64) {
+	return func(s *databasev1.DatabaseStatus, generation int64) {
+		s.State = Deleting
+		s.Message = message
+		setCondition(s, generation, databasev1.ConditionReady, false, Deleting, message)
+		setCondition(s, generation, databasev1.ConditionDeleting, true, Deleting, message)
+		if err != nil {
+			s.LastError = err.Error()
+			setCondition(s, generation, databasev1.ConditionDegraded, true, "DeletionFailed", err.Error())
+		}
+	}
+}
+
+func setCondition(s *databasev1.DatabaseStatus, generation int64, conditionType string, status bool, reason, message string) {
+	c := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	}
+	if status {
+		c.Status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&s.Conditions, c)
+}
+
+// conditionReason turns a provider state like backing-up into a valid condition reason like BackingUp
+func conditionReason(state string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(state, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	if b.Len() == 0 {
+		return "Unknown"
+	}
+	return b.String()
+}
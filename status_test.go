@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	databasev1 "github.com/sorenmat/k8s-rds/apis/database/v1"
+	"github.com/sorenmat/k8s-rds/client/clientset/versioned/fake"
+	"github.com/sorenmat/k8s-rds/provider"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConditionReason(t *testing.T) {
+	assert.Equal(t, "BackingUp", conditionReason("backing-up"))
+	assert.Equal(t, "Creating", conditionReason("creating"))
+	assert.Equal(t, "StorageOptimization", conditionReason("storage-optimization"))
+	assert.Equal(t, "Unknown", conditionReason(""))
+}
+
+func TestStatusLifecycle(t *testing.T) {
+	s := &databasev1.DatabaseStatus{}
+
+	setCreating("aws")(s, 1)
+	assert.Equal(t, Creating, s.State)
+	assert.Equal(t, "aws", s.Provider)
+	assert.True(t, meta.IsStatusConditionFalse(s.Conditions, databasev1.ConditionReady))
+	assert.True(t, meta.IsStatusConditionTrue(s.Conditions, databasev1.ConditionProvisioning))
+
+	setPending(&provider.PendingError{State: "backing-up", Message: "waiting"})(s, 1)
+	assert.Equal(t, "backing-up", s.State)
+	assert.Equal(t, "BackingUp", meta.FindStatusCondition(s.Conditions, databasev1.ConditionReady).Reason)
+
+	setAvailable(&provider.DatabaseInfo{Hostname: "db.example.com", Port: 5432, EngineVersion: "13.4"})(s, 1)
+	assert.Equal(t, Available, s.State)
+	assert.Equal(t, int64(1), s.ObservedGeneration)
+	assert.Equal(t, "db.example.com", s.Endpoint)
+	assert.Equal(t, int32(5432), s.Port)
+	assert.True(t, meta.IsStatusConditionTrue(s.Conditions, databasev1.ConditionReady))
+	assert.True(t, meta.IsStatusConditionFalse(s.Conditions, databasev1.ConditionProvisioning))
+
+	// an available database stays ready while changes are applied
+	setPending(&provider.PendingError{State: "modifying", Message: "waiting"})(s, 2)
+	assert.True(t, meta.IsStatusConditionTrue(s.Conditions, databasev1.ConditionReady))
+	assert.True(t, meta.IsStatusConditionTrue(s.Conditions, databasev1.ConditionProvisioning))
+
+	setUpdateFailed(errors.New("invalid class"))(s, 2)
+	assert.Equal(t, "invalid class", s.LastError)
+	assert.True(t, meta.IsStatusConditionTrue(s.Conditions, databasev1.ConditionDegraded))
+
+	setAvailable(nil)(s, 2)
+	assert.Equal(t, "", s.LastError)
+	assert.Equal(t, int64(2), s.ObservedGeneration)
+	assert.Equal(t, "db.example.com", s.Endpoint)
+	assert.True(t, meta.IsStatusConditionFalse(s.Conditions, databasev1.ConditionDegraded))
+
+	setDeleting("Deleting", nil)(s, 2)
+	assert.True(t, meta.IsStatusConditionTrue(s.Conditions, databasev1.ConditionDeleting))
+	assert.True(t, meta.IsStatusConditionFalse(s.Conditions, databasev1.ConditionReady))
+}
+
+func TestUpdateStatusDoesNotModifyCachedObject(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}}
+	clientset := fake.NewSimpleClientset(db)
+	crdclient := clientset.DatabaseV1().Databases("default")
+
+	err := updateStatus(context.Background(), db, crdclient, setAvailable(&provider.DatabaseInfo{Hostname: "db.example.com", Port: 5432}))
+	assert.NoError(t, err)
+	assert.Empty(t, db.Status.State)
+	assert.Empty(t, db.Status.Conditions)
+
+	updated, err := crdclient.Get(context.Background(), "db", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, Available, updated.Status.State)
+	assert.Equal(t, "db.example.com", updated.Status.Endpoint)
+	assert.NotNil(t, updated.Status.LastReconcileTime)
+}
+
+func TestPasswordStatus(t *testing.T) {
+	db := &databasev1.Database{ObjectMeta: metav1.ObjectMeta{Name: "db", UID: "1234"}}
+	hash := passwordHash(db, "secret")
+	assert.NotContains(t, hash, "secret")
+	assert.Equal(t, hash, passwordHash(db, "secret"))
+	assert.NotEqual(t, hash, passwordHash(db, "other"))
+
+	s := &databasev1.DatabaseStatus{}
+	setPasswordHash(hash)(s, 1)
+	assert.Equal(t, hash, s.PasswordHash)
+	assert.NotNil(t, s.PasswordRotationTime)
+	assert.Nil(t, meta.FindStatusCondition(s.Conditions, databasev1.ConditionPasswordRotated))
+
+	created := s.PasswordRotationTime
+	setPasswordHash(hash)(s, 1)
+	assert.Equal(t, created, s.PasswordRotationTime)
+
+	setPasswordRotated(passwordHash(db, "other"))(s, 1)
+	assert.Equal(t, passwordHash(db, "other"), s.PasswordHash)
+	assert.True(t, meta.IsStatusConditionTrue(s.Conditions, databasev1.ConditionPasswordRotated))
+}
+
+func TestReadReplicasStatus(t *testing.T) {
+	s := &databasev1.DatabaseStatus{}
+	setReadReplicas([]databasev1.ReplicaStatus{{Name: "db-ro-0", Endpoint: "db-ro-0.rds", State: "available"}, {Name: "db-ro-1", State: "creating"}})(s, 1)
+	assert.Len(t, s.ReadReplicas, 2)
+	assert.Equal(t, "db-ro-0.rds", s.ReadReplicas[0].Endpoint)
+
+	setReadReplicas([]databasev1.ReplicaStatus{})(s, 1)
+	assert.Empty(t, s.ReadReplicas)
+}
+
+func TestRestoredFromStatus(t *testing.T) {
+	s := &databasev1.DatabaseStatus{}
+	setRestoredFrom("snapshot/db-default-1650000000")(s, 1)
+	assert.Equal(t, "snapshot/db-default-1650000000", s.RestoredFrom)
+}
@@ -8,3 +8,6 @@ package main
 
 // golangci-lint is used for linting the project
 import _ "github.com/golangci/golangci-lint/cmd/golangci-lint"
+
+// code-generator generates the deepcopy functions, clientset, listers and informers of the API, see scripts/update-codegen.sh
+import _ "k8s.io/code-generator"